@@ -14,6 +14,7 @@ import (
 	"aichat/flows"
 	"aichat/models"
 	"aichat/navigation"
+	"aichat/services/ai/mcp"
 	"aichat/services/cache"
 	"aichat/services/storage"
 	"aichat/types"
@@ -73,6 +74,15 @@ type UnifiedAppModel struct {
 	sidebar  *sidebar.SidebarTabsModel
 	chatView *chat.CompositeChatViewState
 
+	// Active chat's streaming worker and screen (nil when no chat is open)
+	streamWorker  *chat.StreamWorker
+	activeChat    *chat.ChatScreen
+	chatWaiting   bool // A command is waiting for the worker's next event
+	footerTicking bool // A tick keeps the footer's retry countdown and stream metrics current
+
+	// Commands handed over by menu actions, returned by the next Update
+	pendingCmds []tea.Cmd
+
 	// Modal system (using existing modal components)
 	modalManager *modals.ModalManager
 	modalActive  bool
@@ -215,6 +225,24 @@ func (nav *appNavigation) HandleExit() {
 	flows.FlowExitMenu(nav)
 }
 
+// HandleCompare runs the compare mode flow; the Chats menu reaches it through
+// appNavigation because menus cannot import the chat components
+func (nav *appNavigation) HandleCompare() {
+	flows.FlowCompareModels(nav)
+}
+
+// HandleOpenChat opens a chat from the Chats menu, which reaches it through
+// appNavigation because menus cannot import the chat components
+func (nav *appNavigation) HandleOpenChat(title string) {
+	nav.app.openChat(title)
+}
+
+// HandleCmd runs cmd in the background for a menu action, whose result
+// message comes back through Update
+func (nav *appNavigation) HandleCmd(cmd tea.Cmd) {
+	nav.app.pendingCmds = append(nav.app.pendingCmds, cmd)
+}
+
 // Implement QuitApp on appNavigation to send a QuitAppMsg to the Bubble Tea program
 func (nav *appNavigation) QuitApp() {
 	// Send a QuitAppMsg to the Bubble Tea program
@@ -251,7 +279,7 @@ func (m *UnifiedAppModel) Init() tea.Cmd {
 		}
 	}
 
-	return nil
+	return waitForApproval()
 }
 
 // Update handles messages and updates the application
@@ -285,8 +313,17 @@ func (m *UnifiedAppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q":
-			return m, tea.Quit
+			if !m.typing() {
+				return m, tea.Quit
+			}
+		case "esc":
+			if m.cancelGeneration() {
+				return m, nil
+			}
 		case "ctrl+c":
+			if m.cancelGeneration() {
+				return m, nil
+			}
 			top := m.navStack.Top()
 			if _, ok := top.(*types.MenuViewState); ok {
 				// Main menu or submenu: show exit confirmation
@@ -319,6 +356,22 @@ func (m *UnifiedAppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case common.ResizeMsg:
 		m.OnResize(msg.Width, msg.Height)
 		return m, nil
+	case footerTickMsg:
+		m.footerTicking = false
+		return m, m.footerTick()
+	case chat.ChatEventMsg:
+		if msg.Worker != m.streamWorker {
+			return m, nil // Event of a chat closed since
+		}
+		m.chatWaiting = false
+		m.activeChat.Apply(msg.Event)
+		return m, tea.Batch(m.chatEvents(), m.footerTick())
+	case mcpApprovalMsg:
+		m.showApprovalModal(msg.approval)
+		return m, waitForApproval()
+	case menus.ModelCatalogRefreshedMsg:
+		msg.Show()
+		return m, nil
 	}
 
 	// Update current ViewState (following project structure)
@@ -328,7 +381,7 @@ func (m *UnifiedAppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.navStack.ReplaceTop(vs)
 		}
 		if cmd != nil {
-			return m, cmd
+			return m, tea.Batch(cmd, m.chatEvents(), m.takeCmds())
 		}
 	}
 
@@ -355,7 +408,82 @@ func (m *UnifiedAppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// 	return m, tea.Quit
 	// }
 
-	return m, nil
+	return m, tea.Batch(m.chatEvents(), m.footerTick(), m.takeCmds())
+}
+
+// takeCmds returns the commands menu actions handed over since the last
+// Update.
+func (m *UnifiedAppModel) takeCmds() tea.Cmd {
+	cmds := m.pendingCmds
+	m.pendingCmds = nil
+	return tea.Batch(cmds...)
+}
+
+// chatEvents waits for the open chat's next worker event, unless a wait is
+// already pending: a single reader keeps the events in order.
+func (m *UnifiedAppModel) chatEvents() tea.Cmd {
+	if m.streamWorker == nil || m.chatWaiting {
+		return nil
+	}
+	m.chatWaiting = true
+	return chat.WaitForEvent(m.streamWorker)
+}
+
+// openChat shows the chat titled title, with a worker streaming its replies
+// until the chat is closed.
+func (m *UnifiedAppModel) openChat(title string) {
+	nav := &appNavigation{app: m}
+	m.closeChat()
+	screen, err := flows.OpenChat(title, func() {
+		m.closeChat()
+		nav.Pop()
+	})
+	if err != nil {
+		m.logger.Warn("Failed to open chat", "chat", title, "error", err)
+		nav.Push(dialogs.NewListModalFactory("Open Chat", []string{err.Error()}, func(int) {}, func() { nav.Pop() }, modals.ModalRenderConfig{}))
+		return
+	}
+	screen.OnViewLastRequest = func() { _ = menus.ViewLastRequestAction(&appContext{app: m}, nav) }
+	screen.Resize(m.width, m.height)
+	m.streamWorker, m.activeChat = screen.Worker, screen
+	nav.Push(screen)
+}
+
+// closeChat stops the open chat's worker, if any. A wait still pending on it
+// ends once the worker is stopped.
+func (m *UnifiedAppModel) closeChat() {
+	if m.streamWorker == nil {
+		return
+	}
+	m.streamWorker.Stop()
+	m.streamWorker, m.activeChat, m.chatWaiting = nil, nil, false
+}
+
+// typing reports whether the top view takes text input, so letter keys must
+// not act as global shortcuts.
+func (m *UnifiedAppModel) typing() bool {
+	switch m.navStack.Top().(type) {
+	case *chat.ChatScreen, *chat.CompareView:
+		return true
+	}
+	return false
+}
+
+// footerTickMsg redraws the footer, and the open chat's retry countdown,
+// while a response streams or a request waits to be retried.
+type footerTickMsg struct{}
+
+// footerTick schedules the next footer redraw when a response is streaming
+// or a retry is pending, and no tick is already scheduled.
+func (m *UnifiedAppModel) footerTick() tea.Cmd {
+	if m.footerTicking || m.streamWorker == nil {
+		return nil
+	}
+	if !m.streamWorker.Generating() && m.streamWorker.RetryStatus() == "" {
+		return nil
+	}
+	m.footerTicking = true
+	return tea.Tick(250*time.Millisecond, func(time.Time) tea.Msg { return footerTickMsg{} })
 }
 
 // View renders the application
@@ -434,6 +562,9 @@ func (m *UnifiedAppModel) handleKeyPress(key tea.KeyMsg) (tea.Model, tea.Cmd) {
 	// Global keys
 	switch keyStr {
 	case "ctrl+c", "q":
+		if keyStr == "ctrl+c" && m.cancelGeneration() {
+			return m, nil
+		}
 		nav := &appNavigation{app: m}
 		flows.FlowExitMenu(nav)
 		return m, nil
@@ -450,6 +581,9 @@ func (m *UnifiedAppModel) handleKeyPress(key tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.focus = "menu"
 		return m, m.showMainMenu()
 	case "esc":
+		if m.cancelGeneration() {
+			return m, nil
+		}
 		return m, m.goBack()
 	case "r":
 		// Refresh layout
@@ -522,6 +656,15 @@ func (m *UnifiedAppModel) goBack() tea.Cmd {
 	}
 }
 
+// cancelGeneration aborts the in-flight AI response, if any.
+// Returns true when a generation was cancelled so the key is consumed.
+func (m *UnifiedAppModel) cancelGeneration() bool {
+	if m.streamWorker == nil || !m.streamWorker.Generating() {
+		return false
+	}
+	return m.streamWorker.CancelGeneration()
+}
+
 // =====================================================================================
 // 🎯 Modal Methods (using existing modal components)
 // =====================================================================================
@@ -588,6 +731,41 @@ func (m *UnifiedAppModel) showQuitConfirmationModal() {
 	m.modalActive = true
 }
 
+// mcpApprovalMsg carries an MCP tool call waiting for the user's approval.
+type mcpApprovalMsg struct {
+	approval *mcp.Approval
+}
+
+// waitForApproval delivers the next MCP tool call that needs approval.
+func waitForApproval() tea.Cmd {
+	return func() tea.Msg {
+		return mcpApprovalMsg{approval: <-mcp.Approvals()}
+	}
+}
+
+// showApprovalModal asks whether a model may run an MCP tool; closing the
+// modal without choosing denies the call
+func (m *UnifiedAppModel) showApprovalModal(a *mcp.Approval) {
+	nav := &appNavigation{app: m}
+	args := string(a.Arguments)
+	if len(args) > 200 {
+		args = args[:197] + "..."
+	}
+	modal := dialogs.NewConfirmationModal(
+		fmt.Sprintf("Run tool %q from MCP server %q?\n%s", a.Tool, a.Server, args),
+		[]modals.ModalOption{
+			{Label: "Allow", OnSelect: func() { a.Answer(true) }},
+			{Label: "Deny", OnSelect: func() { a.Answer(false) }},
+		},
+		func() {
+			a.Answer(false)
+			nav.HideModal()
+		},
+		modals.ModalRenderConfig{},
+	)
+	nav.ShowModal("confirmation", modal)
+}
+
 // =====================================================================================
 // 🎯 Focus Management
 // =====================================================================================
@@ -741,6 +919,17 @@ func (m *UnifiedAppModel) renderFooter(width int) string {
 		helpLines = append(helpLines, "Type to chat | Enter: Send")
 	}
 
+	// Show a pending retry instead of leaving the chat silently stalled, and
+	// how fast the response streams (or the last one streamed)
+	if m.streamWorker != nil {
+		if status := m.streamWorker.RetryStatus(); status != "" {
+			helpLines = append(helpLines, status)
+		}
+		if metrics, ok := m.streamWorker.Metrics(); ok {
+			helpLines = append(helpLines, metrics.String())
+		}
+	}
+
 	// Add performance info if stats are shown
 	if m.showStats {
 		stats := m.GetPerformanceStats()
@@ -953,8 +1142,12 @@ func convertTypesMessagesToChatMessages(msgs []types.Message) []models.ChatMessa
 	out := make([]models.ChatMessage, len(msgs))
 	for i, m := range msgs {
 		out[i] = models.ChatMessage{
-			Content: m.Content,
-			IsUser:  m.Role == "user", // Assuming Role "user" means it's a user message
+			Content:     m.Content,
+			IsUser:      m.Role == "user", // Assuming Role "user" means it's a user message
+			Attachments: m.Attachments,
+			ToolCalls:   m.ToolCalls,
+			ToolName:    m.ToolName,
+			Reasoning:   m.Reasoning,
 		}
 	}
 	return out
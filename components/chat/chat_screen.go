@@ -0,0 +1,279 @@
+package chat
+
+// chat_screen.go - An open chat: its transcript, the reply streaming in and
+// the input line, with the chat's StreamWorker doing the streaming.
+
+import (
+	"aichat/components/input"
+	"aichat/interfaces"
+	"aichat/models"
+	"aichat/types"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ChatEventMsg carries an event of an open chat's worker to the UI.
+type ChatEventMsg struct {
+	Worker *StreamWorker
+	Event  StreamEvent
+}
+
+// WaitForEvent returns a command delivering the worker's next event as a
+// ChatEventMsg. It yields nil once the worker is stopped. Keep one pending
+// at a time so events arrive in order.
+func WaitForEvent(w *StreamWorker) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case ev := <-w.EventChan:
+			return ChatEventMsg{Worker: w, Event: ev}
+		case <-w.Done():
+			return nil
+		}
+	}
+}
+
+// ChatScreen shows an open chat. Enter sends the input to the worker, esc
+// stops the reply being streamed or else closes the chat, ctrl+t expands or
+// collapses the thinking of reasoning models and ctrl+r shows the last
+// provider request. The worker's events are read
+// by the app and handed to Apply.
+type ChatScreen struct {
+	Chat   *types.ChatFile
+	Worker *StreamWorker
+	Input  *input.InputModel
+	Status string // Outcome of the last exchange, shown above the input
+	Width  int
+	Height int
+
+	// OnSave stores the chat after each message.
+	OnSave func(chat *types.ChatFile) error
+	// OnClose leaves the screen and stops the worker.
+	OnClose func()
+	// OnViewLastRequest shows the last logged provider request.
+	OnViewLastRequest func()
+
+	transcript *ChatView
+	reply      string // Answer streaming in
+	reasoning  string // Thinking streamed before the answer
+	waiting    bool   // A message was sent and its reply has not ended
+}
+
+// NewChatScreen creates the screen of chat c, streamed by w.
+func NewChatScreen(c *types.ChatFile, w *StreamWorker, onSave func(*types.ChatFile) error, onClose func()) *ChatScreen {
+	s := &ChatScreen{
+		Chat:       c,
+		Worker:     w,
+		Input:      &input.InputModel{Focused: true},
+		Width:      80,
+		Height:     24,
+		OnSave:     onSave,
+		OnClose:    onClose,
+		transcript: NewChatView(),
+	}
+	s.updateGauge()
+	return s
+}
+
+func (s *ChatScreen) Init() tea.Cmd { return nil }
+
+// Resize is called by the app when the terminal size changes.
+func (s *ChatScreen) Resize(width, height int) {
+	s.Width, s.Height = width, height
+}
+
+func (s *ChatScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return s, nil
+	}
+	switch keyMsg.String() {
+	case "enter":
+		// "/attach path" is the input's own command.
+		if strings.HasPrefix(s.Input.Buffer, "/attach ") {
+			s.Input.Update(msg)
+			return s, nil
+		}
+		s.send()
+	case "ctrl+t":
+		s.transcript.ShowReasoning = !s.transcript.ShowReasoning
+	case "ctrl+r":
+		if s.OnViewLastRequest != nil {
+			s.OnViewLastRequest()
+		}
+	case "esc", "ctrl+q":
+		if s.Worker.CancelGeneration() {
+			return s, nil
+		}
+		if s.OnClose != nil {
+			s.OnClose()
+		}
+	default:
+		s.Input.Update(msg)
+		s.updateGauge()
+	}
+	return s, nil
+}
+
+// updateGauge shows what the next request would cost against the model's
+// context window: the conversation plus the text being typed.
+func (s *ChatScreen) updateGauge() {
+	s.Input.SetTokenUsage(s.Worker.ContextUsage(s.Input.Buffer))
+}
+
+// send adds the typed message to the chat and hands it to the worker.
+func (s *ChatScreen) send() {
+	text := strings.TrimSpace(s.Input.Buffer)
+	if text == "" && len(s.Input.Attachments) == 0 {
+		return
+	}
+	if s.waiting {
+		s.Status = "Wait for the reply, or press esc to stop it"
+		return
+	}
+	msg := types.Message{
+		Role:          "user",
+		Content:       text,
+		MessageNumber: len(s.Chat.Messages) + 1,
+		Attachments:   s.Input.TakeAttachments(),
+	}
+	s.Input.Buffer, s.Input.Cursor, s.Input.Message = "", 0, ""
+	s.Chat.Messages = append(s.Chat.Messages, msg)
+	s.Status = ""
+	s.waiting = true
+	s.save()
+	s.Worker.InputChan <- msg.ToAI()
+	s.updateGauge()
+}
+
+// Apply records a worker event in the chat; the chat is saved once the
+// reply ends.
+func (s *ChatScreen) Apply(ev StreamEvent) {
+	switch ev.Type {
+	case StreamEventChunk:
+		s.reply += ev.Content
+		s.reasoning += ev.Reasoning
+		s.Status = ""
+	case StreamEventRetry:
+		// View counts down to the retry while the app's tick redraws it.
+		s.Status = "Request failed: " + ev.Retry.Err.Error()
+	case StreamEventMessage:
+		// Text streamed so far belongs to the tool call being recorded.
+		if ev.Message != nil {
+			s.Chat.Messages = append(s.Chat.Messages, types.MessageFromAI(*ev.Message, len(s.Chat.Messages)+1))
+		}
+		s.reply, s.reasoning = "", ""
+	case StreamEventDone:
+		s.finish(ev)
+	case StreamEventCancel:
+		s.Status = "Stopped"
+		s.finish(ev)
+	case StreamEventError:
+		s.Status = "Error: " + ev.Err.Error()
+		s.finish(ev)
+	}
+}
+
+// finish ends the exchange with ev, keeping what was streamed: the worker
+// keeps a partial answer in its history too. The exchange's usage goes on
+// the answer and into the chat's totals; the answer also records its stream
+// metrics and who answered, which differs from the chat's model under a
+// fallback chain.
+func (s *ChatScreen) finish(ev StreamEvent) {
+	if s.reply != "" || s.reasoning != "" {
+		s.Chat.Messages = append(s.Chat.Messages, types.Message{
+			Role:          "assistant",
+			Content:       s.reply,
+			Reasoning:     s.reasoning,
+			MessageNumber: len(s.Chat.Messages) + 1,
+			Usage:         ev.Usage,
+			Metrics:       ev.Metrics,
+			Provider:      ev.Provider,
+			Model:         ev.Model,
+		})
+	}
+	if ev.Usage != nil {
+		s.Chat.AddUsage(*ev.Usage)
+	}
+	s.reply, s.reasoning = "", ""
+	s.waiting = false
+	s.save()
+	s.updateGauge()
+}
+
+func (s *ChatScreen) save() {
+	s.Chat.Metadata.ModifiedAt = time.Now().Unix()
+	if s.OnSave == nil {
+		return
+	}
+	if err := s.OnSave(s.Chat); err != nil {
+		s.Status = "Saving failed: " + err.Error()
+	}
+}
+
+// Waiting reports whether a reply is still expected.
+func (s *ChatScreen) Waiting() bool {
+	return s.waiting
+}
+
+func (s *ChatScreen) UpdateWithContext(msg tea.Msg, ctx interfaces.Context, nav interfaces.Controller) (tea.Model, tea.Cmd) {
+	return s.Update(msg)
+}
+
+func (s *ChatScreen) View() string {
+	messages := make([]models.ChatMessage, 0, len(s.Chat.Messages)+1)
+	for _, m := range s.Chat.Messages {
+		messages = append(messages, chatMessage(m))
+	}
+	if s.waiting {
+		messages = append(messages, models.ChatMessage{Content: s.reply + "…", Reasoning: s.reasoning})
+	}
+	transcript := strings.Split(strings.TrimRight(s.transcript.Render(models.NewChatViewState(s.Chat.Metadata.Title, messages)), "\n"), "\n")
+	// Show the end of long chats so the streaming reply stays in view.
+	if height := max(s.Height-6, 4); len(transcript) > height {
+		transcript = append([]string{compareStatusStyle.Render("…")}, transcript[len(transcript)-height+1:]...)
+	}
+
+	var b strings.Builder
+	b.WriteString(compareHeaderStyle.Render(s.Chat.Metadata.Title) + "\n")
+	b.WriteString(strings.Join(transcript, "\n") + "\n")
+	status := s.Status
+	if retry := s.Worker.RetryStatus(); retry != "" {
+		status += "; " + retry
+	}
+	if status != "" {
+		b.WriteString(compareStatusStyle.Render(status) + "\n")
+	}
+	b.WriteString(s.Input.View() + "\n")
+	help := "Enter: Send | Alt+V: Attach from clipboard | Ctrl+T: Thinking | Ctrl+R: Last request | Esc: Back"
+	if s.waiting {
+		help = "Esc: Stop | streaming…"
+		if metrics, ok := s.Worker.Metrics(); ok {
+			help += " " + metrics.String()
+		}
+	}
+	b.WriteString(compareStatusStyle.Render(help))
+	return b.String()
+}
+
+// chatMessage converts a stored message for the transcript.
+func chatMessage(m types.Message) models.ChatMessage {
+	return models.ChatMessage{
+		Content:     m.Content,
+		IsUser:      m.Role == "user",
+		Attachments: m.Attachments,
+		ToolCalls:   m.ToolCalls,
+		ToolName:    m.ToolName,
+		Reasoning:   m.Reasoning,
+	}
+}
+
+// Add ViewState compliance methods
+func (s *ChatScreen) IsMainMenu() bool                 { return false }
+func (s *ChatScreen) Type() interfaces.ViewType        { return interfaces.ChatStateType }
+func (s *ChatScreen) ViewType() interfaces.ViewType    { return interfaces.ChatStateType }
+func (s *ChatScreen) MarshalState() ([]byte, error)    { return nil, nil }
+func (s *ChatScreen) UnmarshalState(data []byte) error { return nil }
+
+var _ types.ViewState = (*ChatScreen)(nil)
@@ -0,0 +1,235 @@
+package chat
+
+// compare.go - Fan-out of one prompt to several models, each streamed by its
+// own StreamWorker, so their answers can be compared and one kept.
+
+import (
+	"aichat/services/ai"
+	"aichat/services/ai/tokens"
+	aitypes "aichat/services/ai/types"
+	"aichat/services/ai/usage"
+	"aichat/types"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// CompareTarget is one model taking part in a comparison.
+type CompareTarget struct {
+	Provider ai.AIProvider
+	Model    string
+	APIKey   string
+	Params   aitypes.GenerationParams // The chat's settings over this model's defaults
+	Context  *tokens.Manager          // Optional; keeps requests within the context window
+	Recorder usage.Recorder           // Optional; global usage log
+	KeyTitle string                   // Title of APIKey, for usage reports
+}
+
+// CompareColumn is one model's answer to the current prompt.
+type CompareColumn struct {
+	Target    CompareTarget
+	Content   string
+	Reasoning string
+	Messages  []aitypes.Message // Tool calls and results streamed before the answer
+	Usage     *aitypes.Usage
+	Metrics   *aitypes.StreamMetrics
+	Provider  string // Provider and model that answered, as reported on done
+	Model     string
+	Done      bool
+	Cancelled bool
+	Err       error
+}
+
+// Finished reports whether the column's stream has ended for any reason.
+func (c *CompareColumn) Finished() bool {
+	return c.Done || c.Cancelled || c.Err != nil
+}
+
+// CompareEventMsg carries a worker event to the UI, tagged with the column
+// of the worker that sent it.
+type CompareEventMsg struct {
+	Column int
+	Event  StreamEvent
+}
+
+// CompareSession streams the same conversation to several models at once.
+// Every target gets its own StreamWorker; their events are merged into one
+// channel read through Wait.
+type CompareSession struct {
+	Columns []*CompareColumn
+	Prompt  aitypes.Message // The prompt being answered
+
+	workers  []*StreamWorker
+	events   chan CompareEventMsg
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// StartCompareSession starts a worker per target, each holding history as
+// its conversation.
+func StartCompareSession(chatID string, history []aitypes.Message, targets []CompareTarget) *CompareSession {
+	s := &CompareSession{
+		events: make(chan CompareEventMsg, 10*len(targets)),
+		done:   make(chan struct{}),
+	}
+	for i, target := range targets {
+		w := StartStreamWorker(chatID, target.Model, target.APIKey, target.Provider)
+		w.SetHistory(history)
+		w.SetParams(target.Params)
+		w.Context = target.Context
+		w.Recorder, w.KeyTitle = target.Recorder, target.KeyTitle
+		s.workers = append(s.workers, w)
+		s.Columns = append(s.Columns, &CompareColumn{Target: target, Done: true})
+		go s.forward(i, w)
+	}
+	return s
+}
+
+// forward tags the events of one worker with its column until the session
+// stops.
+func (s *CompareSession) forward(column int, w *StreamWorker) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case ev := <-w.EventChan:
+			select {
+			case s.events <- CompareEventMsg{Column: column, Event: ev}:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// Send asks every model to answer content and clears the previous answers.
+func (s *CompareSession) Send(content string) {
+	s.Prompt = aitypes.Message{Role: "user", Content: content}
+	for i, w := range s.workers {
+		s.Columns[i] = &CompareColumn{Target: s.Columns[i].Target}
+		w.InputChan <- s.Prompt
+	}
+}
+
+// Wait returns a command delivering the next worker event as a
+// CompareEventMsg. It yields nil once the session is stopped.
+func (s *CompareSession) Wait() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case msg := <-s.events:
+			return msg
+		case <-s.done:
+			return nil
+		}
+	}
+}
+
+// Apply records an event in its column.
+func (s *CompareSession) Apply(msg CompareEventMsg) {
+	if msg.Column < 0 || msg.Column >= len(s.Columns) {
+		return
+	}
+	col := s.Columns[msg.Column]
+	ev := msg.Event
+	switch ev.Type {
+	case StreamEventChunk:
+		col.Content += ev.Content
+		col.Reasoning += ev.Reasoning
+	case StreamEventMessage:
+		// Text streamed so far belongs to the tool call being recorded.
+		if ev.Message != nil {
+			col.Messages = append(col.Messages, *ev.Message)
+		}
+		col.Content = ""
+	case StreamEventDone:
+		col.Done = true
+		col.Usage = ev.Usage
+		col.Metrics = ev.Metrics
+		col.Provider, col.Model = ev.Provider, ev.Model
+	case StreamEventCancel:
+		col.Cancelled = true
+	case StreamEventError:
+		col.Err = ev.Err
+	}
+}
+
+// Streaming reports whether any model is still answering.
+func (s *CompareSession) Streaming() bool {
+	for _, col := range s.Columns {
+		if !col.Finished() {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryStatus returns the pending retry of a column's worker, if any.
+func (s *CompareSession) RetryStatus(column int) string {
+	return s.workers[column].RetryStatus()
+}
+
+// Metrics returns the live latency and throughput of a column's answer.
+func (s *CompareSession) Metrics(column int) (aitypes.StreamMetrics, bool) {
+	return s.workers[column].Metrics()
+}
+
+// Cancel aborts every answer still streaming. The workers stay alive.
+func (s *CompareSession) Cancel() {
+	for _, w := range s.workers {
+		w.CancelGeneration()
+	}
+}
+
+// Keep makes a finished column's answer the canonical reply: every worker
+// continues the conversation from it. It returns the messages to append to
+// the chat after the prompt, numbered from next, or nil if the column has
+// no complete answer.
+func (s *CompareSession) Keep(column, next int) []types.Message {
+	if column < 0 || column >= len(s.Columns) || !s.Columns[column].Done || s.Prompt.Content == "" {
+		return nil
+	}
+	col := s.Columns[column]
+	replies := append([]aitypes.Message(nil), col.Messages...)
+	if col.Content != "" {
+		replies = append(replies, aitypes.Message{Role: "assistant", Content: col.Content})
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	kept := make([]types.Message, len(replies))
+	for i, m := range replies {
+		kept[i] = types.MessageFromAI(m, next+i)
+	}
+	last := &kept[len(kept)-1]
+	last.Usage = col.Usage
+	last.Metrics = col.Metrics
+	last.Provider, last.Model = col.Provider, col.Model
+	last.Reasoning = col.Reasoning
+
+	for _, w := range s.workers {
+		w.historyMutex.Lock()
+		base := w.history
+		// A worker's history ends with its own answer to the prompt;
+		// replace everything from the prompt on.
+		for i := len(base) - 1; i >= 0; i-- {
+			if base[i].Role == "user" && base[i].Content == s.Prompt.Content {
+				base = base[:i]
+				break
+			}
+		}
+		w.history = append(append(append([]aitypes.Message(nil), base...), s.Prompt), replies...)
+		w.historyMutex.Unlock()
+	}
+	s.Prompt = aitypes.Message{}
+	return kept
+}
+
+// Stop ends the session and its workers.
+func (s *CompareSession) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+		for _, w := range s.workers {
+			w.Stop()
+		}
+	})
+}
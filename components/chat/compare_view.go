@@ -0,0 +1,230 @@
+package chat
+
+// compare_view.go - Side-by-side columns of a CompareSession, with a prompt
+// line and keys to pick the answer kept in the chat.
+
+import (
+	"aichat/interfaces"
+	"aichat/models"
+	"aichat/types"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	compareColumnStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("240")).Padding(0, 1)
+	compareSelectedStyle = compareColumnStyle.BorderForeground(lipgloss.Color("205"))
+	compareHeaderStyle   = lipgloss.NewStyle().Bold(true)
+	compareStatusStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
+)
+
+// CompareView shows one column per model of a CompareSession. Enter sends
+// the typed prompt to every model; once the selected column is done, enter
+// on an empty prompt keeps its answer through OnKeep.
+type CompareView struct {
+	Session  *CompareSession
+	Title    string
+	Input    string
+	Selected int
+	Kept     int // Column kept for the current answers, -1 if none
+	Width    int
+	Height   int
+	Status   string // Outcome of the last keep, shown under the columns
+
+	// OnKeep stores the prompt and the answer of the given column in the
+	// chat, typically through CompareSession.Keep.
+	OnKeep func(column int) error
+	// OnClose leaves the view. The session is stopped first.
+	OnClose func()
+}
+
+// NewCompareView creates the view of a started session.
+func NewCompareView(session *CompareSession, title string, onKeep func(column int) error, onClose func()) *CompareView {
+	return &CompareView{
+		Session: session,
+		Title:   title,
+		Kept:    -1,
+		Width:   120,
+		Height:  30,
+		OnKeep:  onKeep,
+		OnClose: onClose,
+	}
+}
+
+func (v *CompareView) Init() tea.Cmd { return nil }
+
+// Resize is called by the app when the terminal size changes.
+func (v *CompareView) Resize(width, height int) {
+	v.Width, v.Height = width, height
+}
+
+func (v *CompareView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case CompareEventMsg:
+		v.Session.Apply(msg)
+		return v, v.Session.Wait()
+	case tea.KeyMsg:
+		return v, v.handleKey(msg)
+	}
+	return v, nil
+}
+
+func (v *CompareView) handleKey(msg tea.KeyMsg) tea.Cmd {
+	n := len(v.Session.Columns)
+	switch msg.Type {
+	case tea.KeyLeft, tea.KeyShiftTab:
+		v.Selected = (v.Selected + n - 1) % n
+	case tea.KeyRight, tea.KeyTab:
+		v.Selected = (v.Selected + 1) % n
+	case tea.KeyEsc:
+		if v.Session.Streaming() {
+			v.Session.Cancel()
+			return nil
+		}
+		v.Session.Stop()
+		if v.OnClose != nil {
+			v.OnClose()
+		}
+	case tea.KeyEnter:
+		if v.Session.Streaming() {
+			return nil
+		}
+		if prompt := strings.TrimSpace(v.Input); prompt != "" {
+			v.Session.Send(prompt)
+			v.Input = ""
+			v.Kept = -1
+			v.Status = ""
+			return v.Session.Wait()
+		}
+		v.keep()
+	case tea.KeyBackspace:
+		if r := []rune(v.Input); len(r) > 0 {
+			v.Input = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		v.Input += string(msg.Runes)
+	}
+	return nil
+}
+
+// keep hands the selected answer to OnKeep, once per prompt.
+func (v *CompareView) keep() {
+	col := v.Session.Columns[v.Selected]
+	switch {
+	case v.Kept >= 0:
+		v.Status = "An answer to this prompt was already kept"
+	case v.Session.Prompt.Content == "":
+		v.Status = "Type a prompt and press enter first"
+	case !col.Done:
+		v.Status = "Only a complete answer can be kept"
+	case v.OnKeep != nil:
+		if err := v.OnKeep(v.Selected); err != nil {
+			v.Status = "Keeping failed: " + err.Error()
+			return
+		}
+		v.Kept = v.Selected
+		v.Status = "Kept the answer of " + col.Target.Model
+	}
+}
+
+func (v *CompareView) UpdateWithContext(msg tea.Msg, ctx interfaces.Context, nav interfaces.Controller) (tea.Model, tea.Cmd) {
+	return v.Update(msg)
+}
+
+func (v *CompareView) View() string {
+	n := len(v.Session.Columns)
+	if n == 0 {
+		return "No models to compare"
+	}
+	// Border and padding take four cells of every column.
+	width := max(v.Width/n-4, 16)
+	height := max(v.Height-8, 4)
+	columns := make([]string, n)
+	for i, col := range v.Session.Columns {
+		style := compareColumnStyle
+		if i == v.Selected {
+			style = compareSelectedStyle
+		}
+		columns[i] = style.Width(width).Render(v.renderColumn(i, col, width, height))
+	}
+
+	var b strings.Builder
+	b.WriteString(compareHeaderStyle.Render("Compare: "+v.Title) + "\n")
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, columns...) + "\n")
+	if v.Status != "" {
+		b.WriteString(compareStatusStyle.Render(v.Status) + "\n")
+	}
+	b.WriteString("> " + v.Input + "█\n")
+	help := "←/→: Select | Enter: Send prompt, or keep selected answer | Esc: Back"
+	if v.Session.Streaming() {
+		help = "←/→: Select | Esc: Stop all"
+	}
+	b.WriteString(compareStatusStyle.Render(help))
+	return b.String()
+}
+
+// renderColumn renders a model's header, status and answer, showing the end
+// of answers taller than height so streaming text stays in view.
+func (v *CompareView) renderColumn(i int, col *CompareColumn, width, height int) string {
+	header := compareHeaderStyle.Render(col.Target.Model)
+	if col.Target.Provider != nil {
+		header += compareStatusStyle.Render(" (" + col.Target.Provider.Info().Name + ")")
+	}
+	body := col.Content
+	if block := (models.ChatMessage{Reasoning: col.Reasoning}).ReasoningBlock(false); block != "" {
+		body = reasoningStyle.Render(block) + "\n" + body
+	}
+	lines := strings.Split(lipgloss.NewStyle().Width(width).Render(body), "\n")
+	if len(lines) > height {
+		lines = append([]string{compareStatusStyle.Render("…")}, lines[len(lines)-height+1:]...)
+	}
+	return header + "\n" + compareStatusStyle.Render(v.columnStatus(i, col)) + "\n\n" + strings.Join(lines, "\n")
+}
+
+func (v *CompareView) columnStatus(i int, col *CompareColumn) string {
+	switch {
+	case v.Session.Prompt.Content == "" && v.Kept != i:
+		if col.Content == "" {
+			return "ready"
+		}
+		return "done"
+	case col.Err != nil:
+		return "error: " + col.Err.Error()
+	case col.Cancelled:
+		return "stopped"
+	case !col.Done:
+		if retry := v.Session.RetryStatus(i); retry != "" {
+			return retry
+		}
+		if metrics, ok := v.Session.Metrics(i); ok {
+			return "streaming… " + metrics.String()
+		}
+		return "streaming…"
+	}
+	status := "done"
+	if v.Kept == i {
+		status = "kept"
+	}
+	if col.Usage != nil {
+		status += fmt.Sprintf(" · %d tokens", col.Usage.PromptTokens+col.Usage.CompletionTokens)
+		if col.Usage.Cost > 0 {
+			status += fmt.Sprintf(" · $%.4f", col.Usage.Cost)
+		}
+	}
+	if col.Metrics != nil {
+		status += " · " + col.Metrics.String()
+	}
+	return status
+}
+
+// Add ViewState compliance methods
+func (v *CompareView) IsMainMenu() bool                 { return false }
+func (v *CompareView) Type() interfaces.ViewType        { return interfaces.ChatStateType }
+func (v *CompareView) ViewType() interfaces.ViewType    { return interfaces.ChatStateType }
+func (v *CompareView) MarshalState() ([]byte, error)    { return nil, nil }
+func (v *CompareView) UnmarshalState(data []byte) error { return nil }
+
+var _ types.ViewState = (*CompareView)(nil)
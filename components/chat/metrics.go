@@ -0,0 +1,82 @@
+package chat
+
+// metrics.go - Latency and throughput of the response being streamed.
+
+import (
+	"aichat/services/ai/tokens"
+	aitypes "aichat/services/ai/types"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamMeter times one generation. The worker writes it while the UI reads
+// snapshots for the footer, hence the mutex.
+type streamMeter struct {
+	mu       sync.Mutex
+	start    time.Time
+	first    time.Time // Zero until the first token
+	end      time.Time // Zero while streaming
+	text     strings.Builder
+	reported int // Completion tokens the provider reported, 0 if none
+}
+
+// begin starts timing a new generation.
+func (m *streamMeter) begin() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.start, m.first, m.end = time.Now(), time.Time{}, time.Time{}
+	m.text.Reset()
+	m.reported = 0
+}
+
+// chunk records streamed text; the first one marks the first token.
+func (m *streamMeter) chunk(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.first.IsZero() {
+		m.first = time.Now()
+	}
+	m.text.WriteString(text)
+}
+
+// report records completion tokens the provider counted, which replace the
+// local estimate.
+func (m *streamMeter) report(completion int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reported += completion
+}
+
+// finish stops the clock, if still running, and returns the final metrics.
+func (m *streamMeter) finish() aitypes.StreamMetrics {
+	m.mu.Lock()
+	if m.end.IsZero() {
+		m.end = time.Now()
+	}
+	m.mu.Unlock()
+	metrics, _ := m.snapshot()
+	return metrics
+}
+
+// snapshot returns the metrics so far, or false before any generation.
+func (m *streamMeter) snapshot() (aitypes.StreamMetrics, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.start.IsZero() {
+		return aitypes.StreamMetrics{}, false
+	}
+	end := m.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	metrics := aitypes.StreamMetrics{Duration: end.Sub(m.start), Tokens: m.reported}
+	if !m.first.IsZero() {
+		metrics.TimeToFirstToken = m.first.Sub(m.start)
+	}
+	if metrics.Tokens == 0 && m.text.Len() > 0 {
+		metrics.Tokens = tokens.DefaultTokenizer.Count(m.text.String())
+		metrics.Estimated = true
+	}
+	return metrics, true
+}
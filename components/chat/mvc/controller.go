@@ -21,7 +21,14 @@ func NewChatController(model models.IChatModel, view interfaces.IChatView) *Chat
 }
 
 // HandleEvent implements interfaces.IChatController
+// "t" expands or collapses the thinking of reasoning models.
 func (c *ChatController) HandleEvent(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok && key.String() == "t" {
+		if state, ok := c.model.(interface{ ToggleReasoning() }); ok {
+			state.ToggleReasoning()
+			return c, nil
+		}
+	}
 	// TODO: Implement event handling logic, update model as needed
 	return c, nil
 }
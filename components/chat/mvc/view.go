@@ -13,6 +13,7 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
 )
 
 type ChatView struct {
@@ -26,7 +27,11 @@ type ChatView struct {
 	Spinner     spinner.Model
 }
 
+// reasoningStyle dims the thinking of reasoning models so the answer stands out.
+var reasoningStyle = lipgloss.NewStyle().Faint(true)
+
 type chatMessageDelegate struct {
+	State      *models.ChatViewState // Read for ShowReasoning
 	ThemeMap   render.ThemeMap
 	Strategies map[string]render.RenderStrategy
 }
@@ -41,16 +46,14 @@ func (d chatMessageDelegate) Render(w io.Writer, m list.Model, index int, item l
 	if !ok {
 		return
 	}
-	var content string
+	content := chatItem.Msg.DisplayText()
 	if !chatItem.Msg.IsUser {
-		rendered, err := glamour.Render(chatItem.Msg.Content, "dark")
-		if err != nil {
-			content = chatItem.Msg.Content
-		} else {
+		if rendered, err := glamour.Render(content, "dark"); err == nil {
 			content = rendered
 		}
-	} else {
-		content = chatItem.Msg.Content
+		if block := chatItem.Msg.ReasoningBlock(d.State != nil && d.State.ShowReasoning); block != "" {
+			content = reasoningStyle.Render(block) + "\n" + content
+		}
 	}
 	prefix := "  "
 	if index == m.Index() {
@@ -85,7 +88,7 @@ func NewChatView(state *models.ChatViewState, themeMap render.ThemeMap, strategi
 	pg := paginator.New()
 	pg.PerPage = 10
 	hp := help.New()
-	delegate := chatMessageDelegate{ThemeMap: themeMap, Strategies: strategies}
+	delegate := chatMessageDelegate{State: state, ThemeMap: themeMap, Strategies: strategies}
 	l := list.New(items, delegate, 60, 20)
 	l.Title = state.ChatTitle
 	l.SetShowStatusBar(false)
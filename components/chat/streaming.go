@@ -1,16 +1,24 @@
 package chat
-// streaming.go - Per-chat streaming worker for OpenRouter API
+// streaming.go - Per-chat streaming worker backed by an ai.AIProvider
 // Handles streaming, cancellation, and updates to chat state.
 
 package chat
 
 import (
-	"bufio"
+	"aichat/errors"
+	"aichat/services/ai"
+	"aichat/services/ai/mcp"
+	"aichat/services/ai/tokens"
+	"aichat/services/ai/tools"
+	aitypes "aichat/services/ai/types"
+	"aichat/services/ai/usage"
 	"context"
-	"encoding/json"
-	"net/http"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
+	"time"
 )
 
 // StreamEventType represents the type of event sent from the worker
@@ -18,18 +26,30 @@ import (
 type StreamEventType int
 
 const (
-	StreamEventChunk  StreamEventType = iota // Streaming chunk received
-	StreamEventDone                          // Streaming finished
-	StreamEventCancel                        // Streaming cancelled
-	StreamEventError                         // Error occurred
+	StreamEventChunk   StreamEventType = iota // Streaming chunk received
+	StreamEventDone                           // Streaming finished
+	StreamEventCancel                         // Streaming cancelled
+	StreamEventError                          // Error occurred
+	StreamEventMessage                        // Tool call or tool result to record
+	StreamEventRetry                          // Request failed and will be retried
 )
 
 // StreamEvent is sent from the worker to the main thread
 // to update chat state.
 type StreamEvent struct {
-	Type    StreamEventType
-	Content string // For chunk
-	Err     error  // For error
+	Type      StreamEventType
+	Content   string                 // For chunk
+	Reasoning string                 // For chunk; thinking of a reasoning model, shown apart from the answer
+	Err       error                  // For error
+	Message   *aitypes.Message       // For message; chunks streamed before a tool call belong to it
+	Usage     *aitypes.Usage         // For done; priced usage of the exchange, nil if not reported
+	Metrics   *aitypes.StreamMetrics // For done; latency and throughput of the response
+	Retry     *errors.RetryNotice    // For retry
+
+	// For done; the provider and model that answered, which differ from the
+	// worker's under a fallback chain.
+	Provider string
+	Model    string
 }
 
 // StreamWorker manages streaming for a single chat
@@ -37,24 +57,53 @@ type StreamWorker struct {
 	ChatID      string
 	Model       string
 	APIKey      string
+	Provider    ai.AIProvider
+	Tools       *tools.Registry // Optional; tools offered to the model instead of those of running MCP servers
+	Context     *tokens.Manager // Optional; keeps requests within the context window
+	Recorder    usage.Recorder  // Optional; global usage log
+	KeyTitle    string          // Title of the API key in use, for usage reports
 	CancelFunc  context.CancelFunc
-	EventChan   chan StreamEvent // Worker → main thread
-	InputChan   chan string      // Main thread → worker (user message)
+	EventChan   chan StreamEvent     // Worker → main thread
+	InputChan   chan aitypes.Message // Main thread → worker (user message)
 	active      bool
 	activeMutex sync.Mutex
+
+	// stopped is closed by Stop. Once nobody reads EventChan any more, events
+	// are dropped instead of blocking the worker (and its open stream).
+	stopped chan struct{}
+
+	// genCancel aborts the in-flight generation without stopping the worker.
+	genCancel context.CancelFunc
+	genMutex  sync.Mutex
+
+	// history is the conversation sent with each request, params its
+	// sampling settings.
+	history      []aitypes.Message
+	params       aitypes.GenerationParams
+	historyMutex sync.Mutex
+
+	// retry is the pending retry of the current request, with when it fires.
+	retry      *errors.RetryNotice
+	retryAt    time.Time
+	retryMutex sync.Mutex
+
+	// meter times the current, or else the last, generation.
+	meter streamMeter
 }
 
 // StartStreamWorker starts a new streaming worker for a chat
-func StartStreamWorker(chatID, model, apiKey string) *StreamWorker {
+func StartStreamWorker(chatID, model, apiKey string, provider ai.AIProvider) *StreamWorker {
 	ctx, cancel := context.WithCancel(context.Background())
 	w := &StreamWorker{
 		ChatID:     chatID,
 		Model:      model,
 		APIKey:     apiKey,
+		Provider:   provider,
 		CancelFunc: cancel,
 		EventChan:  make(chan StreamEvent, 10),
-		InputChan:  make(chan string, 1),
+		InputChan:  make(chan aitypes.Message, 1),
 		active:     true,
+		stopped:    make(chan struct{}),
 	}
 	go w.run(ctx)
 	return w
@@ -65,11 +114,109 @@ func (w *StreamWorker) Stop() {
 	w.activeMutex.Lock()
 	if w.active {
 		w.CancelFunc()
+		close(w.stopped)
 		w.active = false
 	}
 	w.activeMutex.Unlock()
 }
 
+// Done is closed once the worker is stopped.
+func (w *StreamWorker) Done() <-chan struct{} {
+	return w.stopped
+}
+
+// emit sends an event to the main thread, giving up once the worker is
+// stopped so a full EventChan cannot block it forever.
+func (w *StreamWorker) emit(ev StreamEvent) {
+	select {
+	case w.EventChan <- ev:
+	case <-w.stopped:
+	}
+}
+
+// CancelGeneration aborts the response currently being streamed, if any.
+// The worker stays alive and accepts further messages.
+func (w *StreamWorker) CancelGeneration() bool {
+	w.genMutex.Lock()
+	defer w.genMutex.Unlock()
+	if w.genCancel == nil {
+		return false
+	}
+	w.genCancel()
+	w.genCancel = nil
+	return true
+}
+
+// Generating reports whether a response is currently being streamed.
+func (w *StreamWorker) Generating() bool {
+	w.genMutex.Lock()
+	defer w.genMutex.Unlock()
+	return w.genCancel != nil
+}
+
+// SetHistory replaces the conversation sent with the next request, e.g. with
+// the messages of a chat being reopened.
+func (w *StreamWorker) SetHistory(messages []aitypes.Message) {
+	w.historyMutex.Lock()
+	defer w.historyMutex.Unlock()
+	w.history = append([]aitypes.Message(nil), messages...)
+}
+
+// SetParams sets the sampling settings of the next requests, e.g. the chat's
+// settings merged over its model's defaults.
+func (w *StreamWorker) SetParams(params aitypes.GenerationParams) {
+	w.historyMutex.Lock()
+	defer w.historyMutex.Unlock()
+	w.params = params
+}
+
+// ContextUsage estimates the tokens the conversation plus pending (text the
+// user is still typing) will use, and the model's context window.
+func (w *StreamWorker) ContextUsage(pending string) (used, limit int) {
+	w.historyMutex.Lock()
+	messages := append([]aitypes.Message(nil), w.history...)
+	w.historyMutex.Unlock()
+	if pending != "" {
+		messages = append(messages, aitypes.Message{Role: "user", Content: pending})
+	}
+	manager := w.Context
+	if manager == nil {
+		manager = &tokens.Manager{}
+	}
+	return manager.Usage(w.Model, messages)
+}
+
+// RetryStatus describes a pending retry as a countdown, e.g. "retrying in 8s
+// (attempt 2/5)", or returns "" when none is pending.
+func (w *StreamWorker) RetryStatus() string {
+	w.retryMutex.Lock()
+	defer w.retryMutex.Unlock()
+	if w.retry == nil {
+		return ""
+	}
+	wait := time.Until(w.retryAt).Round(time.Second)
+	if wait < 0 {
+		wait = 0
+	}
+	return fmt.Sprintf("retrying in %s (attempt %d/%d)", wait, w.retry.Attempt, w.retry.MaxAttempts)
+}
+
+// Metrics returns the latency and throughput of the response being
+// streamed, live, or else of the last one; false before the first.
+func (w *StreamWorker) Metrics() (aitypes.StreamMetrics, bool) {
+	return w.meter.snapshot()
+}
+
+// setRetry records (or with nil clears) the pending retry.
+func (w *StreamWorker) setRetry(n *errors.RetryNotice) {
+	w.retryMutex.Lock()
+	defer w.retryMutex.Unlock()
+	w.retry = n
+	if n != nil {
+		w.retryAt = time.Now().Add(n.Delay)
+	}
+}
+
 // run is the main loop for the worker
 func (w *StreamWorker) run(ctx context.Context) {
 	for {
@@ -82,62 +229,132 @@ func (w *StreamWorker) run(ctx context.Context) {
 	}
 }
 
-// streamMessage streams a response from the OpenRouter API
-func (w *StreamWorker) streamMessage(ctx context.Context, userMsg string) {
-	url := "https://openrouter.ai/api/v1/chat/completions"
-	payload := map[string]interface{}{
-		"model":    w.Model,
-		"messages": []map[string]string{{"role": "user", "content": userMsg}},
-		"stream":   true,
-	}
-	body, _ := json.Marshal(payload)
-	req, _ := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(body)))
-	req.Header.Set("Authorization", "Bearer "+w.APIKey)
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		w.EventChan <- StreamEvent{Type: StreamEventError, Err: err}
-		return
+// streamMessage streams a response from the worker's provider
+func (w *StreamWorker) streamMessage(ctx context.Context, userMsg aitypes.Message) {
+	genCtx, cancel := context.WithCancel(ctx)
+	w.genMutex.Lock()
+	w.genCancel = cancel
+	w.genMutex.Unlock()
+	defer func() {
+		w.genMutex.Lock()
+		w.genCancel = nil
+		w.genMutex.Unlock()
+		w.setRetry(nil)
+		w.meter.finish()
+		cancel()
+	}()
+	genCtx = errors.WithRetryObserver(genCtx, func(n errors.RetryNotice) {
+		w.setRetry(&n)
+		w.emit(StreamEvent{Type: StreamEventRetry, Retry: &n})
+	})
+
+	w.historyMutex.Lock()
+	req := aitypes.ChatRequest{
+		Model:    w.Model,
+		Messages: append(append([]aitypes.Message(nil), w.history...), userMsg),
 	}
-	defer resp.Body.Close()
-	reader := bufio.NewReader(resp.Body)
-	var buffer strings.Builder
-	for {
-		select {
-		case <-ctx.Done():
-			w.EventChan <- StreamEvent{Type: StreamEventCancel}
+	w.params.Apply(&req)
+	w.historyMutex.Unlock()
+	if w.Context != nil {
+		fitted, err := w.Context.Fit(genCtx, req)
+		if err != nil {
+			w.finish(err)
 			return
-		default:
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				w.EventChan <- StreamEvent{Type: StreamEventError, Err: err}
-				return
-			}
-			line = strings.TrimSpace(line)
-			if strings.HasPrefix(line, "data: ") {
-				data := line[6:]
-				if data == "[DONE]" {
-					w.EventChan <- StreamEvent{Type: StreamEventDone}
-					return
-				}
-				var obj map[string]interface{}
-				if err := json.Unmarshal([]byte(data), &obj); err == nil {
-					choices, ok := obj["choices"].([]interface{})
-					if ok && len(choices) > 0 {
-						choice := choices[0].(map[string]interface{})
-						delta, ok := choice["delta"].(map[string]interface{})
-						if ok {
-							content, _ := delta["content"].(string)
-							if content != "" {
-								buffer.WriteString(content)
-								w.EventChan <- StreamEvent{Type: StreamEventChunk, Content: content}
-							}
-						}
-					}
-				}
+		}
+		req = fitted
+	}
+	// Trimmed (or summarized) history replaces the old one so the work is
+	// not repeated on the next message.
+	history := req.Messages
+	// Timing starts once the request is ready, so summarizing the history
+	// does not count as latency. Tool rounds are part of the response.
+	w.meter.begin()
+
+	var reply strings.Builder
+	var used *aitypes.Usage
+	answeredBy, model := w.Provider.Info().Name, w.Model
+	onChunk := func(chunk aitypes.StreamChunk) {
+		w.setRetry(nil)
+		if chunk.Provider != "" {
+			answeredBy = chunk.Provider
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			if used == nil {
+				used = &aitypes.Usage{}
 			}
+			used.Add(*chunk.Usage)
+			w.meter.report(chunk.Usage.CompletionTokens)
 		}
+		// Reasoning is shown but kept out of the history: providers expect
+		// only the answer back.
+		if chunk.Content != "" || chunk.Reasoning != "" {
+			reply.WriteString(chunk.Content)
+			w.meter.chunk(chunk.Content + chunk.Reasoning)
+			w.emit(StreamEvent{Type: StreamEventChunk, Content: chunk.Content, Reasoning: chunk.Reasoning})
+		}
+	}
+	registry := w.Tools
+	if registry == nil && ai.SupportsTools(w.Provider, w.Model) {
+		registry = mcp.ToolRegistry()
+	}
+	var err error
+	if registry != nil && registry.Len() > 0 {
+		err = tools.Run(genCtx, w.Provider, req, w.APIKey, registry, onChunk, func(msg aitypes.Message) {
+			history = append(history, msg)
+			reply.Reset()
+			w.emit(StreamEvent{Type: StreamEventMessage, Message: &msg})
+		})
+	} else {
+		err = w.Provider.StreamMessage(genCtx, req, w.APIKey, onChunk)
+	}
+	if reply.Len() > 0 {
+		history = append(history, aitypes.Message{Role: "assistant", Content: reply.String()})
 	}
+	w.historyMutex.Lock()
+	w.history = history
+	w.historyMutex.Unlock()
+	if used != nil {
+		used.Cost = usage.Cost(model, *used)
+		w.recordUsage(answeredBy, model, *used)
+	}
+	if err == nil {
+		metrics := w.meter.finish()
+		w.emit(StreamEvent{Type: StreamEventDone, Usage: used, Metrics: &metrics, Provider: answeredBy, Model: model})
+		return
+	}
+	w.finish(err)
 }
 
+// recordUsage adds an exchange to the global usage log. Failing to record
+// must not fail the chat, so errors are only logged.
+func (w *StreamWorker) recordUsage(provider, model string, u aitypes.Usage) {
+	if w.Recorder == nil {
+		return
+	}
+	record := usage.Record{
+		Time:     time.Now(),
+		Chat:     w.ChatID,
+		Model:    model,
+		Provider: provider,
+		APIKey:   w.KeyTitle,
+		Usage:    u,
+	}
+	if err := w.Recorder.Record(record); err != nil {
+		slog.Warn("Failed to record usage", "chat", w.ChatID, "error", err)
+	}
+}
+
+// finish reports how a generation ended.
+func (w *StreamWorker) finish(err error) {
+	switch {
+	case stderrors.Is(err, context.Canceled):
+		w.emit(StreamEvent{Type: StreamEventCancel})
+	case err != nil:
+		w.emit(StreamEvent{Type: StreamEventError, Err: err})
+	default:
+		w.emit(StreamEvent{Type: StreamEventDone})
+	}
+}
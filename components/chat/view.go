@@ -4,9 +4,16 @@ package chat
 import (
 	"aichat/models"
 	"strings"
+
+	"github.com/charmbracelet/lipgloss"
 )
 
-type ChatView struct{}
+// reasoningStyle dims the thinking of reasoning models so the answer stands out.
+var reasoningStyle = lipgloss.NewStyle().Faint(true)
+
+type ChatView struct {
+	ShowReasoning bool // Expand the thinking shown above answers
+}
 
 func NewChatView() *ChatView {
 	return &ChatView{}
@@ -18,12 +25,19 @@ func (v *ChatView) Render(model models.IChatModel) string {
 	var sb strings.Builder
 	sb.WriteString("[Chat]\n")
 	for _, msg := range messages {
-		if msg.IsUser {
+		switch {
+		case msg.IsUser:
 			sb.WriteString("You: ")
-		} else {
+		case msg.ToolName != "":
+			sb.WriteString("Tool: ")
+		default:
 			sb.WriteString("AI: ")
+			if block := msg.ReasoningBlock(v.ShowReasoning); block != "" {
+				sb.WriteString(reasoningStyle.Render(block))
+				sb.WriteString("\n")
+			}
 		}
-		sb.WriteString(msg.Content)
+		sb.WriteString(msg.DisplayText())
 		sb.WriteString("\n")
 	}
 	return sb.String()
@@ -5,8 +5,14 @@ package input
 package input
 
 import (
+	"aichat/services/ai/attachments"
+	"aichat/services/ai/tokens"
+	aitypes "aichat/services/ai/types"
 	"aichat/types"
 	"aichat/types/render"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/atotto/clipboard"
@@ -22,6 +28,75 @@ type InputModel struct {
 	Controls types.ControlSet
 	ThemeMap render.ThemeMap
 	Strategy render.RenderStrategy
+
+	// Attachments are sent with the next message; AttachmentDir is where
+	// pasted images are saved (defaults to a temp directory).
+	Attachments   []aitypes.Attachment
+	AttachmentDir string
+
+	// ContextTokens is what the conversation plus the typed text costs and
+	// TokenLimit the model's context window; when set, View shows a live
+	// usage gauge.
+	ContextTokens int
+	TokenLimit    int
+}
+
+// SetTokenUsage updates the gauge with the tokens of the conversation plus
+// the typed text, and the model's limit (see StreamWorker.ContextUsage).
+// Call it whenever the input changes.
+func (m *InputModel) SetTokenUsage(used, limit int) {
+	m.ContextTokens, m.TokenLimit = used, limit
+}
+
+// tokenGauge renders "used/limit tokens", counting pending attachments as
+// well.
+func (m *InputModel) tokenGauge() string {
+	used := m.ContextTokens
+	if len(m.Attachments) > 0 {
+		// The typed text is counted in ContextTokens, with its message.
+		attached := aitypes.Message{Role: "user", Attachments: m.Attachments}
+		used += tokens.CountMessage(tokens.DefaultTokenizer, attached) - tokens.CountMessage(tokens.DefaultTokenizer, aitypes.Message{Role: "user"})
+	}
+	gauge := fmt.Sprintf(" [%d/%d tokens]", used, m.TokenLimit)
+	if used > m.TokenLimit {
+		gauge += " (over limit, older messages will be trimmed)"
+	}
+	return gauge
+}
+
+// attachCommand is typed before a file path to attach it instead of sending.
+const attachCommand = "/attach "
+
+// Attach adds the file at path to the pending attachments.
+func (m *InputModel) Attach(path string) error {
+	a, err := attachments.FromFile(path)
+	if err != nil {
+		return err
+	}
+	m.Attachments = append(m.Attachments, a)
+	return nil
+}
+
+// AttachFromClipboard adds the image or file path held by the clipboard.
+func (m *InputModel) AttachFromClipboard() error {
+	dir := m.AttachmentDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "aichat-attachments")
+	}
+	a, err := attachments.FromClipboard(dir)
+	if err != nil {
+		return err
+	}
+	m.Attachments = append(m.Attachments, a)
+	return nil
+}
+
+// TakeAttachments returns the pending attachments and clears them; call it
+// when the message is sent.
+func (m *InputModel) TakeAttachments() []aitypes.Attachment {
+	out := m.Attachments
+	m.Attachments = nil
+	return out
 }
 
 // Init initializes the input model (Bubble Tea compatibility).
@@ -67,6 +142,14 @@ func (m *InputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "ctrl+i":
 			m.Focused = true
 			return m, nil
+		case "alt+v":
+			// Attach an image or file from the clipboard
+			if err := m.AttachFromClipboard(); err != nil {
+				m.Message = " (" + err.Error() + ")"
+			} else {
+				m.Message = ""
+			}
+			return m, nil
 		case "enter":
 			if keyMsg.String() == "shift+enter" || keyMsg.String() == "alt+enter" {
 				// Shift+Enter or Alt+Enter: insert newline
@@ -74,6 +157,14 @@ func (m *InputModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.Cursor++
 				return m, nil
 			}
+			if strings.HasPrefix(m.Buffer, attachCommand) {
+				if err := m.Attach(strings.TrimSpace(strings.TrimPrefix(m.Buffer, attachCommand))); err != nil {
+					m.Message = " (" + err.Error() + ")"
+				} else {
+					m.Buffer, m.Cursor, m.Message = "", 0, ""
+				}
+				return m, nil
+			}
 			// Enter: submit (handled by parent)
 			return m, tea.Quit
 		case "backspace":
@@ -116,6 +207,12 @@ func (m *InputModel) View() string {
 		input = input[:m.Cursor] + "|" + input[m.Cursor:]
 	}
 	content := "Input: " + strings.ReplaceAll(input, "\n", "\\n") + m.Message
+	if n := len(m.Attachments); n > 0 {
+		content += fmt.Sprintf(" [%d attachment(s)]", n)
+	}
+	if m.TokenLimit > 0 {
+		content += m.tokenGauge()
+	}
 	if m.ThemeMap != nil {
 		theme := m.ThemeMap[m.Strategy.ThemeKey]
 		return render.ApplyStrategy(content, m.Strategy, theme)
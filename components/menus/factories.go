@@ -4,12 +4,17 @@ package menus
 import (
 	"aichat/types"
 	"aichat/interfaces"
+	"aichat/services/ai"
+	"aichat/services/ai/structured"
+	aitypes "aichat/services/ai/types"
+	"aichat/services/storage/repositories"
 	"aichat/types"
-	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
-	"net/http"
-	"os"
+	"net/url"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -313,41 +318,28 @@ Example input:
 Color 1: ` + g.colors[0] + `  
 Color 2: ` + g.colors[1] + `
 `
-	// TODO: Get active key, endpoint, and default model
-	apiKey := os.Getenv("API_KEY")        // Replace with actual key retrieval
-	endpoint := os.Getenv("API_ENDPOINT") // Replace with actual endpoint retrieval
-	model := "gpt-3.5-turbo"              // Replace with actual model retrieval
-	if apiKey == "" || endpoint == "" || model == "" {
-		g.errorMsg = "Missing API key, endpoint, or model."
-		g.step = -1
-		return
-	}
-	// Prepare request
-	body := map[string]interface{}{
-		"model":    model,
-		"messages": []map[string]string{{"role": "system", "content": prompt}},
-	}
-	jsonBody, _ := json.Marshal(body)
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonBody))
+	provider, apiKey, model, err := activeChatTarget()
 	if err != nil {
-		g.errorMsg = "Failed to create API request."
+		g.errorMsg = err.Error()
 		g.step = -1
 		return
 	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	reqCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	req := aitypes.ChatRequest{
+		Model:    model,
+		Messages: []aitypes.Message{{Role: "system", Content: prompt}},
+	}
+	// The reply is checked against the schema and the model is reprompted
+	// with the problem until it matches.
+	data, err := structured.Generate(reqCtx, provider, req, apiKey, aitypes.ResponseFormat{Name: "theme", Schema: themeSchema})
 	if err != nil {
-		g.errorMsg = "API request failed."
+		g.errorMsg = "Failed to generate theme: " + err.Error()
 		g.step = -1
 		return
 	}
-	defer resp.Body.Close()
-	respBody, _ := ioutil.ReadAll(resp.Body)
-	// Parse response (assume response is just the JSON theme)
 	var theme map[string]interface{}
-	if err := json.Unmarshal(respBody, &theme); err != nil {
+	if err := json.Unmarshal(data, &theme); err != nil {
 		g.errorMsg = "Failed to parse theme from API response."
 		g.step = -1
 		return
@@ -356,6 +348,84 @@ Color 2: ` + g.colors[1] + `
 	g.step = 3
 }
 
+// themeSchema is the JSON Schema of a generated theme: every color a hex code.
+var themeSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "name": {"type": "string"},
+    "modal": {
+      "type": "object",
+      "properties": {
+        "error": ` + themeColorsSchema + `,
+        "input": ` + themeColorsSchema + `,
+        "notice": ` + themeColorsSchema + `
+      },
+      "required": ["error", "input", "notice"]
+    },
+    "menu": ` + themeColorsSchema + `,
+    "window": {
+      "type": "object",
+      "properties": {
+        "focusedBorder": ` + hexColorSchema + `,
+        "unfocusedBorder": ` + hexColorSchema + `
+      },
+      "required": ["focusedBorder", "unfocusedBorder"]
+    },
+    "appTextColor": ` + hexColorSchema + `
+  },
+  "required": ["name", "modal", "menu", "window", "appTextColor"]
+}`)
+
+const hexColorSchema = `{"type": "string", "pattern": "^#[0-9A-Fa-f]{6}$"}`
+
+const themeColorsSchema = `{
+  "type": "object",
+  "properties": {
+    "textColor": ` + hexColorSchema + `,
+    "highlightTextColor": ` + hexColorSchema + `,
+    "windowBorder": ` + hexColorSchema + `
+  },
+  "required": ["textColor", "highlightTextColor", "windowBorder"]
+}`
+
+// activeChatTarget returns the provider serving the active API key, the key
+// and the default model.
+func activeChatTarget() (ai.AIProvider, string, string, error) {
+	keys, err := repositories.NewAPIKeyRepository().GetAll()
+	if err != nil {
+		return nil, "", "", err
+	}
+	var active *types.APIKey
+	for i := range keys {
+		if keys[i].Active {
+			active = &keys[i]
+			break
+		}
+	}
+	if active == nil {
+		return nil, "", "", fmt.Errorf("no active API key; set one in Settings")
+	}
+	keyURL, err := url.Parse(active.URL)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("API key %q has an invalid URL", active.Title)
+	}
+	var provider ai.AIProvider
+	for _, p := range ai.GetAllProviders() {
+		if endpoint, err := url.Parse(p.Info().Endpoint); err == nil && endpoint.Host == keyURL.Host {
+			provider = p
+			break
+		}
+	}
+	if provider == nil {
+		return nil, "", "", fmt.Errorf("no provider serves %s", keyURL.Host)
+	}
+	model, err := repositories.NewCachedModelRepository().GetDefault()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("no default model; set one in Models")
+	}
+	return provider, active.Key, model.Name, nil
+}
+
 func (g *GenerateThemeFlowState) saveTheme() {
 	// Load existing themes
 	data, err := ioutil.ReadFile(".config/themes.json")
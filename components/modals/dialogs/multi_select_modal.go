@@ -0,0 +1,125 @@
+package dialogs
+
+// multi_select_modal.go - Contains the MultiSelectModal for choosing several
+// options of a list at once.
+
+import (
+	"aichat/components/modals"
+	"aichat/interfaces"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// MultiSelectModal is a list whose options are toggled with space and
+// confirmed together with enter.
+type MultiSelectModal struct {
+	modals.BaseModal
+	Title     string
+	Items     []string
+	Checked   []bool
+	MinChosen int // Fewest options enter accepts
+	Error     string
+	OnConfirm func(indices []int)
+}
+
+// NewMultiSelectModal creates a modal with nothing checked. Enter is refused
+// until at least minChosen options are checked.
+func NewMultiSelectModal(title string, items []string, minChosen int, onConfirm func([]int), closeSelf modals.CloseSelfFunc, config modals.ModalRenderConfig) *MultiSelectModal {
+	return &MultiSelectModal{
+		BaseModal: modals.BaseModal{
+			ModalRenderConfig: config,
+			CloseSelf:         closeSelf,
+			RegionWidth:       DefaultListModalWidth,
+			RegionHeight:      DefaultListModalHeight,
+		},
+		Title:     title,
+		Items:     items,
+		Checked:   make([]bool, len(items)),
+		MinChosen: minChosen,
+		OnConfirm: onConfirm,
+	}
+}
+
+// Chosen returns the indices of the checked options in list order.
+func (m *MultiSelectModal) Chosen() []int {
+	var indices []int
+	for i, checked := range m.Checked {
+		if checked {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// Init (Bubble Tea compatibility)
+func (m *MultiSelectModal) Init() tea.Cmd { return nil }
+
+// Update handles up/down to move, space to toggle, enter to confirm and esc
+// to close without choosing.
+func (m *MultiSelectModal) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok || len(m.Items) == 0 {
+		return m, nil
+	}
+	switch keyMsg.Type {
+	case tea.KeyUp:
+		m.Selected = (m.Selected + len(m.Items) - 1) % len(m.Items)
+	case tea.KeyDown:
+		m.Selected = (m.Selected + 1) % len(m.Items)
+	case tea.KeySpace:
+		m.Checked[m.Selected] = !m.Checked[m.Selected]
+		m.Error = ""
+	case tea.KeyEnter:
+		chosen := m.Chosen()
+		if len(chosen) < m.MinChosen {
+			m.Error = fmt.Sprintf("Choose at least %d", m.MinChosen)
+			return m, nil
+		}
+		if m.OnConfirm != nil {
+			m.OnConfirm(chosen)
+		}
+		if m.CloseSelf != nil {
+			m.CloseSelf()
+		}
+	case tea.KeyEsc:
+		if m.CloseSelf != nil {
+			m.CloseSelf()
+		}
+	}
+	return m, nil
+}
+
+// UpdateWithContext is a stub for context-aware update logic.
+func (m *MultiSelectModal) UpdateWithContext(msg tea.Msg, ctx interfaces.Context, nav interfaces.Controller) (tea.Model, tea.Cmd) {
+	return m.Update(msg)
+}
+
+func (m *MultiSelectModal) View() string {
+	var b strings.Builder
+	b.WriteString(m.Title + "\n\n")
+	for i, item := range m.Items {
+		cursor := "  "
+		if i == m.Selected {
+			cursor = "> "
+		}
+		box := "[ ]"
+		if m.Checked[i] {
+			box = "[x]"
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", cursor, box, item)
+	}
+	if m.Error != "" {
+		b.WriteString("\n" + m.Error + "\n")
+	}
+	b.WriteString("\n↑↓: Move | Space: Toggle | Enter: Confirm | Esc: Cancel")
+	return m.RenderContentWithStrategy(b.String(), "modalBox")
+}
+
+// Add ViewState compliance methods
+func (m *MultiSelectModal) IsMainMenu() bool                 { return false }
+func (m *MultiSelectModal) MarshalState() ([]byte, error)    { return nil, nil }
+func (m *MultiSelectModal) UnmarshalState(data []byte) error { return nil }
+func (m *MultiSelectModal) ViewType() interfaces.ViewType    { return interfaces.ModalStateType }
+func (m *MultiSelectModal) Type() interfaces.ViewType        { return interfaces.ModalStateType }
@@ -0,0 +1,181 @@
+package dialogs
+
+// params_modal.go - Contains the GenerationParamsModal for editing sampling settings
+// (temperature, top_p, max tokens, stop sequences, thinking budget) of a chat or a model.
+
+import (
+	"aichat/components/modals"
+	"aichat/interfaces"
+	aitypes "aichat/services/ai/types"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Field order of the GenerationParamsModal.
+const (
+	paramTemperature = iota
+	paramTopP
+	paramMaxTokens
+	paramStop
+	paramThinking
+	paramCount
+)
+
+// minThinkingBudget is the smallest thinking budget providers accept.
+const minThinkingBudget = 1024
+
+var paramLabels = [paramCount]string{"Temperature", "Top P", "Max tokens", "Stop (comma-separated)", "Thinking budget"}
+
+// GenerationParamsModal edits sampling settings. Empty fields keep the
+// provider's (or the model's) default.
+type GenerationParamsModal struct {
+	modals.BaseModal
+	Title  string
+	Values [paramCount]string
+	Error  string
+	OnSave func(params aitypes.GenerationParams)
+}
+
+// NewGenerationParamsModal creates a modal pre-filled with params.
+func NewGenerationParamsModal(title string, params aitypes.GenerationParams, onSave func(aitypes.GenerationParams), closeSelf modals.CloseSelfFunc, config modals.ModalRenderConfig) *GenerationParamsModal {
+	m := &GenerationParamsModal{
+		BaseModal: modals.BaseModal{
+			ModalRenderConfig: config,
+			CloseSelf:         closeSelf,
+			RegionWidth:       DefaultConfirmationModalWidth,
+			RegionHeight:      DefaultConfirmationModalHeight,
+		},
+		Title:  title,
+		OnSave: onSave,
+	}
+	if params.Temperature != nil {
+		m.Values[paramTemperature] = strconv.FormatFloat(*params.Temperature, 'g', -1, 64)
+	}
+	if params.TopP != nil {
+		m.Values[paramTopP] = strconv.FormatFloat(*params.TopP, 'g', -1, 64)
+	}
+	if params.MaxTokens > 0 {
+		m.Values[paramMaxTokens] = strconv.Itoa(params.MaxTokens)
+	}
+	m.Values[paramStop] = strings.Join(params.Stop, ", ")
+	if params.ThinkingBudget > 0 {
+		m.Values[paramThinking] = strconv.Itoa(params.ThinkingBudget)
+	}
+	return m
+}
+
+// Params parses the fields, reporting the first invalid one.
+func (m *GenerationParamsModal) Params() (aitypes.GenerationParams, error) {
+	var p aitypes.GenerationParams
+	if v := strings.TrimSpace(m.Values[paramTemperature]); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 || t > 2 {
+			return p, fmt.Errorf("temperature must be a number from 0 to 2")
+		}
+		p.Temperature = &t
+	}
+	if v := strings.TrimSpace(m.Values[paramTopP]); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 || t > 1 {
+			return p, fmt.Errorf("top P must be a number above 0, up to 1")
+		}
+		p.TopP = &t
+	}
+	if v := strings.TrimSpace(m.Values[paramMaxTokens]); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("max tokens must be a positive whole number")
+		}
+		p.MaxTokens = n
+	}
+	for _, s := range strings.Split(m.Values[paramStop], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			p.Stop = append(p.Stop, s)
+		}
+	}
+	if v := strings.TrimSpace(m.Values[paramThinking]); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minThinkingBudget {
+			return p, fmt.Errorf("thinking budget must be a whole number of tokens, at least %d", minThinkingBudget)
+		}
+		p.ThinkingBudget = n
+	}
+	return p, nil
+}
+
+// Init (Bubble Tea compatibility)
+func (m *GenerationParamsModal) Init() tea.Cmd { return nil }
+
+// Update handles up/down to pick a field, typing and backspace to edit it,
+// enter to save and esc to close without saving.
+func (m *GenerationParamsModal) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch keyMsg.Type {
+	case tea.KeyUp, tea.KeyShiftTab:
+		m.Selected = (m.Selected + paramCount - 1) % paramCount
+	case tea.KeyDown, tea.KeyTab:
+		m.Selected = (m.Selected + 1) % paramCount
+	case tea.KeyBackspace:
+		if v := []rune(m.Values[m.Selected]); len(v) > 0 {
+			m.Values[m.Selected] = string(v[:len(v)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.Values[m.Selected] += string(keyMsg.Runes)
+	case tea.KeyEnter:
+		params, err := m.Params()
+		if err != nil {
+			m.Error = err.Error()
+			return m, nil
+		}
+		if m.OnSave != nil {
+			m.OnSave(params)
+		}
+		if m.CloseSelf != nil {
+			m.CloseSelf()
+		}
+	case tea.KeyEsc:
+		if m.CloseSelf != nil {
+			m.CloseSelf()
+		}
+	}
+	return m, nil
+}
+
+// UpdateWithContext is a stub for context-aware update logic.
+func (m *GenerationParamsModal) UpdateWithContext(msg tea.Msg, ctx interfaces.Context, nav interfaces.Controller) (tea.Model, tea.Cmd) {
+	return m.Update(msg)
+}
+
+func (m *GenerationParamsModal) View() string {
+	var b strings.Builder
+	b.WriteString(m.Title + "\n\n")
+	for i, label := range paramLabels {
+		cursor := "  "
+		if i == m.Selected {
+			cursor = "> "
+		}
+		value := m.Values[i]
+		if value == "" && i != m.Selected {
+			value = "(default)"
+		}
+		fmt.Fprintf(&b, "%s%-24s %s\n", cursor, label+":", value)
+	}
+	if m.Error != "" {
+		b.WriteString("\n" + m.Error + "\n")
+	}
+	b.WriteString("\n↑↓: Field | Enter: Save | Esc: Cancel")
+	return m.RenderContentWithStrategy(b.String(), "modalBox")
+}
+
+// Add ViewState compliance methods
+func (m *GenerationParamsModal) IsMainMenu() bool                 { return false }
+func (m *GenerationParamsModal) MarshalState() ([]byte, error)    { return nil, nil }
+func (m *GenerationParamsModal) UnmarshalState(data []byte) error { return nil }
+func (m *GenerationParamsModal) ViewType() interfaces.ViewType    { return interfaces.ModalStateType }
+func (m *GenerationParamsModal) Type() interfaces.ViewType        { return interfaces.ModalStateType }
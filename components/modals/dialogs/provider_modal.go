@@ -0,0 +1,238 @@
+package dialogs
+
+// provider_modal.go - Contains the ProviderModal for writing a provider
+// definition: type, base URL, auth scheme, default headers and capabilities.
+
+import (
+	"aichat/components/modals"
+	"aichat/interfaces"
+	aitypes "aichat/services/ai/types"
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Field order of the ProviderModal.
+const (
+	providerName = iota
+	providerType
+	providerBaseURL
+	providerAuth
+	providerAuthName
+	providerHeaders
+	providerCapabilities
+	providerAPIKey
+	providerFieldCount
+)
+
+var providerLabels = [providerFieldCount]string{
+	"Name",
+	"Type",
+	"Base URL",
+	"Auth (bearer/header/query/none)",
+	"Auth header or parameter",
+	"Headers (Name: value, ...)",
+	"Capabilities (streaming, tools, vision, embeddings, stream_usage)",
+	"API key (optional; empty keeps the stored one)",
+}
+
+// capabilityNames are the capabilities in the order of aitypes.Capabilities.
+var capabilityNames = []string{"streaming", "tools", "vision", "embeddings", "stream_usage"}
+
+// ProviderModal edits a provider definition. OnSave validates it; a failure
+// is shown and the modal stays open.
+type ProviderModal struct {
+	modals.BaseModal
+	Title  string
+	Types  []string // Known types, listed as a hint
+	Values [providerFieldCount]string
+	Error  string
+	OnSave func(def aitypes.ProviderInfo) error
+
+	keyRef string // Where the definition's key is stored, kept on save
+}
+
+// NewProviderModal creates a modal pre-filled with def.
+func NewProviderModal(title string, def aitypes.ProviderInfo, types []string, onSave func(aitypes.ProviderInfo) error, closeSelf modals.CloseSelfFunc, config modals.ModalRenderConfig) *ProviderModal {
+	m := &ProviderModal{
+		BaseModal: modals.BaseModal{
+			ModalRenderConfig: config,
+			CloseSelf:         closeSelf,
+			RegionWidth:       DefaultConfirmationModalWidth,
+			RegionHeight:      DefaultConfirmationModalHeight,
+		},
+		Title:  title,
+		Types:  types,
+		OnSave: onSave,
+	}
+	m.Values[providerName] = def.Name
+	m.Values[providerType] = def.Type
+	m.Values[providerBaseURL] = def.BaseURL
+	m.Values[providerAuth] = string(def.Auth)
+	m.Values[providerAuthName] = def.AuthHeader
+	if def.Auth == aitypes.AuthQuery {
+		m.Values[providerAuthName] = def.AuthParam
+	}
+	var headers []string
+	for k, v := range def.Headers {
+		headers = append(headers, k+": "+v)
+	}
+	sort.Strings(headers)
+	m.Values[providerHeaders] = strings.Join(headers, ", ")
+	if c := def.Capabilities; c != nil {
+		var caps []string
+		streaming := c.Streaming != nil && *c.Streaming
+		for i, on := range []bool{streaming, c.Tools, c.Vision, c.Embeddings, c.StreamUsage} {
+			if on {
+				caps = append(caps, capabilityNames[i])
+			}
+		}
+		m.Values[providerCapabilities] = strings.Join(caps, ", ")
+	}
+	// A stored key is not shown; leaving the field empty keeps it.
+	m.Values[providerAPIKey] = def.APIKey
+	m.keyRef = def.KeyRef
+	return m
+}
+
+// Definition parses the fields, reporting the first invalid one. Empty
+// capabilities leave them to the type.
+func (m *ProviderModal) Definition() (aitypes.ProviderInfo, error) {
+	def := aitypes.ProviderInfo{
+		Name:    strings.TrimSpace(m.Values[providerName]),
+		Type:    strings.TrimSpace(m.Values[providerType]),
+		BaseURL: strings.TrimSpace(m.Values[providerBaseURL]),
+		Auth:    aitypes.AuthScheme(strings.ToLower(strings.TrimSpace(m.Values[providerAuth]))),
+		APIKey:  strings.TrimSpace(m.Values[providerAPIKey]),
+		KeyRef:  m.keyRef,
+	}
+	if def.Name == "" || def.Type == "" {
+		return def, fmt.Errorf("a name and a type are required")
+	}
+	if name := strings.TrimSpace(m.Values[providerAuthName]); def.Auth == aitypes.AuthQuery {
+		def.AuthParam = name
+	} else {
+		def.AuthHeader = name
+	}
+	for _, h := range strings.Split(m.Values[providerHeaders], ",") {
+		if h = strings.TrimSpace(h); h == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			return def, fmt.Errorf("headers must be written as Name: value")
+		}
+		if def.Headers == nil {
+			def.Headers = map[string]string{}
+		}
+		def.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	if caps := strings.TrimSpace(m.Values[providerCapabilities]); caps != "" {
+		def.Capabilities = &aitypes.Capabilities{}
+		for _, c := range strings.Split(caps, ",") {
+			switch strings.ToLower(strings.TrimSpace(c)) {
+			case "streaming":
+				on := true
+				def.Capabilities.Streaming = &on
+			case "tools":
+				def.Capabilities.Tools = true
+			case "vision":
+				def.Capabilities.Vision = true
+			case "embeddings":
+				def.Capabilities.Embeddings = true
+			case "stream_usage":
+				def.Capabilities.StreamUsage = true
+			case "":
+			default:
+				return def, fmt.Errorf("unknown capability %q", strings.TrimSpace(c))
+			}
+		}
+		if def.Capabilities.Streaming != nil {
+			def.Stream = true
+		}
+	}
+	return def, nil
+}
+
+// Init (Bubble Tea compatibility)
+func (m *ProviderModal) Init() tea.Cmd { return nil }
+
+// Update handles up/down to pick a field, typing and backspace to edit it,
+// enter to save and esc to close without saving.
+func (m *ProviderModal) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch keyMsg.Type {
+	case tea.KeyUp, tea.KeyShiftTab:
+		m.Selected = (m.Selected + providerFieldCount - 1) % providerFieldCount
+	case tea.KeyDown, tea.KeyTab:
+		m.Selected = (m.Selected + 1) % providerFieldCount
+	case tea.KeyBackspace:
+		if v := []rune(m.Values[m.Selected]); len(v) > 0 {
+			m.Values[m.Selected] = string(v[:len(v)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.Values[m.Selected] += string(keyMsg.Runes)
+	case tea.KeyEnter:
+		def, err := m.Definition()
+		if err == nil && m.OnSave != nil {
+			err = m.OnSave(def)
+		}
+		if err != nil {
+			m.Error = err.Error()
+			return m, nil
+		}
+		if m.CloseSelf != nil {
+			m.CloseSelf()
+		}
+	case tea.KeyEsc:
+		if m.CloseSelf != nil {
+			m.CloseSelf()
+		}
+	}
+	return m, nil
+}
+
+// UpdateWithContext is a stub for context-aware update logic.
+func (m *ProviderModal) UpdateWithContext(msg tea.Msg, ctx interfaces.Context, nav interfaces.Controller) (tea.Model, tea.Cmd) {
+	return m.Update(msg)
+}
+
+func (m *ProviderModal) View() string {
+	var b strings.Builder
+	b.WriteString(m.Title + "\n\n")
+	width := 0
+	for _, label := range providerLabels {
+		width = max(width, len(label)+1)
+	}
+	for i, label := range providerLabels {
+		cursor := "  "
+		if i == m.Selected {
+			cursor = "> "
+		}
+		value := m.Values[i]
+		if i == providerAPIKey && value != "" {
+			value = strings.Repeat("*", len([]rune(value)))
+		}
+		fmt.Fprintf(&b, "%s%-*s %s\n", cursor, width, label+":", value)
+	}
+	if len(m.Types) > 0 {
+		b.WriteString("\nTypes: " + strings.Join(m.Types, ", ") + "\n")
+	}
+	if m.Error != "" {
+		b.WriteString("\n" + m.Error + "\n")
+	}
+	b.WriteString("\n↑↓: Field | Enter: Save | Esc: Cancel")
+	return m.RenderContentWithStrategy(b.String(), "modalBox")
+}
+
+// Add ViewState compliance methods
+func (m *ProviderModal) IsMainMenu() bool                 { return false }
+func (m *ProviderModal) MarshalState() ([]byte, error)    { return nil, nil }
+func (m *ProviderModal) UnmarshalState(data []byte) error { return nil }
+func (m *ProviderModal) ViewType() interfaces.ViewType    { return interfaces.ModalStateType }
+func (m *ProviderModal) Type() interfaces.ViewType        { return interfaces.ModalStateType }
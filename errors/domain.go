@@ -62,6 +62,12 @@ func (e *DomainError) Error() string {
 
 func (e *DomainError) Unwrap() error { return e.Cause }
 
+// RetryAfter returns the delay the server asked for before retrying, or 0.
+func (e *DomainError) RetryAfter() time.Duration {
+	d, _ := e.Details["retry_after"].(time.Duration)
+	return d
+}
+
 func (e *DomainError) Is(target error) bool {
 	t, ok := target.(*DomainError)
 	return ok && e.Type == t.Type && e.Code == t.Code
@@ -158,6 +164,62 @@ func NewAIServiceError(provider, op string, cause error) *DomainError {
 		Build()
 }
 
+// NewAIHTTPError reports a non-2xx response from an AI provider. Rate limits
+// (429), timeouts (408) and server errors (5xx) are retryable, after
+// retryAfter when the server suggested a delay (0 otherwise). A rejected key
+// (401/403) is an authentication error; other client errors are final.
+func NewAIHTTPError(provider string, status int, body string, retryAfter time.Duration) *DomainError {
+	switch {
+	case status == 401 || status == 403:
+		return NewError(AuthenticationError, "AI_AUTH_FAILED").
+			Message(fmt.Sprintf("%s rejected the API key (status %d): %s", provider, status, body)).
+			UserMessage("The API key was rejected. Check it in Settings.").
+			Detail("provider", provider).
+			Detail("status", status).
+			Build()
+	case status == 429:
+		return NewError(ExternalServiceError, "AI_RATE_LIMITED").
+			Message(fmt.Sprintf("%s rate limit hit: %s", provider, body)).
+			UserMessage("Rate limited by the AI service. Retrying shortly.").
+			Detail("provider", provider).
+			Detail("status", status).
+			Detail("retry_after", retryAfter).
+			Retryable(true).
+			Build()
+	}
+	return NewError(ExternalServiceError, "AI_HTTP_ERROR").
+		Message(fmt.Sprintf("%s returned status %d: %s", provider, status, body)).
+		UserMessage("AI service unavailable. Retry shortly.").
+		Detail("provider", provider).
+		Detail("status", status).
+		Detail("retry_after", retryAfter).
+		Retryable(status == 408 || status >= 500).
+		Build()
+}
+
+// NewAIStreamError reports an error payload received in the middle of a stream.
+func NewAIStreamError(provider, code, message string, retryable bool) *DomainError {
+	return NewError(ExternalServiceError, "AI_STREAM_ERROR").
+		Message(fmt.Sprintf("%s stream error: %s", provider, message)).
+		UserMessage("The AI service reported an error mid-response.").
+		Detail("provider", provider).
+		Detail("code", code).
+		Retryable(retryable).
+		Build()
+}
+
+// NewContextWindowError reports a request that cannot be trimmed to fit the
+// model's context window.
+func NewContextWindowError(model string, tokens, limit int) *DomainError {
+	return NewError(ValidationError, "CONTEXT_WINDOW_EXCEEDED").
+		Message(fmt.Sprintf("request needs ~%d tokens but %s allows %d", tokens, model, limit)).
+		UserMessage("The message is too long for this model. Shorten it or pick a larger model.").
+		Detail("model", model).
+		Detail("tokens", tokens).
+		Detail("limit", limit).
+		Build()
+}
+
 // Cache-specific errors
 func NewCacheError(operation string, cause error) *DomainError {
 	return NewError(CacheError, "CACHE_FAIL").
@@ -221,8 +283,41 @@ const (
 
 type RetryableOperation func() error
 
+// RetryNotice describes a retry about to be made, for progress display.
+type RetryNotice struct {
+	Attempt     int // The attempt about to be made, from 2
+	MaxAttempts int
+	Delay       time.Duration
+	Err         error // What the previous attempt failed with
+}
+
+type retryObserverKey struct{}
+
+type noRetryKey struct{}
+
+// WithRetryObserver returns a context whose Retry calls report each pending
+// retry to fn, e.g. to show "retrying in 8s (attempt 2/5)".
+func WithRetryObserver(ctx context.Context, fn func(RetryNotice)) context.Context {
+	return context.WithValue(ctx, retryObserverKey{}, fn)
+}
+
+// WithoutRetries returns a context whose Retry calls make a single attempt,
+// for callers with a retry policy of their own, such as a fallback chain
+// failing over to its next step.
+func WithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// Retry runs op until it succeeds, fails with a non-retryable DomainError or
+// runs out of attempts. A delay the server suggested (DomainError.RetryAfter)
+// is honored in place of the backoff, even beyond MaxDelay. Under
+// WithoutRetries op runs once.
 func Retry(ctx context.Context, cfg RetryConfig, op RetryableOperation) error {
 	var last error
+	observe, _ := ctx.Value(retryObserverKey{}).(func(RetryNotice))
+	if single, _ := ctx.Value(noRetryKey{}).(bool); single {
+		cfg.MaxAttempts = 1
+	}
 	for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
 		if err := op(); err != nil {
 			last = err
@@ -230,10 +325,16 @@ func Retry(ctx context.Context, cfg RetryConfig, op RetryableOperation) error {
 			if errors.As(err, &dErr) && !dErr.Retryable {
 				return err
 			}
-			if attempt == cfg.MaxAttempts {
+			if attempt == cfg.MaxAttempts || ctx.Err() != nil {
 				break
 			}
 			delay := cfg.calcDelay(attempt)
+			if dErr != nil && dErr.RetryAfter() > 0 {
+				delay = dErr.RetryAfter()
+			}
+			if observe != nil {
+				observe(RetryNotice{Attempt: attempt + 1, MaxAttempts: cfg.MaxAttempts, Delay: delay, Err: err})
+			}
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
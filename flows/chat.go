@@ -0,0 +1,113 @@
+package flows
+
+// chat.go - Opening a chat: the provider and model it streams from, and the
+// worker doing the streaming.
+
+import (
+	"aichat/components/chat"
+	"aichat/services/ai"
+	"aichat/services/ai/attachments"
+	"aichat/services/ai/tokens"
+	aitypes "aichat/services/ai/types"
+	"aichat/services/storage/repositories"
+	"aichat/types"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+// OpenChat loads the chat titled title and starts a worker streaming for it,
+// holding the chat's conversation and settings. The returned screen saves
+// the chat after every message; onClose is called when the user leaves it,
+// and must stop the worker.
+func OpenChat(title string, onClose func()) (*chat.ChatScreen, error) {
+	repo := repositories.NewChatRepository()
+	c, err := repo.GetByTitle(title)
+	if err != nil {
+		return nil, err
+	}
+	provider, model, key, err := chatTarget(c.Metadata.Model)
+	if err != nil {
+		return nil, err
+	}
+	params, err := repositories.NewModelParamsRepository().Get(model)
+	if err != nil {
+		log.Printf("loading model defaults: %v", err)
+	}
+	w := chat.StartStreamWorker(c.Metadata.Title, model, key.Key, provider)
+	history := make([]aitypes.Message, len(c.Messages))
+	for i, m := range c.Messages {
+		history[i] = m.ToAI()
+		attachments.SetSizes(history[i].Attachments)
+	}
+	w.SetHistory(history)
+	w.SetParams(c.GenerationParams(params))
+	w.Context = contextManager(provider, model, key.Key)
+	w.Recorder, w.KeyTitle = repositories.NewUsageRepository(), key.Title
+	save := func(updated *types.ChatFile) error { return repo.Save(*updated) }
+	return chat.NewChatScreen(c, w, save, onClose), nil
+}
+
+// contextManager keeps requests within the model's context window by the
+// strategy chosen in settings.ini; "summarize" has the chat's own model
+// write the summary.
+func contextManager(provider ai.AIProvider, model, apiKey string) *tokens.Manager {
+	strategy, keepTurns := types.GetContextSettings()
+	m := &tokens.Manager{Strategy: tokens.ParseStrategy(strategy), KeepTurns: keepTurns}
+	if m.Strategy == tokens.Summarize {
+		m.Summarize = tokens.ProviderSummarizer(provider, model, apiKey)
+	}
+	return m
+}
+
+// chatTarget picks who answers a chat using model: the provider listing it
+// in the model catalog, or else the provider serving the active API key,
+// with the default model when the chat names none. The key is the stored
+// one for the provider's host, empty for providers needing none.
+func chatTarget(model string) (ai.AIProvider, string, types.APIKey, error) {
+	keys := repositories.NewAPIKeyRepository()
+	if model != "" {
+		if m, err := repositories.NewModelCatalogRepository().GetByID(model); err == nil {
+			if p := ai.GetProviderByName(m.Provider); p != nil {
+				key, _ := keys.ForEndpoint(p.Info().Endpoint)
+				return p, model, key, nil
+			}
+		}
+	}
+	all, err := keys.GetAll()
+	if err != nil {
+		return nil, "", types.APIKey{}, err
+	}
+	var active *types.APIKey
+	for i := range all {
+		if all[i].Active {
+			active = &all[i]
+			break
+		}
+	}
+	if active == nil {
+		return nil, "", types.APIKey{}, fmt.Errorf("no provider lists model %q and no API key is active; set one in Settings", model)
+	}
+	keyURL, err := url.Parse(active.URL)
+	if err != nil {
+		return nil, "", types.APIKey{}, fmt.Errorf("API key %q has an invalid URL", active.Title)
+	}
+	var provider ai.AIProvider
+	for _, p := range ai.GetAllProviders() {
+		if endpoint, err := url.Parse(p.Info().Endpoint); err == nil && endpoint.Host == keyURL.Host {
+			provider = p
+			break
+		}
+	}
+	if provider == nil {
+		return nil, "", types.APIKey{}, fmt.Errorf("no provider serves %s", keyURL.Host)
+	}
+	if model == "" {
+		m, err := repositories.NewCachedModelRepository().GetDefault()
+		if err != nil {
+			return nil, "", types.APIKey{}, fmt.Errorf("no default model; set one in Models")
+		}
+		model = m.Name
+	}
+	return provider, model, *active, nil
+}
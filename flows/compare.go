@@ -0,0 +1,131 @@
+package flows
+
+// compare.go - Compare mode: one prompt streamed to several models at once,
+// with the chosen answer kept in the chat.
+
+import (
+	"aichat/components/chat"
+	"aichat/components/modals"
+	"aichat/components/modals/dialogs"
+	"aichat/interfaces"
+	"aichat/services/ai"
+	aitypes "aichat/services/ai/types"
+	"aichat/services/storage/repositories"
+	"aichat/types"
+	"log"
+	"time"
+)
+
+// FlowCompareModels asks for a chat and the models to compare, then opens
+// the compare view on the chat's conversation. The prompt and the kept
+// answer are appended to the chat.
+func FlowCompareModels(nav interfaces.Controller) {
+	repo := repositories.NewChatRepository()
+	chats, err := repo.GetAll()
+	if err != nil {
+		log.Printf("loading chats: %v", err)
+		return
+	}
+	catalog, err := repositories.NewModelCatalogRepository().GetAll()
+	if err != nil {
+		log.Printf("loading model catalog: %v", err)
+		return
+	}
+	// Only models whose provider is registered can be streamed.
+	var models []types.Model
+	var names []string
+	for _, m := range catalog {
+		if ai.GetProviderByName(m.Provider) != nil {
+			models = append(models, *m)
+			names = append(names, m.Name+" ("+m.Provider+")")
+		}
+	}
+	switch {
+	case len(chats) == 0:
+		showNotice(nav, "Compare Models", "No chats yet; add one under Chats first")
+		return
+	case len(models) < 2:
+		showNotice(nav, "Compare Models", "Comparing needs at least two models in the catalog", "Refresh it under Models > Refresh Model Catalog")
+		return
+	}
+	titles := make([]string, len(chats))
+	for i, c := range chats {
+		titles[i] = c.Metadata.Title
+	}
+
+	chatIndex := -1
+	nav.Push(dialogs.NewListModalFactory("Compare in chat", titles, func(index int) { chatIndex = index }, func() {
+		nav.Pop()
+		if chatIndex < 0 {
+			return
+		}
+		var chosen []int
+		nav.Push(dialogs.NewMultiSelectModal("Models to compare", names, 2, func(indices []int) { chosen = indices }, func() {
+			nav.Pop()
+			if len(chosen) > 0 {
+				startCompare(nav, repo, chats, chatIndex, pick(models, chosen))
+			}
+		}, modals.ModalRenderConfig{}))
+	}, modals.ModalRenderConfig{}))
+}
+
+// startCompare opens the compare view for chats[index] and the given models.
+func startCompare(nav interfaces.Controller, repo *repositories.ChatRepository, chats []types.ChatFile, index int, models []types.Model) {
+	keys := repositories.NewAPIKeyRepository()
+	defaults := repositories.NewModelParamsRepository()
+	recorder := repositories.NewUsageRepository()
+	c := &chats[index]
+	targets := make([]chat.CompareTarget, len(models))
+	for i, m := range models {
+		provider := ai.GetProviderByName(m.Provider)
+		params, err := defaults.Get(m.Name)
+		if err != nil {
+			log.Printf("loading model defaults: %v", err)
+		}
+		key, _ := keys.ForEndpoint(provider.Info().Endpoint)
+		targets[i] = chat.CompareTarget{
+			Provider: provider,
+			Model:    m.Name,
+			APIKey:   key.Key,
+			Params:   c.GenerationParams(params),
+			Context:  contextManager(provider, m.Name, key.Key),
+			Recorder: recorder,
+			KeyTitle: key.Title,
+		}
+	}
+	history := make([]aitypes.Message, len(c.Messages))
+	for i, m := range c.Messages {
+		history[i] = m.ToAI()
+	}
+	session := chat.StartCompareSession(c.Metadata.Title, history, targets)
+	keep := func(column int) error {
+		prompt := session.Prompt.Content
+		next := len(c.Messages) + 1
+		kept := session.Keep(column, next+1)
+		if kept == nil {
+			return nil
+		}
+		c.Messages = append(c.Messages, types.Message{Role: "user", Content: prompt, MessageNumber: next})
+		c.Messages = append(c.Messages, kept...)
+		if u := kept[len(kept)-1].Usage; u != nil {
+			c.AddUsage(*u)
+		}
+		c.Metadata.ModifiedAt = time.Now().Unix()
+		return repo.SaveAll(chats)
+	}
+	nav.Push(chat.NewCompareView(session, c.Metadata.Title, keep, func() { nav.Pop() }))
+}
+
+// pick returns the models at the given indices.
+func pick(models []types.Model, indices []int) []types.Model {
+	out := make([]types.Model, len(indices))
+	for i, index := range indices {
+		out[i] = models[index]
+	}
+	return out
+}
+
+// showNotice shows lines in a list modal closed with enter or esc.
+func showNotice(nav interfaces.Controller, title string, lines ...string) {
+	nav.Push(dialogs.NewListModalFactory(title, lines, func(int) {}, func() { nav.Pop() }, modals.ModalRenderConfig{}))
+}
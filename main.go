@@ -3,7 +3,14 @@ package main
 
 import (
 	"aichat/types"
+	"aichat/services/ai"
+	"aichat/services/ai/catalog"
+	"aichat/services/ai/httplog"
+	"aichat/services/ai/mcp"
+	"aichat/services/ai/usage"
 	"aichat/services/storage"
+	"aichat/services/storage/repositories"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -13,12 +20,68 @@ import (
 )
 
 func main() {
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+	// The TUI owns the terminal, so the log goes to a file; stderr is only
+	// used when the file cannot be opened
+	logOut := io.Writer(os.Stderr)
+	if err := os.MkdirAll(".config/logs", 0700); err == nil {
+		if f, err := os.OpenFile(".config/logs/aichat.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err == nil {
+			defer f.Close()
+			logOut = f
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(types.GetLogLevel())); err != nil {
+		level = slog.LevelInfo
+	}
+	logger := slog.New(slog.NewTextHandler(logOut, &slog.HandlerOptions{
+		Level: level,
 	}))
 	slog.SetDefault(logger)
 	logger.Info("Starting AI CLI application", "version", "1.0.0")
 
+	// Chats, keys, models, settings and usage in JSON files or one SQLite
+	// database; the first SQLite open imports the JSON files. Opened before
+	// any repository is created, as each picks its storage from it
+	backend, err := storage.OpenBackend(types.GetStorageBackend(), "")
+	if err != nil {
+		logger.Warn("Failed to open storage backend, using JSON", "error", err)
+		backend, _ = storage.OpenBackend(storage.BackendJSON, "")
+	}
+	storage.SetCurrent(backend)
+	defer backend.Close()
+
+	// Optional per-model price overrides used for usage accounting
+	if err := usage.LoadPricing(".config/pricing.json"); err != nil {
+		logger.Warn("Failed to load pricing overrides", "error", err)
+	}
+	// Provider definitions: type, base URL, auth scheme, headers, capabilities.
+	// Their keys live with the other API keys, not in the providers file
+	keys := repositories.NewAPIKeyRepository()
+	ai.SetKeyStore(keys)
+	if err := ai.LoadProvidersFromJSON(".config/providers.json"); err != nil {
+		logger.Warn("Failed to load providers", "error", err)
+	}
+	// Named fallback chains, selectable like providers; each step gets the
+	// stored key matching its provider's host
+	if err := ai.LoadChainsFromJSON(".config/fallback_chains.json", func(p ai.AIProvider) string {
+		return keys.KeyForEndpoint(p.Info().Endpoint)
+	}); err != nil {
+		logger.Warn("Failed to load fallback chains", "error", err)
+	}
+	// Opt-in transcript of provider HTTP traffic, with keys redacted
+	if types.GetHTTPLogEnabled() {
+		if err := httplog.Enable(".config/logs"); err != nil {
+			logger.Warn("Failed to enable HTTP log", "error", err)
+		}
+	}
+	// MCP tool servers; their tools are offered to models, each call
+	// subject to the user's approval
+	if err := mcp.LoadServersFromJSON(".config/mcp_servers.json"); err != nil {
+		logger.Warn("Failed to load MCP servers", "error", err)
+	}
+	// Context windows and prices from the last model catalog refresh
+	catalog.LoadCached(repositories.NewModelCatalogRepository(), ai.GetAllProviders())
+
 	navStorage := storage.NewNavigationStorage(".config")
 	cfg := app.DefaultAppConfig()
 	appModel := app.NewUnifiedAppModel(cfg, navStorage, logger)
@@ -26,8 +89,12 @@ func main() {
 	program := tea.NewProgram(appModel, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	setupGracefulShutdown(program, logger)
 
-	if _, err := program.Run(); err != nil {
+	_, err = program.Run()
+	// Servers exit once their stdin closes; stop them before the app does
+	mcp.Shutdown()
+	if err != nil {
 		logger.Error("Application failed", "error", err)
+		backend.Close()
 		os.Exit(1)
 	}
 	logger.Info("Application completed successfully")
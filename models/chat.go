@@ -2,15 +2,78 @@ package models
 package models
 
 import (
+	aitypes "aichat/services/ai/types"
 	"aichat/types"
+	"fmt"
+	"strings"
 )
 
 // ChatMessage represents a single message in the chat
 // IsUser: true if sent by user, false if assistant
 // Content: message text
+// Attachments: files sent with the message
+// ToolCalls: tools the assistant asked to run
+// ToolName: set when the message is the result of a tool call
+// Reasoning: thinking of a reasoning model, shown above the answer
 type ChatMessage struct {
-	Content string
-	IsUser  bool
+	Content     string
+	IsUser      bool
+	Attachments []aitypes.Attachment
+	ToolCalls   []aitypes.ToolCall
+	ToolName    string
+	Reasoning   string
+}
+
+// ReasoningBlock returns the thinking to show above the answer: a one-line
+// summary while collapsed, the full text when expanded. It is empty when
+// the model did not think aloud.
+func (m ChatMessage) ReasoningBlock(expanded bool) string {
+	reasoning := strings.TrimSpace(m.Reasoning)
+	if reasoning == "" {
+		return ""
+	}
+	if !expanded {
+		return fmt.Sprintf("▸ Thinking (%d words)", len(strings.Fields(reasoning)))
+	}
+	return "▾ Thinking\n" + reasoning
+}
+
+// DisplayText returns the message text with attachments, tool calls and
+// results spelled out, so they show up in the transcript.
+func (m ChatMessage) DisplayText() string {
+	text := m.contentText()
+	if len(m.Attachments) == 0 {
+		return text
+	}
+	names := make([]string, len(m.Attachments))
+	for i, a := range m.Attachments {
+		names[i] = a.Name
+	}
+	indicator := fmt.Sprintf("[%d attachment(s): %s]", len(names), strings.Join(names, ", "))
+	if text == "" {
+		return indicator
+	}
+	return indicator + "\n" + text
+}
+
+// contentText renders the content with the tool calls or tool result of the
+// message.
+func (m ChatMessage) contentText() string {
+	if m.ToolName != "" {
+		return fmt.Sprintf("[tool result: %s]\n%s", m.ToolName, m.Content)
+	}
+	if len(m.ToolCalls) == 0 {
+		return m.Content
+	}
+	var sb strings.Builder
+	sb.WriteString(m.Content)
+	for _, call := range m.ToolCalls {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "[tool call: %s(%s)]", call.Name, call.Arguments)
+	}
+	return sb.String()
 }
 
 // IChatModel defines the data access/mutation interface for chat models.
@@ -29,6 +92,7 @@ type ChatViewState struct {
 	SelectedMessageIdx int
 	ResponseReceived   bool
 	WaitingForResponse bool
+	ShowReasoning      bool             // Expand the thinking of reasoning models
 	observers          []types.Observer // Observer pattern
 	// ThemeMap, Strategies, MessageList, Spinner, Paginator, Help, ShowHelp are UI-related and should be handled in the view/controller layer.
 }
@@ -65,6 +129,12 @@ func (c *ChatViewState) AddMessage(msg ChatMessage) {
 	c.NotifyObservers(types.Event{Type: "message_added", Payload: msg})
 }
 
+// ToggleReasoning expands or collapses the thinking shown above answers.
+func (c *ChatViewState) ToggleReasoning() {
+	c.ShowReasoning = !c.ShowReasoning
+	c.NotifyObservers(types.Event{Type: "reasoning_toggled", Payload: c.ShowReasoning})
+}
+
 // chatMessageItem wraps ChatMessage for Bubbles list.Model
 type ChatMessageItem struct {
 	Msg ChatMessage
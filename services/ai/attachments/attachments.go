@@ -0,0 +1,135 @@
+// Package attachments builds message attachments from files on disk and from
+// the clipboard.
+package attachments
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+)
+
+// MaxSize is the largest file accepted as an attachment. Providers cap
+// inline request payloads at around 20MB.
+const MaxSize = 20 << 20
+
+// FromFile returns an attachment referencing the file at path. The media
+// type comes from the extension, falling back to content sniffing.
+func FromFile(path string) (types.Attachment, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return types.Attachment{}, errors.NewStorageError("attach file", path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return types.Attachment{}, errors.NewStorageError("attach file", abs, err)
+	}
+	if info.IsDir() {
+		return types.Attachment{}, errors.NewValidationError("attachment", abs+" is a directory")
+	}
+	if info.Size() > MaxSize {
+		return types.Attachment{}, errors.NewValidationError("attachment", fmt.Sprintf("%s is larger than %d MB", filepath.Base(abs), MaxSize>>20))
+	}
+	mediaType := mime.TypeByExtension(filepath.Ext(abs))
+	if mediaType == "" {
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			return types.Attachment{}, errors.NewStorageError("attach file", abs, err)
+		}
+		mediaType = http.DetectContentType(data)
+	}
+	return newAttachment(mediaType, filepath.Base(abs), abs, info.Size()), nil
+}
+
+// FromClipboard attaches what the clipboard holds: an image (e.g. a
+// screenshot), a path to a file or a base64 data URL (as copied from
+// browsers). Images and data URLs are written into dir so the chat can keep
+// referencing them. Images are read through the platform's clipboard tool
+// (see imageCommands); without one only text is read.
+func FromClipboard(dir string) (types.Attachment, error) {
+	if data, mediaType, ok := readClipboardImage(); ok {
+		return saveClipboardData(dir, mediaType, data)
+	}
+	text, err := clipboard.ReadAll()
+	if err != nil {
+		return types.Attachment{}, errors.NewStorageError("read clipboard", "", err)
+	}
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "data:") {
+		if text == "" {
+			return types.Attachment{}, errors.NewValidationError("clipboard", "clipboard is empty")
+		}
+		return FromFile(text)
+	}
+	mediaType, data, err := decodeDataURL(text)
+	if err != nil {
+		return types.Attachment{}, errors.NewValidationError("clipboard", err.Error())
+	}
+	return saveClipboardData(dir, mediaType, data)
+}
+
+// saveClipboardData writes data copied from the clipboard into dir and
+// attaches the file.
+func saveClipboardData(dir, mediaType string, data []byte) (types.Attachment, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return types.Attachment{}, errors.NewStorageError("save clipboard attachment", dir, err)
+	}
+	ext := ".bin"
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	name := "clipboard-" + time.Now().Format("20060102-150405") + ext
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return types.Attachment{}, errors.NewStorageError("save clipboard attachment", path, err)
+	}
+	return newAttachment(mediaType, name, path, int64(len(data))), nil
+}
+
+func newAttachment(mediaType, name, path string, size int64) types.Attachment {
+	if parsed, _, err := mime.ParseMediaType(mediaType); err == nil {
+		mediaType = parsed
+	}
+	kind := types.AttachmentDocument
+	if strings.HasPrefix(mediaType, "image/") {
+		kind = types.AttachmentImage
+	}
+	return types.Attachment{Kind: kind, MediaType: mediaType, Name: name, Path: path, Size: size}
+}
+
+// SetSizes records the size of attachments saved before sizes were kept, so
+// token estimates can count them. Missing files are left at zero.
+func SetSizes(list []types.Attachment) {
+	for i, a := range list {
+		if a.Size > 0 || a.Path == "" {
+			continue
+		}
+		if info, err := os.Stat(a.Path); err == nil {
+			list[i].Size = info.Size()
+		}
+	}
+}
+
+// decodeDataURL parses a "data:<type>;base64,<data>" URL.
+func decodeDataURL(url string) (string, []byte, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return "", nil, fmt.Errorf("clipboard does not hold a base64 data URL")
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) > MaxSize {
+		return "", nil, fmt.Errorf("clipboard data is larger than %d MB", MaxSize>>20)
+	}
+	return strings.TrimSuffix(header, ";base64"), data, nil
+}
@@ -0,0 +1,65 @@
+package attachments
+
+// clipboard_image.go - Reads image data from the clipboard through the
+// platform's clipboard tools, which the text-only clipboard package cannot.
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// windowsImageScript writes the clipboard image, if any, to stdout as PNG.
+const windowsImageScript = `Add-Type -AssemblyName System.Windows.Forms, System.Drawing
+$img = [System.Windows.Forms.Clipboard]::GetImage()
+if ($img) {
+	$buf = New-Object System.IO.MemoryStream
+	$img.Save($buf, [System.Drawing.Imaging.ImageFormat]::Png)
+	$out = [Console]::OpenStandardOutput()
+	$out.Write($buf.ToArray(), 0, $buf.Length)
+}`
+
+// imageCommands lists, per platform, the commands printing the clipboard
+// image as PNG, in order of preference. Tools that are not installed are
+// skipped: wl-paste or xclip on Linux, pngpaste on macOS.
+func imageCommands() [][]string {
+	switch runtime.GOOS {
+	case "windows":
+		return [][]string{{"powershell", "-NoProfile", "-STA", "-Command", windowsImageScript}}
+	case "darwin":
+		return [][]string{{"pngpaste", "-"}}
+	default:
+		var cmds [][]string
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmds = append(cmds, []string{"wl-paste", "--no-newline", "--type", "image/png"})
+		}
+		return append(cmds, []string{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"})
+	}
+}
+
+// readClipboardImage returns the image on the clipboard and its media type,
+// or false when the clipboard holds none or no tool can read it.
+func readClipboardImage() ([]byte, string, bool) {
+	for _, args := range imageCommands() {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var out bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdout = &out
+		err := cmd.Run()
+		cancel()
+		if err != nil || out.Len() == 0 || out.Len() > MaxSize {
+			continue
+		}
+		if mediaType := http.DetectContentType(out.Bytes()); mediaType == "image/png" {
+			return out.Bytes(), mediaType, true
+		}
+	}
+	return nil, "", false
+}
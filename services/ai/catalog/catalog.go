@@ -0,0 +1,73 @@
+// Package catalog discovers the models each provider offers and keeps the
+// token limits and prices of the rest of the app in sync with them.
+package catalog
+
+import (
+	"aichat/errors"
+	"aichat/services/ai"
+	"aichat/services/ai/tokens"
+	"aichat/services/ai/types"
+	"aichat/services/ai/usage"
+	"context"
+	"time"
+)
+
+// Store caches model lists per provider.
+type Store interface {
+	GetByProvider(provider string) ([]types.ModelInfo, time.Time, error)
+	ReplaceProvider(provider string, models []types.ModelInfo, fetchedAt time.Time) error
+}
+
+// Result is the model list of one provider.
+type Result struct {
+	Provider  string
+	Models    []types.ModelInfo
+	FetchedAt time.Time
+	// Cached is set when the fetch failed and Models come from the store;
+	// FetchErr then holds the reason.
+	Cached   bool
+	FetchErr error
+}
+
+// Refresh fetches provider's model list and caches it. If the provider
+// cannot be reached, the cached list is returned instead, so the catalog
+// keeps working offline. An error is returned only when neither works.
+func Refresh(ctx context.Context, provider ai.AIProvider, apiKey string, store Store) (*Result, error) {
+	name := provider.Info().Name
+	lister, ok := provider.(ai.ModelLister)
+	if !ok {
+		return nil, errors.NewValidationError("provider", name+" cannot list its models")
+	}
+	models, err := lister.ListModels(ctx, apiKey)
+	if err != nil {
+		cached, fetchedAt, cacheErr := store.GetByProvider(name)
+		if cacheErr != nil {
+			return nil, err
+		}
+		register(cached)
+		return &Result{Provider: name, Models: cached, FetchedAt: fetchedAt, Cached: true, FetchErr: err}, nil
+	}
+	now := time.Now()
+	if err := store.ReplaceProvider(name, models, now); err != nil {
+		return nil, err
+	}
+	register(models)
+	return &Result{Provider: name, Models: models, FetchedAt: now}, nil
+}
+
+// LoadCached registers the cached lists of providers without fetching, e.g.
+// at startup.
+func LoadCached(store Store, providers []ai.AIProvider) {
+	for _, p := range providers {
+		if models, _, err := store.GetByProvider(p.Info().Name); err == nil {
+			register(models)
+		}
+	}
+}
+
+// register shares reported context windows and prices with the token
+// budgeting and cost accounting.
+func register(models []types.ModelInfo) {
+	tokens.RegisterModels(models)
+	usage.RegisterModels(models)
+}
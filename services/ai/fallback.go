@@ -0,0 +1,227 @@
+package ai
+
+// fallback.go - Named provider/model chains with automatic failover.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"os"
+	"time"
+)
+
+// FallbackType is the ProviderInfo.Type of a fallback chain.
+const FallbackType = "fallback"
+
+// DefaultChainRetry fails over as soon as a step fails. Inside a chain
+// providers make a single attempt (see errors.WithoutRetries), so this is
+// the only retry policy a step gets.
+var DefaultChainRetry = errors.RetryConfig{
+	MaxAttempts:  1,
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     4 * time.Second,
+	Backoff:      errors.ExponentialBackoff,
+}
+
+// ChainStep is one provider/model pair in a fallback chain. An empty Model
+// keeps the model of the request.
+type ChainStep struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+}
+
+// FallbackChain is an AIProvider that tries its steps in order. Each step is
+// retried per Retry instead of by its provider; when it still fails with a retryable error (rate limit,
+// server or network error) the next step is tried. Non-retryable errors, such
+// as a rejected key, are returned at once. Responses and chunks carry the
+// provider and model that answered.
+type FallbackChain struct {
+	Name  string
+	Steps []ChainStep
+	Retry errors.RetryConfig
+	// KeyFor returns the API key for a step's provider. When nil the key
+	// passed to the call is used for every step.
+	KeyFor func(provider AIProvider) string
+}
+
+func (c *FallbackChain) Info() types.ProviderInfo {
+	return types.ProviderInfo{Name: c.Name, Stream: true, Type: FallbackType}
+}
+
+func (c *FallbackChain) retry() errors.RetryConfig {
+	cfg := c.Retry
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return cfg
+}
+
+// step resolves a chain step to its provider, request and key.
+func (c *FallbackChain) step(s ChainStep, req types.ChatRequest, apiKey string) (AIProvider, types.ChatRequest, string, error) {
+	p := GetProviderByName(s.Provider)
+	if p == nil {
+		return nil, req, "", errors.NewConfigurationError("fallback chain "+c.Name, "unknown provider "+s.Provider)
+	}
+	if s.Model != "" {
+		req.Model = s.Model
+	}
+	if c.KeyFor != nil {
+		apiKey = c.KeyFor(p)
+	}
+	return p, req, apiKey, nil
+}
+
+func (c *FallbackChain) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	var last error
+	for _, s := range c.Steps {
+		p, stepReq, key, err := c.step(s, req, apiKey)
+		if err != nil {
+			last = err
+			continue
+		}
+		var resp *types.ChatResponse
+		err = errors.Retry(ctx, c.retry(), func() error {
+			var err error
+			resp, err = p.SendMessage(errors.WithoutRetries(ctx), stepReq, key)
+			return err
+		})
+		if err == nil {
+			resp.Provider = p.Info().Name
+			if resp.Model == "" {
+				resp.Model = stepReq.Model
+			}
+			return resp, nil
+		}
+		if !failover(ctx, err) {
+			return nil, err
+		}
+		last = err
+	}
+	return nil, c.exhausted(last)
+}
+
+// StreamMessage fails over only while nothing has been streamed; once output
+// reached the caller, retrying would repeat it, so later errors are returned.
+func (c *FallbackChain) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	var last error
+	for _, s := range c.Steps {
+		p, stepReq, key, err := c.step(s, req, apiKey)
+		if err != nil {
+			last = err
+			continue
+		}
+		name := p.Info().Name
+		started := false
+		emit := func(chunk types.StreamChunk) {
+			started = true
+			chunk.Provider, chunk.Model = name, stepReq.Model
+			onChunk(chunk)
+		}
+		var partial error
+		err = errors.Retry(ctx, c.retry(), func() error {
+			err := p.StreamMessage(errors.WithoutRetries(ctx), stepReq, key, emit)
+			if err != nil && started {
+				partial = err
+				return nil
+			}
+			return err
+		})
+		if partial != nil {
+			return partial
+		}
+		if err == nil {
+			return nil
+		}
+		if !failover(ctx, err) {
+			return err
+		}
+		last = err
+	}
+	return c.exhausted(last)
+}
+
+// exhausted wraps the error of the last step once every step has failed.
+func (c *FallbackChain) exhausted(last error) error {
+	if last == nil {
+		return errors.NewConfigurationError("fallback chain "+c.Name, "no steps configured")
+	}
+	return errors.NewAIServiceError(c.Name, "fallback chain", last)
+}
+
+// failover reports whether err should move the chain to its next step.
+func failover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var dErr *errors.DomainError
+	return stderrors.As(err, &dErr) && dErr.Retryable
+}
+
+// chainConfig is the JSON form of a fallback chain.
+type chainConfig struct {
+	Name  string      `json:"name"`
+	Steps []ChainStep `json:"steps"`
+	Retry *struct {
+		MaxAttempts  int    `json:"max_attempts"`
+		InitialDelay string `json:"initial_delay"`
+		MaxDelay     string `json:"max_delay"`
+		Backoff      string `json:"backoff"`
+	} `json:"retry,omitempty"`
+}
+
+// LoadChainsFromJSON registers the fallback chains in path as providers, so
+// they can be selected like any other. Steps name providers registered by
+// LoadProvidersFromJSON and are resolved on each call. keyFor picks the API
+// key for each step (see FallbackChain.KeyFor). A missing file is not an
+// error.
+func LoadChainsFromJSON(path string, keyFor func(provider AIProvider) string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var entries []chainConfig
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return errors.NewConfigurationError(path, err.Error())
+	}
+	for _, entry := range entries {
+		if entry.Name == "" || len(entry.Steps) == 0 {
+			return errors.NewConfigurationError(path, "fallback chains need a name and at least one step")
+		}
+		chain := &FallbackChain{Name: entry.Name, Steps: entry.Steps, Retry: DefaultChainRetry, KeyFor: keyFor}
+		if r := entry.Retry; r != nil {
+			if r.MaxAttempts > 0 {
+				chain.Retry.MaxAttempts = r.MaxAttempts
+			}
+			if r.Backoff != "" {
+				chain.Retry.Backoff = errors.BackoffStrategy(r.Backoff)
+			}
+			if err := parseDelay(r.InitialDelay, &chain.Retry.InitialDelay); err != nil {
+				return errors.NewConfigurationError(entry.Name+" initial_delay", err.Error())
+			}
+			if err := parseDelay(r.MaxDelay, &chain.Retry.MaxDelay); err != nil {
+				return errors.NewConfigurationError(entry.Name+" max_delay", err.Error())
+			}
+		}
+		registerProvider(entry.Name, chain)
+	}
+	return nil
+}
+
+// parseDelay sets *d from a duration such as "500ms", leaving it unchanged
+// when s is empty.
+func parseDelay(s string, d *time.Duration) error {
+	if s == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
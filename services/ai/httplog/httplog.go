@@ -0,0 +1,236 @@
+// Package httplog records provider HTTP traffic for debugging. When enabled,
+// every request and its response, streamed bodies included, is written with
+// credentials redacted to a size-rotated log, and the last exchange is kept
+// for display.
+package httplog
+
+import (
+	"aichat/errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// FileName is the active log inside the log directory; rotated logs get
+	// a numeric suffix, http.log.1 being the most recent.
+	FileName = "http.log"
+	// MaxSize is the size a log may grow to before it is rotated.
+	MaxSize = 5 << 20
+	// MaxBackups is how many rotated logs are kept.
+	MaxBackups = 3
+	// maxBody bounds how much of a single body is logged.
+	maxBody = 1 << 20
+)
+
+// Exchange is one logged request/response pair, already redacted.
+type Exchange struct {
+	ID       int64
+	Time     time.Time
+	Duration time.Duration // Until the response body was closed
+	Request  string        // Request line, headers and body
+	Response string        // Status line, headers and body as received
+	Err      string        // Transport or body read failure, if any
+}
+
+// String formats the exchange as it appears in the log.
+func (e *Exchange) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "===== #%d %s (%s)\n", e.ID, e.Time.Format("2006-01-02 15:04:05.000"), e.Duration.Round(time.Millisecond))
+	b.WriteString(e.Request)
+	b.WriteString(e.Response)
+	if e.Err != "" {
+		b.WriteString("! " + Redact(e.Err) + "\n")
+	}
+	return b.String()
+}
+
+var (
+	mu     sync.Mutex
+	dir    string // Log directory; empty while logging is off
+	last   *Exchange
+	nextID atomic.Int64
+)
+
+// Enable starts logging into logDir, creating it if needed.
+func Enable(logDir string) error {
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		return errors.NewStorageError("enable http log", logDir, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	dir = logDir
+	return nil
+}
+
+// Disable stops logging. The last exchange stays available.
+func Disable() {
+	mu.Lock()
+	defer mu.Unlock()
+	dir = ""
+}
+
+// Enabled reports whether requests are being logged.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return dir != ""
+}
+
+// Last returns the most recently completed exchange, or nil when none was
+// logged yet.
+func Last() *Exchange {
+	mu.Lock()
+	defer mu.Unlock()
+	return last
+}
+
+// Wrap returns a transport that logs through base while logging is
+// enabled. A nil base means http.DefaultTransport.
+func Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+// Transport is an http.RoundTripper that logs every exchange while logging
+// is enabled and passes requests through untouched otherwise.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Enabled() {
+		return t.Base.RoundTrip(req)
+	}
+	ex := &Exchange{ID: nextID.Add(1), Time: time.Now(), Request: formatRequest(req)}
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		ex.Err = err.Error()
+		finish(ex)
+		return nil, err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "< %s %s\n", resp.Proto, resp.Status)
+	writeHeaders(&b, "< ", resp.Header)
+	b.WriteString("<\n")
+	ex.Response = b.String()
+	resp.Body = &loggedBody{ReadCloser: resp.Body, ex: ex}
+	return resp, nil
+}
+
+// formatRequest renders the request line, headers and body. The body is read
+// through GetBody so the request itself is left unconsumed.
+func formatRequest(req *http.Request) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, Redact(req.URL.String()))
+	writeHeaders(&b, "> ", req.Header)
+	b.WriteString(">\n")
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxBody))
+			body.Close()
+			writeBody(&b, data)
+		}
+	}
+	return b.String()
+}
+
+func writeBody(b *strings.Builder, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	b.WriteString(Redact(string(data)))
+	if data[len(data)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	if len(data) >= maxBody {
+		b.WriteString("[truncated]\n")
+	}
+}
+
+// loggedBody copies what the caller reads from a response body, chunk by
+// chunk as it streams in, and logs the exchange when the body is closed.
+type loggedBody struct {
+	io.ReadCloser
+	ex   *Exchange
+	buf  []byte
+	once sync.Once
+}
+
+func (l *loggedBody) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	if room := maxBody - len(l.buf); room > 0 {
+		l.buf = append(l.buf, p[:min(n, room)]...)
+	}
+	if err != nil && err != io.EOF {
+		l.ex.Err = err.Error()
+	}
+	return n, err
+}
+
+func (l *loggedBody) Close() error {
+	err := l.ReadCloser.Close()
+	l.once.Do(func() {
+		var b strings.Builder
+		b.WriteString(l.ex.Response)
+		writeBody(&b, l.buf)
+		l.ex.Response = b.String()
+		finish(l.ex)
+	})
+	return err
+}
+
+// finish records a completed exchange as the last one and appends it to the
+// log. Failing to write must not fail the request, so write errors only
+// disable logging.
+func finish(ex *Exchange) {
+	ex.Duration = time.Since(ex.Time)
+	mu.Lock()
+	defer mu.Unlock()
+	last = ex
+	if dir == "" {
+		return
+	}
+	if err := appendLog(filepath.Join(dir, FileName), ex.String()); err != nil {
+		slog.Warn("Failed to write HTTP log, logging disabled", "dir", dir, "error", err)
+		dir = ""
+	}
+}
+
+// appendLog writes entry to path, rotating first when it would grow past
+// MaxSize.
+func appendLog(path, entry string) error {
+	if info, err := os.Stat(path); err == nil && info.Size()+int64(len(entry)) > MaxSize {
+		if err := rotate(path); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotate shifts path to path.1, path.1 to path.2 and so on, dropping the
+// oldest beyond MaxBackups.
+func rotate(path string) error {
+	os.Remove(fmt.Sprintf("%s.%d", path, MaxBackups))
+	for i := MaxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	return os.Rename(path, path+".1")
+}
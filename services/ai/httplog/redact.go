@@ -0,0 +1,98 @@
+package httplog
+
+// redact.go - Removal of credentials from logged headers and bodies.
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces every secret in a transcript.
+const Redacted = "[REDACTED]"
+
+// secretHeaders carry credentials and are never logged.
+var secretHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"X-Api-Key":           true,
+	"X-Goog-Api-Key":      true,
+	"Api-Key":             true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// secretPatterns match key-like strings wherever they appear: provider key
+// formats, bearer tokens, key query parameters and key-named JSON fields.
+// Patterns with a group keep the group and redact the rest of the match.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=\-]{8,}`),
+	regexp.MustCompile(`(?i)([?&](?:key|api_key|apikey|access_token|token)=)[^&\s"']+`),
+	regexp.MustCompile(`(?i)("(?:api_?key|key|token|access_token|secret|password)"\s*:\s*")[^"]+`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`),
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`),
+	regexp.MustCompile(`\b(?:gsk|hf|xai|pplx)[_-][A-Za-z0-9]{20,}`),
+}
+
+var (
+	customMu sync.RWMutex
+	// customHeaders and customPatterns cover the auth header and query
+	// parameter names of configured providers.
+	customHeaders  = map[string]bool{}
+	customPatterns []*regexp.Regexp
+)
+
+// AddSecretName marks a header or query parameter name as carrying a
+// credential, e.g. the custom auth header of a configured provider.
+func AddSecretName(name string) {
+	if name == "" {
+		return
+	}
+	customMu.Lock()
+	defer customMu.Unlock()
+	if customHeaders[http.CanonicalHeaderKey(name)] {
+		return
+	}
+	customHeaders[http.CanonicalHeaderKey(name)] = true
+	customPatterns = append(customPatterns, regexp.MustCompile(`([?&]`+regexp.QuoteMeta(name)+`=)[^&\s"']+`))
+}
+
+// Redact returns s with key-like strings replaced by Redacted.
+func Redact(s string) string {
+	customMu.RLock()
+	patterns := append(secretPatterns[:len(secretPatterns):len(secretPatterns)], customPatterns...)
+	customMu.RUnlock()
+	for _, re := range patterns {
+		if re.NumSubexp() > 0 {
+			s = re.ReplaceAllString(s, "${1}"+Redacted)
+		} else {
+			s = re.ReplaceAllString(s, Redacted)
+		}
+	}
+	return s
+}
+
+// writeHeaders formats h one header per line after prefix, in a stable
+// order, with credential headers redacted.
+func writeHeaders(b *strings.Builder, prefix string, h http.Header) {
+	h = h.Clone()
+	for name := range h {
+		if secretHeaders[http.CanonicalHeaderKey(name)] || isCustomSecret(name) {
+			h[name] = []string{Redacted}
+		}
+	}
+	var sb strings.Builder
+	_ = h.Write(&sb)
+	for _, line := range strings.Split(strings.TrimRight(sb.String(), "\r\n"), "\r\n") {
+		if line != "" {
+			b.WriteString(prefix + Redact(line) + "\n")
+		}
+	}
+}
+
+func isCustomSecret(header string) bool {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	return customHeaders[http.CanonicalHeaderKey(header)]
+}
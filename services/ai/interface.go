@@ -1,11 +1,45 @@
 package ai
 package ai
 
-import "aichat/services/ai/types"
+import (
+	"aichat/services/ai/types"
+	"context"
+)
 
+// AIProvider is the contract every chat backend implements. All calls take a
+// context so callers can cancel in-flight requests or apply deadlines.
 type AIProvider interface {
 	Info() types.ProviderInfo
-	SendMessage(messages []map[string]string, apiKey, model string) (string, error)
-	StreamMessage(messages []map[string]string, apiKey, model string, onData func(data string)) error
+	SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error)
+	StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error
 }
 
+// ModelLister is implemented by providers that can enumerate their models.
+type ModelLister interface {
+	ListModels(ctx context.Context, apiKey string) ([]types.ModelInfo, error)
+}
+
+// Embedder is implemented by providers with an embeddings endpoint. Large
+// input lists are split into batches; the result holds one vector per input,
+// in input order.
+type Embedder interface {
+	Embed(ctx context.Context, inputs []string, model, apiKey string) (*types.EmbeddingResponse, error)
+}
+
+// ToolSupport is implemented by providers that know whether a model accepts
+// tools. Providers without it are assumed to pass tools on.
+type ToolSupport interface {
+	SupportsTools(model string) bool
+}
+
+// SupportsTools reports whether tools may be offered to model through p. A
+// provider definition declaring capabilities decides for all its models.
+func SupportsTools(p AIProvider, model string) bool {
+	if c := p.Info().Capabilities; c != nil {
+		return c.Tools
+	}
+	if ts, ok := p.(ToolSupport); ok {
+		return ts.SupportsTools(model)
+	}
+	return true
+}
@@ -0,0 +1,392 @@
+// Package mcp is a Model Context Protocol client for local tool servers. It
+// launches configured stdio servers, discovers their tools, resources and
+// prompts, and offers the tools to models through a tools.Registry, each
+// call subject to the user's approval.
+package mcp
+
+import (
+	"aichat/errors"
+	"bufio"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is the MCP revision requested in the handshake.
+const ProtocolVersion = "2025-03-26"
+
+// initTimeout bounds starting a server: the handshake and the listings.
+const initTimeout = 20 * time.Second
+
+// Tool is a tool a server offers.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Resource is a piece of context a server can provide, e.g. a file.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Prompt is a prompt template a server offers.
+type Prompt struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Arguments   []struct {
+		Name     string `json:"name"`
+		Required bool   `json:"required,omitempty"`
+	} `json:"arguments,omitempty"`
+}
+
+// Client is a connection to one running server.
+type Client struct {
+	Name      string
+	Tools     []Tool
+	Resources []Resource
+	Prompts   []Prompt
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan rpcMessage
+	err     error // Why the server exited; nil while it runs
+	exited  chan struct{}
+	stderr  chan struct{} // Closed once the server's stderr is drained
+}
+
+// Start launches a server, performs the initialize handshake and lists what
+// it offers.
+func Start(ctx context.Context, cfg ServerConfig) (*Client, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.NewAIServiceError(cfg.Name, "mcp start", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.NewAIServiceError(cfg.Name, "mcp start", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, errors.NewAIServiceError(cfg.Name, "mcp start", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.NewAIServiceError(cfg.Name, "mcp start", err)
+	}
+	c := &Client{Name: cfg.Name, cmd: cmd, stdin: stdin, pending: map[int64]chan rpcMessage{}, exited: make(chan struct{}), stderr: make(chan struct{})}
+	go func() {
+		// Servers log to stderr; keep it in the application log.
+		defer close(c.stderr)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			slog.Debug("MCP server output", "server", cfg.Name, "line", scanner.Text())
+		}
+	}()
+	go c.read(stdout)
+
+	ctx, cancel := context.WithTimeout(ctx, initTimeout)
+	defer cancel()
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// initialize negotiates the protocol and lists tools, resources and prompts
+// for each capability the server declares.
+func (c *Client) initialize(ctx context.Context) error {
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		Capabilities    struct {
+			Tools     json.RawMessage `json:"tools"`
+			Resources json.RawMessage `json:"resources"`
+			Prompts   json.RawMessage `json:"prompts"`
+		} `json:"capabilities"`
+	}
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "aichat", "version": "1.0.0"},
+	}
+	if err := c.call(ctx, "initialize", params, &init); err != nil {
+		return err
+	}
+	if err := c.notify("notifications/initialized", nil); err != nil {
+		return errors.NewAIServiceError(c.Name, "initialize", err)
+	}
+	if init.Capabilities.Tools != nil {
+		if err := listAll(ctx, c, "tools/list", "tools", &c.Tools); err != nil {
+			return err
+		}
+	}
+	if init.Capabilities.Resources != nil {
+		if err := listAll(ctx, c, "resources/list", "resources", &c.Resources); err != nil {
+			return err
+		}
+	}
+	if init.Capabilities.Prompts != nil {
+		if err := listAll(ctx, c, "prompts/list", "prompts", &c.Prompts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listAll follows nextCursor through a paginated list, appending the items
+// under key to out.
+func listAll[T any](ctx context.Context, c *Client, method, key string, out *[]T) error {
+	cursor := ""
+	for {
+		var params any
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		var page map[string]json.RawMessage
+		if err := c.call(ctx, method, params, &page); err != nil {
+			return err
+		}
+		var items []T
+		if raw, ok := page[key]; ok {
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return errors.NewAIServiceError(c.Name, method, err)
+			}
+		}
+		*out = append(*out, items...)
+		cursor = ""
+		if raw, ok := page["nextCursor"]; ok {
+			_ = json.Unmarshal(raw, &cursor)
+		}
+		if cursor == "" {
+			return nil
+		}
+	}
+}
+
+// CallTool runs a tool and returns its result as text. A result the server
+// flags as an error is returned as one.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			Resource *struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(result.Content))
+	for _, item := range result.Content {
+		switch {
+		case item.Type == "text":
+			parts = append(parts, item.Text)
+		case item.Resource != nil && item.Resource.Text != "":
+			parts = append(parts, item.Resource.Text)
+		case item.Resource != nil:
+			parts = append(parts, "[resource "+item.Resource.URI+"]")
+		default:
+			parts = append(parts, fmt.Sprintf("[%s %s]", item.Type, item.MimeType))
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", stderrors.New(text)
+	}
+	return text, nil
+}
+
+// Err returns why the server exited, or nil while it runs.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close stops the server: stdin is closed, as the protocol prescribes, and
+// the process is killed if it has not exited shortly after.
+func (c *Client) Close() {
+	c.stdin.Close()
+	select {
+	case <-c.exited:
+	case <-time.After(2 * time.Second):
+		if c.cmd.Process != nil {
+			c.cmd.Process.Kill()
+		}
+	}
+}
+
+// rpcMessage is any JSON-RPC 2.0 message, in either direction.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// read dispatches server messages until the server exits, then fails the
+// requests still waiting.
+func (c *Client) read(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	var err error
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			c.dispatch(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	// Wait closes the pipes, so let the stderr reader finish first.
+	<-c.stderr
+	if waitErr := c.cmd.Wait(); waitErr != nil {
+		err = waitErr
+	} else if err == io.EOF {
+		err = stderrors.New("server exited")
+	}
+	c.mu.Lock()
+	c.err = errors.NewAIServiceError(c.Name, "mcp", err)
+	pending := c.pending
+	c.pending = map[int64]chan rpcMessage{}
+	c.mu.Unlock()
+	for _, ch := range pending {
+		close(ch)
+	}
+	close(c.exited)
+}
+
+func (c *Client) dispatch(line []byte) {
+	var msg struct {
+		ID     *json.RawMessage `json:"id"`
+		Method string           `json:"method"`
+		Result json.RawMessage  `json:"result"`
+		Error  *rpcError        `json:"error"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		slog.Warn("Ignoring malformed MCP message", "server", c.Name, "error", err)
+		return
+	}
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		// A request from the server. Only ping is supported: the client
+		// declares no capabilities the server could call on.
+		reply := map[string]any{"jsonrpc": "2.0", "id": msg.ID}
+		if msg.Method == "ping" {
+			reply["result"] = map[string]any{}
+		} else {
+			reply["error"] = rpcError{Code: -32601, Message: "method not found: " + msg.Method}
+		}
+		if err := c.write(reply); err != nil {
+			slog.Warn("Failed to answer MCP request", "server", c.Name, "method", msg.Method, "error", err)
+		}
+	case msg.Method != "":
+		// Notifications such as list changes or progress are not acted on.
+	case msg.ID != nil:
+		var id int64
+		if json.Unmarshal(*msg.ID, &id) != nil {
+			return
+		}
+		c.mu.Lock()
+		ch := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- rpcMessage{Result: msg.Result, Error: msg.Error}
+		}
+	}
+}
+
+// call sends a request and decodes its result into out. A cancelled context
+// notifies the server and returns without waiting for it.
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan rpcMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.write(rpcMessage{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		c.forget(id)
+		return errors.NewAIServiceError(c.Name, method, err)
+	}
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return c.Err()
+		}
+		if msg.Error != nil {
+			return errors.NewAIServiceError(c.Name, method, fmt.Errorf("%s (code %d)", msg.Error.Message, msg.Error.Code))
+		}
+		if out != nil && len(msg.Result) > 0 {
+			if err := json.Unmarshal(msg.Result, out); err != nil {
+				return errors.NewAIServiceError(c.Name, method, err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		c.forget(id)
+		_ = c.notify("notifications/cancelled", map[string]any{"requestId": id, "reason": ctx.Err().Error()})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(method string, params any) error {
+	return c.write(rpcMessage{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (c *Client) forget(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+// write sends one message as a line of JSON.
+func (c *Client) write(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
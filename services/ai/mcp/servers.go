@@ -0,0 +1,292 @@
+package mcp
+
+// servers.go - Configured servers, their lifecycle, and their tools offered
+// to models behind user approval.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/tools"
+	"aichat/services/ai/types"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// ServerConfig is one entry of the servers file.
+type ServerConfig struct {
+	Name     string            `json:"name"`
+	Command  string            `json:"command"`
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Disabled bool              `json:"disabled,omitempty"`
+}
+
+// ServerStatus describes a configured server for display. An enabled server
+// with neither Client nor Err is still starting.
+type ServerStatus struct {
+	Config ServerConfig
+	Client *Client // Nil unless running
+	Err    error   // Why it is not running, if it failed
+}
+
+var (
+	mu         sync.Mutex
+	configPath string
+	configs    []ServerConfig
+	clients    = map[string]*Client{}
+	failures   = map[string]error{}
+)
+
+// LoadServersFromJSON reads the servers in path and starts every enabled
+// one in the background. A server that fails to start is reported by
+// Servers rather than here, so one broken server does not keep the others
+// from loading. A missing file is not an error.
+func LoadServersFromJSON(path string) error {
+	data, err := os.ReadFile(path)
+	var entries []ServerConfig
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return errors.NewStorageError("load mcp servers", path, err)
+	default:
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return errors.NewConfigurationError(path, err.Error())
+		}
+	}
+	for _, entry := range entries {
+		if entry.Name == "" || entry.Command == "" {
+			return errors.NewConfigurationError(path, "mcp servers need a name and a command")
+		}
+	}
+	mu.Lock()
+	configPath, configs = path, entries
+	mu.Unlock()
+	for _, entry := range entries {
+		if !entry.Disabled {
+			go start(entry)
+		}
+	}
+	return nil
+}
+
+// start launches a server, recording the client or the failure. A server
+// disabled while it was starting is closed again.
+func start(cfg ServerConfig) {
+	client, err := Start(context.Background(), cfg)
+	mu.Lock()
+	defer mu.Unlock()
+	delete(failures, cfg.Name)
+	if err != nil {
+		failures[cfg.Name] = err
+		return
+	}
+	if !isEnabled(cfg.Name) {
+		go client.Close()
+		return
+	}
+	clients[cfg.Name] = client
+}
+
+// isEnabled reports whether a server is configured and enabled. mu must be
+// held.
+func isEnabled(name string) bool {
+	for _, cfg := range configs {
+		if cfg.Name == name {
+			return !cfg.Disabled
+		}
+	}
+	return false
+}
+
+// stop closes a running server.
+func stop(name string) {
+	mu.Lock()
+	client := clients[name]
+	delete(clients, name)
+	delete(failures, name)
+	mu.Unlock()
+	if client != nil {
+		client.Close()
+	}
+}
+
+// Servers returns every configured server in file order.
+func Servers() []ServerStatus {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]ServerStatus, len(configs))
+	for i, cfg := range configs {
+		out[i] = ServerStatus{Config: cfg, Err: failures[cfg.Name]}
+		if client := clients[cfg.Name]; client != nil {
+			if err := client.Err(); err != nil {
+				out[i].Err = err
+			} else {
+				out[i].Client = client
+			}
+		}
+	}
+	return out
+}
+
+// SetEnabled starts or stops a server and saves the choice in the servers
+// file.
+func SetEnabled(name string, enabled bool) error {
+	mu.Lock()
+	var cfg *ServerConfig
+	for i := range configs {
+		if configs[i].Name == name {
+			cfg = &configs[i]
+		}
+	}
+	if cfg == nil {
+		mu.Unlock()
+		return errors.NewNotFoundError("mcp server", name)
+	}
+	cfg.Disabled = !enabled
+	entry, path := *cfg, configPath
+	data, err := json.MarshalIndent(configs, "", "  ")
+	mu.Unlock()
+	stop(name)
+	if enabled {
+		go start(entry)
+	}
+	if err != nil {
+		return errors.NewStorageError("save mcp servers", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.NewStorageError("save mcp servers", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.NewStorageError("save mcp servers", path, err)
+	}
+	return nil
+}
+
+// Restart stops a server, if running, and starts it again in the
+// background.
+func Restart(name string) error {
+	for _, s := range Servers() {
+		if s.Config.Name == name {
+			stop(name)
+			go start(s.Config)
+			return nil
+		}
+	}
+	return errors.NewNotFoundError("mcp server", name)
+}
+
+// Shutdown stops every running server.
+func Shutdown() {
+	mu.Lock()
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+	mu.Unlock()
+	for _, name := range names {
+		stop(name)
+	}
+}
+
+// Approval asks the user whether a tool may run. Exactly one answer is
+// expected; the tool call waits for it.
+type Approval struct {
+	Server    string
+	Tool      string
+	Arguments json.RawMessage
+	reply     chan bool
+}
+
+// Answer allows or denies the call.
+func (a *Approval) Answer(allow bool) {
+	select {
+	case a.reply <- allow:
+	default:
+	}
+}
+
+// approvals carries pending approvals to the UI.
+var approvals = make(chan *Approval)
+
+// Approvals delivers each tool call awaiting the user's decision.
+func Approvals() <-chan *Approval {
+	return approvals
+}
+
+// errDeclined is reported to the model when the user refuses a call.
+var errDeclined = stderrors.New("the user declined to run this tool")
+
+// invalidToolChars are replaced in tool names, which providers restrict to
+// letters, digits, '_' and '-'.
+var invalidToolChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// ToolName is the name a server's tool is offered under: prefixed with the
+// server so tools of different servers cannot collide.
+func ToolName(server, tool string) string {
+	name := invalidToolChars.ReplaceAllString(server+"_"+tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// ToolRegistry returns the tools of every running server, or nil when there
+// are none. Each call first asks for the user's approval through Approvals.
+func ToolRegistry() *tools.Registry {
+	registry := tools.NewRegistry()
+	for _, s := range Servers() {
+		if s.Client == nil {
+			continue
+		}
+		for _, tool := range s.Client.Tools {
+			client, name := s.Client, tool.Name
+			description := tool.Description
+			if description == "" {
+				description = name
+			}
+			// A tool the registry rejects, e.g. a name clashing after
+			// shortening, is left out rather than failing the others.
+			err := registry.Register(types.Tool{
+				Name:        ToolName(client.Name, name),
+				Description: "[" + client.Name + "] " + description,
+				Parameters:  tool.InputSchema,
+			}, func(ctx context.Context, args json.RawMessage) (string, error) {
+				if err := approve(ctx, client.Name, name, args); err != nil {
+					return "", err
+				}
+				return client.CallTool(ctx, name, args)
+			})
+			if err != nil {
+				slog.Warn("Skipping MCP tool", "server", client.Name, "tool", name, "error", err)
+			}
+		}
+	}
+	if registry.Len() == 0 {
+		return nil
+	}
+	return registry
+}
+
+// approve waits for the user to answer an Approval for the call.
+func approve(ctx context.Context, server, tool string, args json.RawMessage) error {
+	a := &Approval{Server: server, Tool: tool, Arguments: args, reply: make(chan bool, 1)}
+	select {
+	case approvals <- a:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case allow := <-a.reply:
+		if !allow {
+			return errDeclined
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,352 @@
+package providers
+
+// anthropic.go - Native Anthropic Messages API provider.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/sse"
+	"aichat/services/ai/types"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com/v1"
+	anthropicVersion = "2023-06-01"
+	// anthropicDefaultMaxTokens is used when the request leaves MaxTokens unset;
+	// the Messages API requires an explicit limit.
+	anthropicDefaultMaxTokens = 4096
+)
+
+// AnthropicType is the ProviderInfo.Type value selecting this provider.
+const AnthropicType = "anthropic"
+
+type AnthropicProvider struct {
+	info types.ProviderInfo
+}
+
+// NewAnthropicProvider builds a provider from its definition; BaseURL, when
+// set, replaces the API root.
+func NewAnthropicProvider(info types.ProviderInfo) *AnthropicProvider {
+	info = builtinInfo(info, AnthropicType, "Anthropic", anthropicBaseURL, "/messages", types.AuthHeader, "x-api-key")
+	info.Network = withProviderHeaders(info, nil)
+	return &AnthropicProvider{info: info}
+}
+
+func (p *AnthropicProvider) Info() types.ProviderInfo {
+	return p.info
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicContentBlock is a text, thinking, tool_use or tool_result block.
+type anthropicContentBlock struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	Thinking  string           `json:"thinking,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	Source    *anthropicSource `json:"source,omitempty"`
+}
+
+// anthropicSource carries image or document data: base64 for binary files,
+// plain text for text documents.
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	Thinking      *anthropicThinking `json:"thinking,omitempty"`
+}
+
+// anthropicThinking enables extended thinking with a token budget, which
+// counts towards max_tokens.
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// anthropicUsage counts cache writes and reads as prompt tokens, since they
+// are input the request was billed for.
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func (u anthropicUsage) promptTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+type anthropicResponse struct {
+	Model      string                  `json:"model"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+// anthropicEvent covers the fields used from every streamed event type.
+type anthropicEvent struct {
+	Type         string                `json:"type"`
+	Index        int                   `json:"index"`
+	ContentBlock anthropicContentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	// Message is sent with message_start and carries the prompt usage;
+	// message_delta reports the output tokens in Usage.
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// newAnthropicRequest moves system messages into the top-level system field,
+// which is where the Messages API expects them. Tool results are sent as
+// tool_result blocks in a user turn, grouped when several follow each other.
+func newAnthropicRequest(req types.ChatRequest, stream bool) anthropicRequest {
+	var system []string
+	messages := make([]anthropicMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+			continue
+		case "tool":
+			block := anthropicContentBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content}
+			if n := len(messages); n > 0 && messages[n-1].Role == "user" && messages[n-1].Content[0].Type == "tool_result" {
+				messages[n-1].Content = append(messages[n-1].Content, block)
+			} else {
+				messages = append(messages, anthropicMessage{Role: "user", Content: []anthropicContentBlock{block}})
+			}
+			continue
+		}
+		// The API rejects empty text blocks, and messages without content.
+		blocks := anthropicAttachmentBlocks(m.Attachments)
+		if m.Content != "" {
+			blocks = append(blocks, anthropicContentBlock{Type: "text", Text: m.Content})
+		}
+		for _, call := range m.ToolCalls {
+			blocks = append(blocks, anthropicContentBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: toolArguments(call.Arguments)})
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		messages = append(messages, anthropicMessage{Role: m.Role, Content: blocks})
+	}
+	var tools []anthropicTool
+	for _, t := range req.Tools {
+		tools = append(tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: toolSchema(t)})
+	}
+	// The Messages API has no JSON mode, so the format is asked for in the
+	// system prompt and left to local validation.
+	if req.ResponseFormat != nil {
+		system = append(system, req.ResponseFormat.Instruction())
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	// Temperature only ranges 0-1 here, and newer models reject temperature
+	// and top_p together, so top_p gives way.
+	temperature, topP := req.Temperature, req.TopP
+	if temperature != nil {
+		t := min(max(*temperature, 0), 1)
+		temperature, topP = &t, nil
+	}
+	// Thinking takes no sampling settings, and its budget must leave room
+	// for the answer. It stays off with tools: tool rounds would have to
+	// send the signed thinking blocks back, which messages do not keep.
+	var thinking *anthropicThinking
+	if req.ThinkingBudget > 0 && len(tools) == 0 {
+		thinking = &anthropicThinking{Type: "enabled", BudgetTokens: req.ThinkingBudget}
+		if maxTokens <= req.ThinkingBudget {
+			maxTokens = req.ThinkingBudget + anthropicDefaultMaxTokens
+		}
+		temperature, topP = nil, nil
+	}
+	return anthropicRequest{
+		Model:         req.Model,
+		System:        strings.Join(system, "\n\n"),
+		Messages:      messages,
+		MaxTokens:     maxTokens,
+		Temperature:   temperature,
+		TopP:          topP,
+		StopSequences: req.Stop,
+		Stream:        stream,
+		Tools:         tools,
+		Thinking:      thinking,
+	}
+}
+
+// anthropicAttachmentBlocks converts attachments to image and document
+// blocks; they precede the text, as the API recommends.
+func anthropicAttachmentBlocks(attachments []types.Attachment) []anthropicContentBlock {
+	var blocks []anthropicContentBlock
+	for _, a := range attachments {
+		switch {
+		case a.Kind == types.AttachmentImage:
+			blocks = append(blocks, anthropicContentBlock{Type: "image", Source: &anthropicSource{Type: "base64", MediaType: a.MediaType, Data: base64Data(a)}})
+		case isTextAttachment(a):
+			blocks = append(blocks, anthropicContentBlock{Type: "document", Source: &anthropicSource{Type: "text", MediaType: "text/plain", Data: string(a.Data)}})
+		default:
+			blocks = append(blocks, anthropicContentBlock{Type: "document", Source: &anthropicSource{Type: "base64", MediaType: a.MediaType, Data: base64Data(a)}})
+		}
+	}
+	return blocks
+}
+
+func (p *AnthropicProvider) headers(apiKey string) map[string]string {
+	return map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+}
+
+func (p *AnthropicProvider) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	req, err := loadAttachments(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := postJSON(ctx, p.info, p.info.Endpoint, p.headers(apiKey), newAnthropicRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.NewAIServiceError(p.info.Name, "decode response", err)
+	}
+	var text, thinking strings.Builder
+	var calls []types.ToolCall
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "thinking":
+			thinking.WriteString(block.Thinking)
+		case "tool_use":
+			calls = append(calls, types.ToolCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
+		}
+	}
+	return &types.ChatResponse{
+		Model:        result.Model,
+		Content:      text.String(),
+		FinishReason: result.StopReason,
+		ToolCalls:    calls,
+		Usage:        &types.Usage{PromptTokens: result.Usage.promptTokens(), CompletionTokens: result.Usage.OutputTokens},
+		Reasoning:    thinking.String(),
+	}, nil
+}
+
+func (p *AnthropicProvider) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	req, err := loadAttachments(req)
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, p.info, p.info.Endpoint, p.headers(apiKey), newAnthropicRequest(req, true))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := sse.NewDecoder(resp.Body, p.info.Name)
+	var calls toolCallBuilder
+	var usage types.Usage
+	for {
+		ev, err := dec.Next()
+		if err != nil {
+			return streamError(ctx, p.info.Name, err)
+		}
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+			return errors.NewAIServiceError(p.info.Name, "stream", err)
+		}
+		// content_block_stop and ping carry nothing we use. Error events are
+		// turned into DomainErrors by the decoder.
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.promptTokens()
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				calls.add(event.Index, event.ContentBlock.ID, event.ContentBlock.Name, "")
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				if event.Delta.Text != "" {
+					onChunk(types.StreamChunk{Content: event.Delta.Text})
+				}
+			case "thinking_delta":
+				if event.Delta.Thinking != "" {
+					onChunk(types.StreamChunk{Reasoning: event.Delta.Thinking})
+				}
+			case "input_json_delta":
+				calls.add(event.Index, "", "", event.Delta.PartialJSON)
+			}
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+			if event.Delta.StopReason != "" {
+				onChunk(types.StreamChunk{FinishReason: event.Delta.StopReason, ToolCalls: calls.flush(), Usage: &usage})
+			}
+		case "message_stop":
+			return nil
+		}
+	}
+}
+
+// ListModels queries the Models API. It reports no context window, so the
+// built-in table in the tokens package applies to Claude models.
+func (p *AnthropicProvider) ListModels(ctx context.Context, apiKey string) ([]types.ModelInfo, error) {
+	endpoint := strings.TrimSuffix(p.info.Endpoint, "/messages") + "/models?limit=1000"
+	resp, err := doRequest(ctx, p.info, http.MethodGet, endpoint, p.headers(apiKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.NewAIServiceError(p.info.Name, "list models", err)
+	}
+	models := make([]types.ModelInfo, len(result.Data))
+	for i, m := range result.Data {
+		models[i] = types.ModelInfo{ID: m.ID, OwnedBy: "anthropic", Modalities: []string{"text", "image"}}
+	}
+	return models, nil
+}
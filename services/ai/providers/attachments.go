@@ -0,0 +1,58 @@
+package providers
+
+// attachments.go - Helpers for encoding message attachments.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"encoding/base64"
+	"strings"
+)
+
+// loadAttachments returns req with the data of every attachment read into
+// memory, so request builders can encode them without touching the disk.
+// The caller's messages are left untouched.
+func loadAttachments(req types.ChatRequest) (types.ChatRequest, error) {
+	var messages []types.Message
+	for i, m := range req.Messages {
+		if len(m.Attachments) == 0 {
+			continue
+		}
+		if messages == nil {
+			messages = append([]types.Message(nil), req.Messages...)
+		}
+		attachments := make([]types.Attachment, len(m.Attachments))
+		for j, a := range m.Attachments {
+			data, err := a.Bytes()
+			if err != nil {
+				return req, errors.NewStorageError("load attachment", a.Path, err)
+			}
+			a.Data = data
+			attachments[j] = a
+		}
+		messages[i].Attachments = attachments
+	}
+	if messages != nil {
+		req.Messages = messages
+	}
+	return req, nil
+}
+
+// isTextAttachment reports whether a document is plain text that can be
+// inlined into the prompt instead of being sent as binary data.
+func isTextAttachment(a types.Attachment) bool {
+	return strings.HasPrefix(a.MediaType, "text/") || a.MediaType == "application/json"
+}
+
+func base64Data(a types.Attachment) string {
+	return base64.StdEncoding.EncodeToString(a.Data)
+}
+
+func dataURL(a types.Attachment) string {
+	return "data:" + a.MediaType + ";base64," + base64Data(a)
+}
+
+// attachmentText renders a text document for inlining, labelled with its name.
+func attachmentText(a types.Attachment) string {
+	return a.Name + ":\n" + string(a.Data)
+}
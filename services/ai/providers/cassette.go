@@ -0,0 +1,253 @@
+package providers
+
+// cassette.go - Record real provider exchanges to cassette files and replay
+// them offline, chunk timing included.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ReplayType is the ProviderInfo.Type value selecting a ReplayProvider; its
+// Cassette names the file to play.
+const ReplayType = "replay"
+
+// cassette is the file format: every recorded exchange, in call order.
+type cassette struct {
+	Provider     string        `json:"provider"`
+	Interactions []interaction `json:"interactions"`
+}
+
+// interaction is one recorded call. Key identifies the request so replay
+// can answer the same conversation with the same response.
+type interaction struct {
+	Key      string              `json:"key"`
+	Request  types.ChatRequest   `json:"request"`
+	Response *types.ChatResponse `json:"response,omitempty"`
+	Chunks   []recordedChunk     `json:"chunks,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// recordedChunk is a stream chunk with the time since the previous one.
+type recordedChunk struct {
+	DelayMS int64             `json:"delay_ms"`
+	Chunk   types.StreamChunk `json:"chunk"`
+}
+
+// requestKey hashes what determines a response: model, conversation, tools
+// and sampling settings.
+func requestKey(req types.ChatRequest) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func loadCassette(path string) (*cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewStorageError("load cassette", path, err)
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.NewStorageError("load cassette", path, err)
+	}
+	return &c, nil
+}
+
+// chatBackend is the provider contract, restated here because the interface
+// lives in the parent ai package.
+type chatBackend interface {
+	Info() types.ProviderInfo
+	SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error)
+	StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error
+}
+
+// RecordingProvider passes calls through to a real provider and appends
+// each exchange to a cassette file. API keys are never recorded.
+type RecordingProvider struct {
+	inner chatBackend
+	path  string
+	mu    sync.Mutex
+}
+
+// NewRecordingProvider wraps inner, recording into the cassette at path.
+// An existing cassette is appended to.
+func NewRecordingProvider(inner chatBackend, path string) *RecordingProvider {
+	return &RecordingProvider{inner: inner, path: path}
+}
+
+func (p *RecordingProvider) Info() types.ProviderInfo {
+	return p.inner.Info()
+}
+
+func (p *RecordingProvider) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	resp, err := p.inner.SendMessage(ctx, req, apiKey)
+	p.record(interaction{Request: req, Response: resp}, err)
+	return resp, err
+}
+
+func (p *RecordingProvider) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	var chunks []recordedChunk
+	last := time.Now()
+	err := p.inner.StreamMessage(ctx, req, apiKey, func(chunk types.StreamChunk) {
+		now := time.Now()
+		chunks = append(chunks, recordedChunk{DelayMS: now.Sub(last).Milliseconds(), Chunk: chunk})
+		last = now
+		onChunk(chunk)
+	})
+	p.record(interaction{Request: req, Chunks: chunks}, err)
+	return err
+}
+
+// record appends an exchange. Cancelled calls are not recorded, and a
+// cassette that cannot be written must not fail the chat.
+func (p *RecordingProvider) record(in interaction, err error) {
+	if stderrors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		in.Error = err.Error()
+	}
+	in.Key = requestKey(in.Request)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, loadErr := loadCassette(p.path)
+	if loadErr != nil {
+		c = &cassette{Provider: p.inner.Info().Name}
+	}
+	c.Interactions = append(c.Interactions, in)
+	data, mErr := json.MarshalIndent(c, "", "  ")
+	if mErr != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(p.path), 0755) == nil {
+		_ = os.WriteFile(p.path, data, 0644)
+	}
+}
+
+// ReplayProvider answers from a cassette instead of the network. A request
+// gets the recorded response of the same request; repeated requests get
+// successive recordings, then the last one again. Streams are replayed with
+// their recorded timing scaled by Speed (0 plays without delays).
+type ReplayProvider struct {
+	info  types.ProviderInfo
+	Speed float64
+
+	mu     sync.Mutex
+	loaded *cassette
+	played map[string]int
+}
+
+// NewReplayProvider builds a provider playing info.Cassette. The cassette is
+// read on first use, so a missing file surfaces as a chat error.
+func NewReplayProvider(info types.ProviderInfo) *ReplayProvider {
+	info.Type = ReplayType
+	info.Stream = true
+	return &ReplayProvider{info: info, Speed: 1, played: map[string]int{}}
+}
+
+func (p *ReplayProvider) Info() types.ProviderInfo {
+	return p.info
+}
+
+// next returns the recording answering req.
+func (p *ReplayProvider) next(req types.ChatRequest) (*interaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loaded == nil {
+		c, err := loadCassette(p.info.Cassette)
+		if err != nil {
+			return nil, err
+		}
+		p.loaded = c
+	}
+	key := requestKey(req)
+	var matches []*interaction
+	for i := range p.loaded.Interactions {
+		if p.loaded.Interactions[i].Key == key {
+			matches = append(matches, &p.loaded.Interactions[i])
+		}
+	}
+	if len(matches) == 0 {
+		return nil, errors.NewNotFoundError("cassette interaction", key[:12])
+	}
+	n := p.played[key]
+	p.played[key]++
+	return matches[min(n, len(matches)-1)], nil
+}
+
+func (p *ReplayProvider) replayError(in *interaction) error {
+	if in.Error == "" {
+		return nil
+	}
+	return errors.NewAIServiceError(p.info.Name, "replay", stderrors.New(in.Error))
+}
+
+func (p *ReplayProvider) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	in, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.replayError(in); err != nil {
+		return nil, err
+	}
+	if in.Response != nil {
+		resp := *in.Response
+		return &resp, nil
+	}
+	// Recorded as a stream: join the chunks.
+	return joinChunks(in.Chunks), nil
+}
+
+func (p *ReplayProvider) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	in, err := p.next(req)
+	if err != nil {
+		return err
+	}
+	chunks := in.Chunks
+	if in.Response != nil {
+		// Recorded without streaming: deliver the response as one chunk.
+		r := in.Response
+		chunks = []recordedChunk{{Chunk: types.StreamChunk{Content: r.Content, Reasoning: r.Reasoning, FinishReason: r.FinishReason, ToolCalls: r.ToolCalls, Usage: r.Usage}}}
+	}
+	for _, rc := range chunks {
+		if delay := time.Duration(float64(rc.DelayMS) * p.Speed * float64(time.Millisecond)); delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		onChunk(rc.Chunk)
+	}
+	return p.replayError(in)
+}
+
+// joinChunks assembles a response from a recorded stream.
+func joinChunks(chunks []recordedChunk) *types.ChatResponse {
+	resp := &types.ChatResponse{}
+	for _, rc := range chunks {
+		resp.Content += rc.Chunk.Content
+		resp.Reasoning += rc.Chunk.Reasoning
+		resp.ToolCalls = append(resp.ToolCalls, rc.Chunk.ToolCalls...)
+		if rc.Chunk.FinishReason != "" {
+			resp.FinishReason = rc.Chunk.FinishReason
+		}
+		if rc.Chunk.Usage != nil {
+			resp.Usage = rc.Chunk.Usage
+		}
+		if rc.Chunk.Model != "" {
+			resp.Model = rc.Chunk.Model
+		}
+	}
+	return resp
+}
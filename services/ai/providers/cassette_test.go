@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"aichat/services/ai/types"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordEcho streams req through a recording echo provider, one word every
+// delay, and returns the chunks the caller saw.
+func recordEcho(t *testing.T, path string, req types.ChatRequest, delay time.Duration) []types.StreamChunk {
+	t.Helper()
+	echo := NewScriptedProvider(types.ProviderInfo{Name: "echo", Type: EchoType})
+	echo.Delay = delay
+	var chunks []types.StreamChunk
+	rec := NewRecordingProvider(echo, path)
+	if err := rec.StreamMessage(context.Background(), req, "secret", func(c types.StreamChunk) { chunks = append(chunks, c) }); err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	return chunks
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	const delay = 40 * time.Millisecond
+	req := types.ChatRequest{Model: "echo-1", Messages: []types.Message{{Role: "user", Content: "one two three four"}}}
+	path := filepath.Join(t.TempDir(), "echo.json")
+	recorded := recordEcho(t, path, req, delay)
+
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "secret") {
+		t.Error("cassette contains the API key")
+	}
+	c, err := loadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 1 || len(c.Interactions[0].Chunks) != len(recorded) {
+		t.Fatalf("cassette holds %+v, want one interaction of %d chunks", c.Interactions, len(recorded))
+	}
+	var total time.Duration
+	for i, rc := range c.Interactions[0].Chunks {
+		if i < len(recorded)-1 && rc.DelayMS < delay.Milliseconds() {
+			t.Errorf("chunk %d recorded after %dms, want at least %v", i, rc.DelayMS, delay)
+		}
+		total += time.Duration(rc.DelayMS) * time.Millisecond
+	}
+
+	tests := []struct {
+		name  string
+		speed float64
+	}{
+		{name: "real time", speed: 1},
+		{name: "four times faster", speed: 0.25},
+		{name: "no delays", speed: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay := NewReplayProvider(types.ProviderInfo{Name: "echo", Cassette: path})
+			replay.Speed = tt.speed
+			var played []types.StreamChunk
+			start := time.Now()
+			if err := replay.StreamMessage(context.Background(), req, "", func(c types.StreamChunk) { played = append(played, c) }); err != nil {
+				t.Fatalf("replay: %v", err)
+			}
+			elapsed := time.Since(start)
+			if !reflect.DeepEqual(played, recorded) {
+				t.Errorf("replayed %+v, want %+v", played, recorded)
+			}
+			// Delays are waited out in full, so the scaled total is a lower
+			// bound; the upper bound leaves room for a slow machine.
+			want := time.Duration(float64(total) * tt.speed)
+			if elapsed < want {
+				t.Errorf("replay took %v, want at least %v", elapsed, want)
+			}
+			if tt.speed < 1 && elapsed >= want+(total-want)/2 {
+				t.Errorf("replay took %v, want close to %v (recorded %v)", elapsed, want, total)
+			}
+		})
+	}
+
+	t.Run("send joins the chunks", func(t *testing.T) {
+		resp, err := NewReplayProvider(types.ProviderInfo{Name: "echo", Cassette: path}).SendMessage(context.Background(), req, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content != "Echo: one two three four" || resp.FinishReason != "stop" {
+			t.Errorf("response = %+v", resp)
+		}
+	})
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	req := types.ChatRequest{Model: "echo-1", Messages: []types.Message{{Role: "user", Content: "hi"}}}
+	path := filepath.Join(t.TempDir(), "echo.json")
+	recordEcho(t, path, req, 0)
+	other := types.ChatRequest{Model: "echo-1", Messages: []types.Message{{Role: "user", Content: "bye"}}}
+	err := NewReplayProvider(types.ProviderInfo{Name: "echo", Cassette: path}).StreamMessage(context.Background(), other, "", func(types.StreamChunk) {})
+	if err == nil {
+		t.Error("replaying an unrecorded request succeeded")
+	}
+}
@@ -0,0 +1,441 @@
+package providers
+
+// chat_completions.go - Wire format and HTTP helpers for the OpenAI-style
+// /chat/completions API, shared by every provider that speaks it.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/sse"
+	"aichat/services/ai/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// chatCompletionMessage is a request message. Content is a string, or a
+// []chatCompletionContentPart when the message has attachments.
+type chatCompletionMessage struct {
+	Role       string                   `json:"role"`
+	Content    any                      `json:"content"`
+	ToolCalls  []chatCompletionToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string                   `json:"tool_call_id,omitempty"`
+}
+
+type chatCompletionContentPart struct {
+	Type     string                   `json:"type"`
+	Text     string                   `json:"text,omitempty"`
+	ImageURL *chatCompletionImageURL  `json:"image_url,omitempty"`
+	File     *chatCompletionFileInput `json:"file,omitempty"`
+}
+
+type chatCompletionImageURL struct {
+	URL string `json:"url"`
+}
+
+type chatCompletionFileInput struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data"`
+}
+
+type chatCompletionToolCall struct {
+	// Index is only set on streamed deltas, where it identifies the call
+	// that a fragment belongs to.
+	Index    int    `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatCompletionTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+type chatCompletionRequest struct {
+	Model       string                  `json:"model"`
+	Messages    []chatCompletionMessage `json:"messages"`
+	Stream      bool                    `json:"stream,omitempty"`
+	Temperature *float64                `json:"temperature,omitempty"`
+	TopP        *float64                `json:"top_p,omitempty"`
+	MaxTokens   int                     `json:"max_tokens,omitempty"`
+	Stop        []string                `json:"stop,omitempty"`
+	Tools       []chatCompletionTool    `json:"tools,omitempty"`
+	// MaxCompletionTokens replaces MaxTokens for OpenAI reasoning models.
+	MaxCompletionTokens int                           `json:"max_completion_tokens,omitempty"`
+	ResponseFormat      *chatCompletionResponseFormat `json:"response_format,omitempty"`
+	// StreamOptions asks for a final chunk carrying usage. Only sent to APIs
+	// known to take it (see streamsUsage): some servers reject it.
+	StreamOptions *chatCompletionStreamOptions `json:"stream_options,omitempty"`
+}
+
+// chatCompletionResponseFormat is "json_schema" with a schema, or
+// "json_object" for any object.
+type chatCompletionResponseFormat struct {
+	Type       string                    `json:"type"`
+	JSONSchema *chatCompletionJSONSchema `json:"json_schema,omitempty"`
+}
+
+type chatCompletionJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+func newChatCompletionResponseFormat(f *types.ResponseFormat) *chatCompletionResponseFormat {
+	if f == nil {
+		return nil
+	}
+	if len(f.Schema) == 0 {
+		return &chatCompletionResponseFormat{Type: "json_object"}
+	}
+	name := f.Name
+	if name == "" {
+		name = "response"
+	}
+	return &chatCompletionResponseFormat{Type: "json_schema", JSONSchema: &chatCompletionJSONSchema{Name: name, Schema: f.Schema}}
+}
+
+type chatCompletionStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatCompletionUsage struct {
+	PromptTokens            int `json:"prompt_tokens"`
+	CompletionTokens        int `json:"completion_tokens"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+// toUsage converts wire usage, returning nil when the server sent none.
+func (u *chatCompletionUsage) toUsage() *types.Usage {
+	if u == nil {
+		return nil
+	}
+	usage := &types.Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+	if u.CompletionTokensDetails != nil {
+		usage.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
+	}
+	return usage
+}
+
+// chatCompletionReasoning holds the thinking text of reasoning models. There
+// is no standard field: OpenRouter sends "reasoning", DeepSeek and vLLM send
+// "reasoning_content".
+type chatCompletionReasoning struct {
+	Reasoning        string `json:"reasoning"`
+	ReasoningContent string `json:"reasoning_content"`
+}
+
+func (r chatCompletionReasoning) text() string {
+	if r.Reasoning != "" {
+		return r.Reasoning
+	}
+	return r.ReasoningContent
+}
+
+type chatCompletionResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			chatCompletionReasoning
+			Content   string                   `json:"content"`
+			ToolCalls []chatCompletionToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatCompletionUsage `json:"usage"`
+}
+
+type chatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			chatCompletionReasoning
+			Content   string                   `json:"content"`
+			ToolCalls []chatCompletionToolCall `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *chatCompletionUsage `json:"usage"`
+}
+
+// newChatCompletionRequest converts a provider-agnostic request to the wire
+// format. streamUsage asks a stream for usage totals.
+func newChatCompletionRequest(req types.ChatRequest, stream, streamUsage bool) chatCompletionRequest {
+	messages := make([]chatCompletionMessage, len(req.Messages))
+	for i, m := range req.Messages {
+		messages[i] = chatCompletionMessage{Role: m.Role, Content: chatCompletionContent(m), ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			wire := chatCompletionToolCall{ID: call.ID, Type: "function"}
+			wire.Function.Name = call.Name
+			wire.Function.Arguments = string(toolArguments(call.Arguments))
+			messages[i].ToolCalls = append(messages[i].ToolCalls, wire)
+		}
+	}
+	var tools []chatCompletionTool
+	for _, t := range req.Tools {
+		tool := chatCompletionTool{Type: "function"}
+		tool.Function.Name = t.Name
+		tool.Function.Description = t.Description
+		tool.Function.Parameters = toolSchema(t)
+		tools = append(tools, tool)
+	}
+	out := chatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Stream:      stream,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+		Tools:       tools,
+
+		ResponseFormat: newChatCompletionResponseFormat(req.ResponseFormat),
+	}
+	if isReasoningModel(req.Model) {
+		// These models reject sampling settings and max_tokens outright.
+		out.Temperature, out.TopP, out.Stop = nil, nil, nil
+		out.MaxCompletionTokens, out.MaxTokens = out.MaxTokens, 0
+	}
+	if stream && streamUsage {
+		out.StreamOptions = &chatCompletionStreamOptions{IncludeUsage: true}
+	}
+	return out
+}
+
+// streamsUsage reports whether the API takes stream_options.include_usage:
+// OpenAI and OpenRouter do, other servers only when they declare it.
+func streamsUsage(info types.ProviderInfo) bool {
+	switch info.Type {
+	case OpenAIType, OpenRouterType:
+		return true
+	}
+	return info.Capabilities != nil && info.Capabilities.StreamUsage
+}
+
+// isReasoningModel reports whether model is an OpenAI reasoning model (o1,
+// o3, o4-mini, ...), also under a vendor prefix such as "openai/o3".
+func isReasoningModel(model string) bool {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return len(model) >= 2 && model[0] == 'o' && model[1] >= '1' && model[1] <= '9'
+}
+
+// chatCompletionContent returns the message text, or content parts when it
+// has attachments: images as data URLs, text documents inlined and other
+// documents as base64 files.
+func chatCompletionContent(m types.Message) any {
+	if len(m.Attachments) == 0 {
+		return m.Content
+	}
+	var parts []chatCompletionContentPart
+	if m.Content != "" {
+		parts = append(parts, chatCompletionContentPart{Type: "text", Text: m.Content})
+	}
+	for _, a := range m.Attachments {
+		part := chatCompletionContentPart{}
+		switch {
+		case a.Kind == types.AttachmentImage:
+			part.Type = "image_url"
+			part.ImageURL = &chatCompletionImageURL{URL: dataURL(a)}
+		case isTextAttachment(a):
+			part.Type = "text"
+			part.Text = attachmentText(a)
+		default:
+			part.Type = "file"
+			part.File = &chatCompletionFileInput{Filename: a.Name, FileData: dataURL(a)}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// fromChatCompletionToolCalls converts complete (non-streamed) tool calls.
+func fromChatCompletionToolCalls(calls []chatCompletionToolCall) []types.ToolCall {
+	var out []types.ToolCall
+	for _, call := range calls {
+		out = append(out, types.ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return out
+}
+
+// postJSON sends body as JSON to endpoint and returns the response when the
+// status is 2xx. The caller owns closing the response body.
+func postJSON(ctx context.Context, info types.ProviderInfo, endpoint string, headers map[string]string, body any) (*http.Response, error) {
+	return doRequest(ctx, info, http.MethodPost, endpoint, headers, body)
+}
+
+// doRequest performs an HTTP request with an optional JSON body (nil for none)
+// through the client for the provider's network settings, and maps transport
+// failures and non-2xx statuses to domain errors.
+// Retryable failures are retried per RetryConfig, honoring the delay the
+// server asks for; pass errors.WithRetryObserver in ctx to follow retries.
+func doRequest(ctx context.Context, info types.ProviderInfo, method, endpoint string, headers map[string]string, body any) (*http.Response, error) {
+	var jsonBody []byte
+	if body != nil {
+		var err error
+		if jsonBody, err = json.Marshal(body); err != nil {
+			return nil, errors.NewAIServiceError(info.Name, "encode request", err)
+		}
+	}
+	var resp *http.Response
+	err := errors.Retry(ctx, RetryConfig, func() error {
+		var err error
+		resp, err = doRequestOnce(ctx, info, method, endpoint, headers, jsonBody)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// doRequestOnce makes a single attempt of doRequest.
+func doRequestOnce(ctx context.Context, info types.ProviderInfo, method, endpoint string, headers map[string]string, jsonBody []byte) (*http.Response, error) {
+	var reader io.Reader
+	if jsonBody != nil {
+		reader = bytes.NewReader(jsonBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, errors.NewAIServiceError(info.Name, "create request", err)
+	}
+	if jsonBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client, err := NewHTTPClient(info.Network)
+	if err != nil {
+		return nil, err
+	}
+	if info.Network != nil {
+		for k, v := range info.Network.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.NewNetworkError(info.Name+" request", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		wait := retryAfter(resp.Header, resp.StatusCode, time.Now())
+		return nil, errors.NewAIHTTPError(info.Name, resp.StatusCode, string(respBody), wait)
+	}
+	return resp, nil
+}
+
+// sendChatCompletion performs a non-streaming chat completion.
+func sendChatCompletion(ctx context.Context, info types.ProviderInfo, endpoint string, headers map[string]string, req types.ChatRequest) (*types.ChatResponse, error) {
+	req, err := loadAttachments(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := postJSON(ctx, info, endpoint, headers, newChatCompletionRequest(req, false, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result chatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.NewAIServiceError(info.Name, "decode response", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, errors.NewAIServiceError(info.Name, "decode response", fmt.Errorf("no choices in response"))
+	}
+	return &types.ChatResponse{
+		Model:        result.Model,
+		Content:      result.Choices[0].Message.Content,
+		FinishReason: result.Choices[0].FinishReason,
+		ToolCalls:    fromChatCompletionToolCalls(result.Choices[0].Message.ToolCalls),
+		Usage:        result.Usage.toUsage(),
+		Reasoning:    result.Choices[0].Message.text(),
+	}, nil
+}
+
+// streamChatCompletion performs a streaming chat completion, calling onChunk
+// for every content or reasoning delta. Tool-call fragments are collected by index and
+// delivered with the finishing chunk. It returns ctx.Err() if the context is
+// cancelled.
+func streamChatCompletion(ctx context.Context, info types.ProviderInfo, endpoint string, headers map[string]string, req types.ChatRequest, onChunk func(types.StreamChunk)) error {
+	req, err := loadAttachments(req)
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, info, endpoint, headers, newChatCompletionRequest(req, true, streamsUsage(info)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := sse.NewDecoder(resp.Body, info.Name)
+	var calls toolCallBuilder
+	for {
+		event, err := dec.Next()
+		if err != nil {
+			// Some servers end the stream without a finish_reason.
+			if pending := calls.flush(); len(pending) > 0 && err == io.EOF {
+				onChunk(types.StreamChunk{ToolCalls: pending})
+			}
+			return streamError(ctx, info.Name, err)
+		}
+		var chunk chatCompletionChunk
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return errors.NewAIServiceError(info.Name, "stream", err)
+		}
+		if len(chunk.Choices) > 0 {
+			choice := chunk.Choices[0]
+			for _, call := range choice.Delta.ToolCalls {
+				calls.add(call.Index, call.ID, call.Function.Name, call.Function.Arguments)
+			}
+			out := types.StreamChunk{Content: choice.Delta.Content, Reasoning: choice.Delta.text(), FinishReason: choice.FinishReason}
+			if choice.FinishReason != "" {
+				out.ToolCalls = calls.flush()
+			}
+			if out.Content != "" || out.Reasoning != "" || out.FinishReason != "" {
+				onChunk(out)
+			}
+		}
+		// With include_usage the totals come in a last chunk with no choices.
+		if usage := chunk.Usage.toUsage(); usage != nil {
+			onChunk(types.StreamChunk{Usage: usage})
+		}
+	}
+}
+
+// streamError maps the error that ended a stream: nil for a clean end,
+// ctx.Err() on cancellation, domain errors unchanged, anything else wrapped.
+func streamError(ctx context.Context, provider string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == io.EOF {
+		return nil
+	}
+	var dErr *errors.DomainError
+	if stderrors.As(err, &dErr) {
+		return err
+	}
+	return errors.NewAIServiceError(provider, "stream", err)
+}
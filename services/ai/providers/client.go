@@ -0,0 +1,183 @@
+package providers
+
+// client.go - Shared HTTP client factory applying each provider's network
+// settings: proxy, extra CA bundle and connect/read timeouts.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/httplog"
+	"aichat/services/ai/types"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	clientsMu sync.Mutex
+	// clients caches one client per distinct settings so providers sharing
+	// them also share connections.
+	clients = map[string]*http.Client{}
+)
+
+// NewHTTPClient returns the client for a provider's network settings (nil
+// for the defaults). Clients are cached and safe to share. Headers in the
+// settings are not applied here; doRequest adds them to each request.
+func NewHTTPClient(settings *types.NetworkSettings) (*http.Client, error) {
+	var key []byte
+	if settings != nil {
+		key, _ = json.Marshal(settings)
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if client, ok := clients[string(key)]; ok {
+		return client, nil
+	}
+	transport, readTimeout, err := newTransport(settings)
+	if err != nil {
+		return nil, err
+	}
+	var rt http.RoundTripper = transport
+	if readTimeout > 0 {
+		rt = &idleTimeoutTransport{base: transport, timeout: readTimeout}
+	}
+	client := &http.Client{Transport: httplog.Wrap(rt)}
+	clients[string(key)] = client
+	return client, nil
+}
+
+// newTransport builds a transport from settings, also returning the read
+// timeout that the caller enforces on response bodies.
+func newTransport(settings *types.NetworkSettings) (*http.Transport, time.Duration, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if settings == nil {
+		return transport, 0, nil
+	}
+	connectTimeout, err := parseTimeout("connect_timeout", settings.ConnectTimeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	readTimeout, err := parseTimeout("read_timeout", settings.ReadTimeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	if connectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+	transport.ResponseHeaderTimeout = readTimeout
+	if settings.Proxy != "" {
+		proxy, err := url.Parse(settings.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, 0, errors.NewConfigurationError("network.proxy", fmt.Sprintf("invalid proxy URL %q", settings.Proxy))
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if settings.CAFile != "" {
+		pem, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, 0, errors.NewConfigurationError("network.ca_file", fmt.Sprintf("cannot read %s: %v", settings.CAFile, err))
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, 0, errors.NewConfigurationError("network.ca_file", fmt.Sprintf("no PEM certificates in %s", settings.CAFile))
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return transport, readTimeout, nil
+}
+
+// withDefaultHeaders returns a copy of settings whose headers include every
+// default the settings do not set themselves.
+func withDefaultHeaders(settings *types.NetworkSettings, defaults map[string]string) *types.NetworkSettings {
+	out := types.NetworkSettings{}
+	if settings != nil {
+		out = *settings
+	}
+	headers := make(map[string]string, len(out.Headers)+len(defaults))
+	set := map[string]bool{}
+	for k, v := range out.Headers {
+		headers[k] = v
+		set[http.CanonicalHeaderKey(k)] = true
+	}
+	for k, v := range defaults {
+		if !set[http.CanonicalHeaderKey(k)] {
+			headers[k] = v
+		}
+	}
+	out.Headers = headers
+	return &out
+}
+
+func parseTimeout(field, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, errors.NewConfigurationError("network."+field, fmt.Sprintf("invalid duration %q", value))
+	}
+	return d, nil
+}
+
+// idleTimeoutTransport aborts a response whose body stays silent for longer
+// than timeout. Unlike http.Client.Timeout it does not cut off long streams
+// that keep delivering chunks.
+type idleTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *idleTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &idleTimeoutBody{ReadCloser: resp.Body, cancel: cancel, timeout: t.timeout}
+	body.timer = time.AfterFunc(t.timeout, func() {
+		body.expired.Store(true)
+		cancel()
+	})
+	resp.Body = body
+	return resp, nil
+}
+
+type idleTimeoutBody struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	timeout time.Duration
+	expired atomic.Bool
+}
+
+// Read reports an expired timeout as such rather than as the cancellation
+// used to interrupt the blocked read.
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && b.expired.Load() {
+		return n, fmt.Errorf("read timeout: no data for %s", b.timeout)
+	}
+	b.timer.Reset(b.timeout)
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.ReadCloser.Close()
+}
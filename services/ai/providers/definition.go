@@ -0,0 +1,118 @@
+package providers
+
+// definition.go - Applying a provider definition: its endpoint, auth scheme,
+// default headers and capabilities.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/httplog"
+	"aichat/services/ai/types"
+	"net/url"
+	"strings"
+)
+
+// normalizeAuth fills in the scheme and names left empty. Definitions
+// written before schemes existed only name a header: "Authorization" (or
+// none) means bearer, any other header the raw key. Custom names are
+// registered with the HTTP log so it redacts them.
+func normalizeAuth(info *types.ProviderInfo) {
+	if info.Auth == "" {
+		info.Auth = types.AuthBearer
+		if info.AuthHeader != "" && !strings.EqualFold(info.AuthHeader, "Authorization") {
+			info.Auth = types.AuthHeader
+		}
+	}
+	switch info.Auth {
+	case types.AuthBearer:
+		info.AuthHeader = "Authorization"
+	case types.AuthHeader:
+		httplog.AddSecretName(info.AuthHeader)
+	case types.AuthQuery:
+		if info.AuthParam == "" {
+			info.AuthParam = "key"
+		}
+		httplog.AddSecretName(info.AuthParam)
+	}
+}
+
+// authHeaders returns the header carrying apiKey, or none when the scheme
+// sends no header or there is no key.
+func authHeaders(info types.ProviderInfo, apiKey string) map[string]string {
+	if apiKey == "" {
+		return nil
+	}
+	switch info.Auth {
+	case types.AuthBearer:
+		return map[string]string{"Authorization": "Bearer " + apiKey}
+	case types.AuthHeader:
+		return map[string]string{info.AuthHeader: apiKey}
+	}
+	return nil
+}
+
+// authURL adds apiKey to endpoint under the query scheme, and otherwise
+// returns endpoint unchanged.
+func authURL(info types.ProviderInfo, endpoint, apiKey string) string {
+	if info.Auth != types.AuthQuery || apiKey == "" {
+		return endpoint
+	}
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + url.QueryEscape(info.AuthParam) + "=" + url.QueryEscape(apiKey)
+}
+
+// builtinInfo completes the definition of a built-in type. The name defaults
+// to the API's, marked " (s)" when streaming as before definitions had
+// types; the endpoint is path under BaseURL or the default root; auth is
+// what the API requires, whatever the definition says.
+func builtinInfo(info types.ProviderInfo, typ, name, defaultBase, path string, auth types.AuthScheme, authHeader string) types.ProviderInfo {
+	info.Type = typ
+	if info.Name == "" {
+		info.Name = name
+		if info.Stream {
+			info.Name += " (s)"
+		}
+	}
+	base := defaultBase
+	if info.BaseURL != "" {
+		base = strings.TrimRight(info.BaseURL, "/")
+	}
+	info.Endpoint = base + path
+	info.Auth, info.AuthHeader, info.AuthParam = auth, authHeader, ""
+	return info
+}
+
+// withProviderHeaders folds the definition's default headers into its
+// network settings, where doRequest applies them; headers the settings set
+// themselves win.
+func withProviderHeaders(info types.ProviderInfo, defaults map[string]string) *types.NetworkSettings {
+	merged := make(map[string]string, len(info.Headers)+len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range info.Headers {
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		return info.Network
+	}
+	return withDefaultHeaders(info.Network, merged)
+}
+
+// checkCapabilities rejects a request needing a capability the definition
+// declares absent, before it reaches a server that would fail obscurely.
+func checkCapabilities(info types.ProviderInfo, req types.ChatRequest) error {
+	if info.Capabilities == nil || info.Capabilities.Vision {
+		return nil
+	}
+	for _, m := range req.Messages {
+		for _, a := range m.Attachments {
+			if a.Kind == types.AttachmentImage {
+				return errors.NewValidationError("attachments", info.Name+" does not accept images")
+			}
+		}
+	}
+	return nil
+}
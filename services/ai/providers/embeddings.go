@@ -0,0 +1,83 @@
+package providers
+
+// embeddings.go - Client for the OpenAI embeddings API, shared by OpenAI and
+// OpenAI-compatible servers.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// EmbeddingBatchSize is the most inputs sent in one embeddings request. OpenAI
+// accepts far more, but local servers embed a request in one pass and slow
+// down (or run out of memory) on large ones.
+var EmbeddingBatchSize = 64
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *chatCompletionUsage `json:"usage"`
+}
+
+// embedOpenAI embeds inputs through an OpenAI-style /embeddings endpoint,
+// EmbeddingBatchSize inputs at a time, and joins the batches in input order.
+func embedOpenAI(ctx context.Context, info types.ProviderInfo, endpoint string, headers map[string]string, inputs []string, model string) (*types.EmbeddingResponse, error) {
+	out := &types.EmbeddingResponse{Model: model, Embeddings: make([]types.Embedding, 0, len(inputs))}
+	for start := 0; start < len(inputs); start += EmbeddingBatchSize {
+		batch := inputs[start:min(start+EmbeddingBatchSize, len(inputs))]
+		result, err := embedBatch(ctx, info, endpoint, headers, batch, model)
+		if err != nil {
+			return nil, err
+		}
+		if result.Model != "" {
+			out.Model = result.Model
+		}
+		out.Embeddings = append(out.Embeddings, result.Embeddings...)
+		if result.Usage != nil {
+			if out.Usage == nil {
+				out.Usage = &types.Usage{}
+			}
+			out.Usage.Add(*result.Usage)
+		}
+	}
+	return out, nil
+}
+
+// embedBatch sends one embeddings request. The server may return the vectors
+// in any order; they are placed by their index.
+func embedBatch(ctx context.Context, info types.ProviderInfo, endpoint string, headers map[string]string, inputs []string, model string) (*types.EmbeddingResponse, error) {
+	resp, err := postJSON(ctx, info, endpoint, headers, embeddingRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.NewAIServiceError(info.Name, "embed", err)
+	}
+	if len(result.Data) != len(inputs) {
+		return nil, errors.NewAIServiceError(info.Name, "embed", fmt.Errorf("got %d embeddings for %d inputs", len(result.Data), len(inputs)))
+	}
+	embeddings := make([]types.Embedding, len(inputs))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(inputs) || embeddings[d.Index] != nil {
+			return nil, errors.NewAIServiceError(info.Name, "embed", fmt.Errorf("invalid embedding index %d", d.Index))
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return &types.EmbeddingResponse{Model: result.Model, Embeddings: embeddings, Usage: result.Usage.toUsage()}, nil
+}
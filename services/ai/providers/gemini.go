@@ -0,0 +1,380 @@
+package providers
+
+// gemini.go - Google Gemini generateContent / streamGenerateContent provider.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/sse"
+	"aichat/services/ai/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	// geminiMaxStopSequences is the most stop sequences the API accepts.
+	geminiMaxStopSequences = 5
+)
+
+// GeminiType is the ProviderInfo.Type value selecting this provider.
+const GeminiType = "gemini"
+
+type GeminiProvider struct {
+	info types.ProviderInfo
+}
+
+// NewGeminiProvider builds a provider from its definition; BaseURL, when
+// set, replaces the API root.
+func NewGeminiProvider(info types.ProviderInfo) *GeminiProvider {
+	info = builtinInfo(info, GeminiType, "Gemini", geminiBaseURL, "", types.AuthHeader, "x-goog-api-key")
+	info.Network = withProviderHeaders(info, nil)
+	return &GeminiProvider{info: info}
+}
+
+func (p *GeminiProvider) Info() types.ProviderInfo {
+	return p.info
+}
+
+// geminiPart is one piece of content. Thought marks the model's thinking
+// summary, sent when thinking models are asked to include their thoughts.
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+// geminiFunctionDeclaration sends the arguments schema as
+// parametersJsonSchema, which takes plain JSON Schema like responseJsonSchema;
+// parameters only accepts Gemini's OpenAPI subset.
+type geminiFunctionDeclaration struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description,omitempty"`
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	// ResponseMimeType "application/json" asks for JSON, constrained by
+	// ResponseJSONSchema when set.
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+	ThinkingConfig     *geminiThinking `json:"thinkingConfig,omitempty"`
+}
+
+// geminiThinking sets a thinking model's token budget and asks for its
+// thought summaries, which arrive as parts marked thought.
+type geminiThinking struct {
+	IncludeThoughts bool `json:"includeThoughts"`
+	ThinkingBudget  int  `json:"thinkingBudget"`
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	ModelVersion  string `json:"modelVersion"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// text concatenates the answer parts of the first candidate.
+func (r *geminiResponse) text() string {
+	return r.partsText(false)
+}
+
+// thoughts concatenates the thought parts of the first candidate.
+func (r *geminiResponse) thoughts() string {
+	return r.partsText(true)
+}
+
+func (r *geminiResponse) partsText(thought bool) string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		if part.Thought == thought {
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String()
+}
+
+// toolCalls returns the function calls of the first candidate. Gemini does not
+// assign call IDs, so calls are numbered from first; a stream passes the
+// number of calls its earlier chunks held, keeping IDs unique in a response.
+func (r *geminiResponse) toolCalls(first int) []types.ToolCall {
+	if len(r.Candidates) == 0 {
+		return nil
+	}
+	var calls []types.ToolCall
+	for _, part := range r.Candidates[0].Content.Parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+		calls = append(calls, types.ToolCall{
+			ID:        fmt.Sprintf("%s-%d", part.FunctionCall.Name, first+len(calls)),
+			Name:      part.FunctionCall.Name,
+			Arguments: string(toolArguments(string(part.FunctionCall.Args))),
+		})
+	}
+	return calls
+}
+
+// usage converts usageMetadata; thinking tokens are billed as output.
+func (r *geminiResponse) usage() *types.Usage {
+	if r.UsageMetadata == nil {
+		return nil
+	}
+	return &types.Usage{
+		PromptTokens:     r.UsageMetadata.PromptTokenCount,
+		CompletionTokens: r.UsageMetadata.CandidatesTokenCount + r.UsageMetadata.ThoughtsTokenCount,
+		ReasoningTokens:  r.UsageMetadata.ThoughtsTokenCount,
+	}
+}
+
+func (r *geminiResponse) finishReason() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	return r.Candidates[0].FinishReason
+}
+
+// geminiParts converts a message to parts: attachments are sent inline as
+// base64 data, tool calls become functionCall parts and tool results a
+// functionResponse, whose response must be an object, so plain results are
+// wrapped as {"content": ...}.
+func geminiParts(m types.Message) []geminiPart {
+	if m.Role == "tool" {
+		response := json.RawMessage(m.Content)
+		if !json.Valid(response) || !strings.HasPrefix(strings.TrimSpace(m.Content), "{") {
+			response, _ = json.Marshal(map[string]string{"content": m.Content})
+		}
+		return []geminiPart{{FunctionResponse: &geminiFunctionResponse{Name: m.Name, Response: response}}}
+	}
+	var parts []geminiPart
+	if m.Content != "" || (len(m.ToolCalls) == 0 && len(m.Attachments) == 0) {
+		parts = append(parts, geminiPart{Text: m.Content})
+	}
+	for _, a := range m.Attachments {
+		parts = append(parts, geminiPart{InlineData: &geminiBlob{MimeType: a.MediaType, Data: base64Data(a)}})
+	}
+	for _, call := range m.ToolCalls {
+		parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{Name: call.Name, Args: toolArguments(call.Arguments)}})
+	}
+	return parts
+}
+
+// newGeminiRequest maps role/content history onto Gemini's contents/parts.
+// System messages become systemInstruction, "assistant" becomes "model",
+// tool results are sent by the user, and consecutive turns from the same
+// role are merged into one content entry.
+func newGeminiRequest(req types.ChatRequest) geminiRequest {
+	var out geminiRequest
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			if out.SystemInstruction == nil {
+				out.SystemInstruction = &geminiContent{}
+			}
+			out.SystemInstruction.Parts = append(out.SystemInstruction.Parts, geminiPart{Text: m.Content})
+			continue
+		}
+		role := "user"
+		if m.Role == "assistant" {
+			role = "model"
+		}
+		if n := len(out.Contents); n > 0 && out.Contents[n-1].Role == role {
+			out.Contents[n-1].Parts = append(out.Contents[n-1].Parts, geminiParts(m)...)
+			continue
+		}
+		out.Contents = append(out.Contents, geminiContent{Role: role, Parts: geminiParts(m)})
+	}
+	if len(req.Tools) > 0 {
+		tool := geminiTool{}
+		for _, t := range req.Tools {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, geminiFunctionDeclaration{Name: t.Name, Description: t.Description, ParametersJSONSchema: t.Parameters})
+		}
+		out.Tools = []geminiTool{tool}
+	}
+	if req.Temperature != nil || req.TopP != nil || req.MaxTokens > 0 || len(req.Stop) > 0 || req.ResponseFormat != nil || req.ThinkingBudget > 0 {
+		out.GenerationConfig = &geminiGenerationConfig{
+			Temperature:     req.Temperature,
+			TopP:            req.TopP,
+			MaxOutputTokens: req.MaxTokens,
+			StopSequences:   req.Stop[:min(len(req.Stop), geminiMaxStopSequences)],
+		}
+		if f := req.ResponseFormat; f != nil {
+			out.GenerationConfig.ResponseMimeType = "application/json"
+			out.GenerationConfig.ResponseJSONSchema = f.Schema
+		}
+		if req.ThinkingBudget > 0 {
+			out.GenerationConfig.ThinkingConfig = &geminiThinking{IncludeThoughts: true, ThinkingBudget: req.ThinkingBudget}
+		}
+	}
+	return out
+}
+
+func (p *GeminiProvider) headers(apiKey string) map[string]string {
+	return map[string]string{"x-goog-api-key": apiKey}
+}
+
+func (p *GeminiProvider) methodURL(model, method string) string {
+	return strings.TrimRight(p.info.Endpoint, "/") + "/models/" + url.PathEscape(model) + ":" + method
+}
+
+func (p *GeminiProvider) responseError(r *geminiResponse) error {
+	return errors.NewAIServiceError(p.info.Name, "generate content", fmt.Errorf("%s (%d): %s", r.Error.Status, r.Error.Code, r.Error.Message))
+}
+
+func (p *GeminiProvider) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	req, err := loadAttachments(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := postJSON(ctx, p.info, p.methodURL(req.Model, "generateContent"), p.headers(apiKey), newGeminiRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.NewAIServiceError(p.info.Name, "decode response", err)
+	}
+	if result.Error != nil {
+		return nil, p.responseError(&result)
+	}
+	model := result.ModelVersion
+	if model == "" {
+		model = req.Model
+	}
+	return &types.ChatResponse{
+		Model:        model,
+		Content:      result.text(),
+		FinishReason: result.finishReason(),
+		ToolCalls:    result.toolCalls(0),
+		Usage:        result.usage(),
+		Reasoning:    result.thoughts(),
+	}, nil
+}
+
+// StreamMessage requests streamGenerateContent with alt=sse so every partial
+// GenerateContentResponse arrives as its own event.
+func (p *GeminiProvider) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	req, err := loadAttachments(req)
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, p.info, p.methodURL(req.Model, "streamGenerateContent")+"?alt=sse", p.headers(apiKey), newGeminiRequest(req))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := sse.NewDecoder(resp.Body, p.info.Name)
+	calls := 0 // Tool calls so far, numbering their IDs across chunks
+	for {
+		event, err := dec.Next()
+		if err != nil {
+			return streamError(ctx, p.info.Name, err)
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return errors.NewAIServiceError(p.info.Name, "stream", err)
+		}
+		// Function calls are never split across chunks. Every chunk repeats
+		// the running usage totals, so they are only passed on with the last.
+		out := types.StreamChunk{Content: chunk.text(), Reasoning: chunk.thoughts(), FinishReason: chunk.finishReason(), ToolCalls: chunk.toolCalls(calls)}
+		calls += len(out.ToolCalls)
+		if out.FinishReason != "" {
+			out.Usage = chunk.usage()
+		}
+		if out.Content != "" || out.Reasoning != "" || out.FinishReason != "" || len(out.ToolCalls) > 0 {
+			onChunk(out)
+		}
+	}
+}
+
+// ListModels queries the models endpoint, keeping only models that support
+// generateContent (embedding and other models are listed there too).
+func (p *GeminiProvider) ListModels(ctx context.Context, apiKey string) ([]types.ModelInfo, error) {
+	endpoint := strings.TrimRight(p.info.Endpoint, "/") + "/models?pageSize=1000"
+	resp, err := doRequest(ctx, p.info, http.MethodGet, endpoint, p.headers(apiKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			InputTokenLimit            int      `json:"inputTokenLimit"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.NewAIServiceError(p.info.Name, "list models", err)
+	}
+	var models []types.ModelInfo
+	for _, m := range result.Models {
+		if !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+			continue
+		}
+		models = append(models, types.ModelInfo{
+			ID:            strings.TrimPrefix(m.Name, "models/"),
+			OwnedBy:       "google",
+			ContextWindow: m.InputTokenLimit,
+		})
+	}
+	return models, nil
+}
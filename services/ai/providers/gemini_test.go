@@ -0,0 +1,184 @@
+package providers
+
+import (
+	"aichat/services/ai/types"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonEqual reports whether a and b hold the same JSON value.
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var av, bv any
+	if err := json.Unmarshal(a, &av); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+func TestNewGeminiRequest(t *testing.T) {
+	temperature := 0.5
+	tests := []struct {
+		name string
+		req  types.ChatRequest
+		want string
+	}{
+		{
+			name: "system messages become systemInstruction",
+			req: types.ChatRequest{Messages: []types.Message{
+				{Role: "system", Content: "Be brief."},
+				{Role: "system", Content: "Answer in English."},
+				{Role: "user", Content: "hi"},
+			}},
+			want: `{"systemInstruction":{"parts":[{"text":"Be brief."},{"text":"Answer in English."}]},
+				"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`,
+		},
+		{
+			name: "assistant turns use the model role",
+			req: types.ChatRequest{Messages: []types.Message{
+				{Role: "user", Content: "hi"},
+				{Role: "assistant", Content: "hello"},
+				{Role: "user", Content: "bye"},
+			}},
+			want: `{"contents":[
+				{"role":"user","parts":[{"text":"hi"}]},
+				{"role":"model","parts":[{"text":"hello"}]},
+				{"role":"user","parts":[{"text":"bye"}]}]}`,
+		},
+		{
+			name: "consecutive turns of a role are merged",
+			req: types.ChatRequest{Messages: []types.Message{
+				{Role: "user", Content: "one"},
+				{Role: "user", Content: "two"},
+				{Role: "assistant", Content: "three"},
+				{Role: "assistant", Content: "four"},
+			}},
+			want: `{"contents":[
+				{"role":"user","parts":[{"text":"one"},{"text":"two"}]},
+				{"role":"model","parts":[{"text":"three"},{"text":"four"}]}]}`,
+		},
+		{
+			name: "tool calls and results",
+			req: types.ChatRequest{Messages: []types.Message{
+				{Role: "user", Content: "weather?"},
+				{Role: "assistant", ToolCalls: []types.ToolCall{
+					{ID: "get_weather-0", Name: "get_weather", Arguments: `{"city":"Oslo"}`},
+					{ID: "get_time-1", Name: "get_time"},
+				}},
+				{Role: "tool", ToolCallID: "get_weather-0", Name: "get_weather", Content: `{"temp":21}`},
+				{Role: "tool", ToolCallID: "get_time-1", Name: "get_time", Content: "noon"},
+			}},
+			want: `{"contents":[
+				{"role":"user","parts":[{"text":"weather?"}]},
+				{"role":"model","parts":[
+					{"functionCall":{"name":"get_weather","args":{"city":"Oslo"}}},
+					{"functionCall":{"name":"get_time","args":{}}}]},
+				{"role":"user","parts":[
+					{"functionResponse":{"name":"get_weather","response":{"temp":21}}},
+					{"functionResponse":{"name":"get_time","response":{"content":"noon"}}}]}]}`,
+		},
+		{
+			name: "tools and generation config",
+			req: types.ChatRequest{
+				Messages:       []types.Message{{Role: "user", Content: "hi"}},
+				Tools:          []types.Tool{{Name: "now", Description: "Current time", Parameters: json.RawMessage(`{"type":"object"}`)}},
+				Temperature:    &temperature,
+				MaxTokens:      100,
+				Stop:           []string{"a", "b", "c", "d", "e", "f"},
+				ResponseFormat: &types.ResponseFormat{Schema: json.RawMessage(`{"type":"object"}`)},
+				ThinkingBudget: 1024,
+			},
+			want: `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],
+				"tools":[{"functionDeclarations":[{"name":"now","description":"Current time","parametersJsonSchema":{"type":"object"}}]}],
+				"generationConfig":{"temperature":0.5,"maxOutputTokens":100,"stopSequences":["a","b","c","d","e"],
+					"responseMimeType":"application/json","responseJsonSchema":{"type":"object"},
+					"thinkingConfig":{"includeThoughts":true,"thinkingBudget":1024}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(newGeminiRequest(tt.req))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !jsonEqual(t, got, []byte(tt.want)) {
+				t.Errorf("request = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeminiStream(t *testing.T) {
+	// A streamGenerateContent response as sent with alt=sse: thoughts, text
+	// split over chunks, function calls in two chunks and running usage.
+	events := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Let me think.","thought":true}]}}],"usageMetadata":{"promptTokenCount":9}}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Checking "}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"now."},{"functionCall":{"name":"get_weather","args":{"city":"Oslo"}}}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Bergen"}}}]},"finishReason":"STOP"}],` +
+			`"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":12,"thoughtsTokenCount":5}}`,
+	}
+	var gotPath, gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotKey = r.URL.RequestURI(), r.Header.Get("x-goog-api-key")
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			io.WriteString(w, "data: "+e+"\r\n\r\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	p := NewGeminiProvider(types.ProviderInfo{BaseURL: server.URL})
+	req := types.ChatRequest{Model: "gemini-2.5-flash", Messages: []types.Message{{Role: "user", Content: "weather?"}}}
+	var chunks []types.StreamChunk
+	if err := p.StreamMessage(context.Background(), req, "test-key", func(c types.StreamChunk) { chunks = append(chunks, c) }); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if gotPath != "/models/gemini-2.5-flash:streamGenerateContent?alt=sse" || gotKey != "test-key" {
+		t.Errorf("requested %s with key %q", gotPath, gotKey)
+	}
+	want := []types.StreamChunk{
+		{Reasoning: "Let me think."},
+		{Content: "Checking "},
+		{Content: "now.", ToolCalls: []types.ToolCall{{ID: "get_weather-0", Name: "get_weather", Arguments: `{"city":"Oslo"}`}}},
+		{
+			ToolCalls:    []types.ToolCall{{ID: "get_weather-1", Name: "get_weather", Arguments: `{"city":"Bergen"}`}},
+			FinishReason: "STOP",
+			Usage:        &types.Usage{PromptTokens: 9, CompletionTokens: 17, ReasoningTokens: 5},
+		},
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks:\n%+v\nwant\n%+v", chunks, want)
+	}
+}
+
+func TestGeminiStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"candidates":[{"content":{"parts":[{"text":"partial"}]}}]}`+"\n\n")
+		io.WriteString(w, `data: {"error":{"code":500,"message":"internal","status":"INTERNAL"}}`+"\n\n")
+	}))
+	defer server.Close()
+
+	p := NewGeminiProvider(types.ProviderInfo{BaseURL: server.URL})
+	var content strings.Builder
+	err := p.StreamMessage(context.Background(), types.ChatRequest{Model: "m", Messages: []types.Message{{Role: "user", Content: "hi"}}}, "k",
+		func(c types.StreamChunk) { content.WriteString(c.Content) })
+	if err == nil || !strings.Contains(err.Error(), "internal") {
+		t.Errorf("error = %v, want the stream's error", err)
+	}
+	if content.String() != "partial" {
+		t.Errorf("content = %q, want the text before the error", content.String())
+	}
+}
@@ -0,0 +1,154 @@
+package providers
+
+// openai_compatible.go - Configurable provider for any server exposing the
+// OpenAI chat completions API (Ollama, llama.cpp, vLLM, LM Studio, ...).
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OpenAICompatibleType is the ProviderInfo.Type value selecting this provider.
+const OpenAICompatibleType = "openai-compatible"
+
+type OpenAICompatibleProvider struct {
+	info    types.ProviderInfo
+	baseURL string
+}
+
+// NewOpenAICompatibleProvider builds a provider from its definition. The API
+// root is BaseURL, or else Endpoint, which may be either the root
+// (http://localhost:11434/v1) or the full chat completions URL; both resolve
+// to the same base. Every auth scheme is supported.
+func NewOpenAICompatibleProvider(info types.ProviderInfo) *OpenAICompatibleProvider {
+	root := info.BaseURL
+	if root == "" {
+		root = info.Endpoint
+	}
+	base := strings.TrimSuffix(strings.TrimRight(root, "/"), "/chat/completions")
+	if info.Endpoint == "" {
+		info.Endpoint = base
+	}
+	normalizeAuth(&info)
+	info.Network = withProviderHeaders(info, nil)
+	info.Type = OpenAICompatibleType
+	return &OpenAICompatibleProvider{info: info, baseURL: base}
+}
+
+func (p *OpenAICompatibleProvider) Info() types.ProviderInfo {
+	return p.info
+}
+
+// key falls back to the key stored with the provider; a server running
+// keyless gets none at all.
+func (p *OpenAICompatibleProvider) key(apiKey string) string {
+	if apiKey == "" {
+		return p.info.APIKey
+	}
+	return apiKey
+}
+
+// url returns the URL of path, carrying the key under the query scheme.
+func (p *OpenAICompatibleProvider) url(path, apiKey string) string {
+	return authURL(p.info, p.baseURL+path, p.key(apiKey))
+}
+
+func (p *OpenAICompatibleProvider) headers(apiKey string) map[string]string {
+	return authHeaders(p.info, p.key(apiKey))
+}
+
+func (p *OpenAICompatibleProvider) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	if err := checkCapabilities(p.info, req); err != nil {
+		return nil, err
+	}
+	return sendChatCompletion(ctx, p.info, p.url("/chat/completions", apiKey), p.headers(apiKey), req)
+}
+
+func (p *OpenAICompatibleProvider) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	if err := checkCapabilities(p.info, req); err != nil {
+		return err
+	}
+	// A server declared unable to stream answers in one piece.
+	if c := p.info.Capabilities; c != nil && c.Streaming != nil && !*c.Streaming {
+		resp, err := p.SendMessage(ctx, req, apiKey)
+		if err != nil {
+			return err
+		}
+		onChunk(types.StreamChunk{Content: resp.Content, Reasoning: resp.Reasoning, ToolCalls: resp.ToolCalls, FinishReason: resp.FinishReason, Usage: resp.Usage})
+		return nil
+	}
+	return streamChatCompletion(ctx, p.info, p.url("/chat/completions", apiKey), p.headers(apiKey), req, onChunk)
+}
+
+// ListModels queries the server's /models endpoint.
+func (p *OpenAICompatibleProvider) ListModels(ctx context.Context, apiKey string) ([]types.ModelInfo, error) {
+	return listOpenAIModels(ctx, p.info, p.url("/models", apiKey), p.headers(apiKey))
+}
+
+// Embed queries the server's /embeddings endpoint. Ollama, llama.cpp and vLLM
+// serve it for embedding models (llama.cpp only when started with --embedding).
+// A definition declaring no embeddings capability fails without a request.
+func (p *OpenAICompatibleProvider) Embed(ctx context.Context, inputs []string, model, apiKey string) (*types.EmbeddingResponse, error) {
+	if c := p.info.Capabilities; c != nil && !c.Embeddings {
+		return nil, errors.NewValidationError("model", p.info.Name+" does not serve embeddings")
+	}
+	return embedOpenAI(ctx, p.info, p.url("/embeddings", apiKey), p.headers(apiKey), inputs, model)
+}
+
+// listOpenAIModels fetches an OpenAI-style {"data": [{"id": ...}]} model list.
+func listOpenAIModels(ctx context.Context, info types.ProviderInfo, endpoint string, headers map[string]string) ([]types.ModelInfo, error) {
+	resp, err := doRequest(ctx, info, http.MethodGet, endpoint, headers, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// The context length is not part of the OpenAI schema; OpenRouter, vLLM
+	// and LM Studio each report it under their own name. Pricing (USD per
+	// token, as strings) and modalities are OpenRouter extensions.
+	var result struct {
+		Data []struct {
+			ID               string `json:"id"`
+			OwnedBy          string `json:"owned_by"`
+			ContextLength    int    `json:"context_length"`
+			MaxModelLen      int    `json:"max_model_len"`
+			MaxContextLength int    `json:"max_context_length"`
+			Pricing          struct {
+				Prompt     string `json:"prompt"`
+				Completion string `json:"completion"`
+			} `json:"pricing"`
+			Architecture struct {
+				InputModalities []string `json:"input_modalities"`
+			} `json:"architecture"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.NewAIServiceError(info.Name, "list models", err)
+	}
+	models := make([]types.ModelInfo, len(result.Data))
+	for i, m := range result.Data {
+		models[i] = types.ModelInfo{
+			ID:            m.ID,
+			OwnedBy:       m.OwnedBy,
+			ContextWindow: max(m.ContextLength, m.MaxModelLen, m.MaxContextLength),
+			InputPrice:    perMillion(m.Pricing.Prompt),
+			OutputPrice:   perMillion(m.Pricing.Completion),
+			Modalities:    m.Architecture.InputModalities,
+		}
+	}
+	return models, nil
+}
+
+// perMillion converts a per-token price string to USD per million tokens,
+// returning 0 for missing or malformed values.
+func perMillion(perToken string) float64 {
+	price, err := strconv.ParseFloat(perToken, 64)
+	if err != nil || price < 0 {
+		return 0
+	}
+	return price * 1e6
+}
@@ -3,105 +3,49 @@ package providers
 
 import (
 	"aichat/services/ai/types"
-	"bytes"
-	"encoding/json"
-	"errors"
-	"io"
-	"net/http"
+	"context"
+	"strings"
 )
 
+// openRouterHeaders attribute requests to the app on OpenRouter; headers of
+// the definition and its network settings override them.
+var openRouterHeaders = map[string]string{
+	"HTTP-Referer": "https://github.com/go-ai-cli",
+	"X-Title":      "Go AI CLI",
+}
+
+// OpenRouterType is the ProviderInfo.Type value selecting this provider.
+const OpenRouterType = "openrouter"
+
 type OpenRouterProvider struct {
 	info types.ProviderInfo
 }
 
-func NewOpenRouterProvider(stream bool) *OpenRouterProvider {
-	endpoint := "https://openrouter.ai/api/v1/chat/completions"
-	name := "OpenRouter"
-	if stream {
-		name += " (s)"
-	}
-	return &OpenRouterProvider{
-		info: types.ProviderInfo{
-			Name:     name,
-			Endpoint: endpoint,
-			Stream:   stream,
-		},
-	}
+// NewOpenRouterProvider builds a provider from its definition; BaseURL, when
+// set, replaces https://openrouter.ai/api/v1.
+func NewOpenRouterProvider(info types.ProviderInfo) *OpenRouterProvider {
+	info = builtinInfo(info, OpenRouterType, "OpenRouter", "https://openrouter.ai/api/v1", "/chat/completions", types.AuthBearer, "Authorization")
+	info.Network = withProviderHeaders(info, openRouterHeaders)
+	return &OpenRouterProvider{info: info}
 }
 
 func (p *OpenRouterProvider) Info() types.ProviderInfo {
 	return p.info
 }
 
-func (p *OpenRouterProvider) SendMessage(messages []map[string]string, apiKey, model string) (string, error) {
-	body := map[string]interface{}{
-		"model":    model,
-		"messages": messages,
-	}
-	jsonBody, _ := json.Marshal(body)
-	req, err := http.NewRequest("POST", p.info.Endpoint, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
-	var result map[string]interface{}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return "", err
-	}
-	choices, ok := result["choices"].([]interface{})
-	if !ok || len(choices) == 0 {
-		return "", errors.New("no choices in response")
-	}
-	msg, ok := choices[0].(map[string]interface{})["message"].(map[string]interface{})["content"].(string)
-	if !ok {
-		return "", errors.New("no message content in response")
-	}
-	return msg, nil
+func (p *OpenRouterProvider) headers(apiKey string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + apiKey}
 }
 
-func (p *OpenRouterProvider) StreamMessage(messages []map[string]string, apiKey, model string, onData func(data string)) error {
-	body := map[string]interface{}{
-		"model":    model,
-		"messages": messages,
-		"stream":   true,
-	}
-	jsonBody, _ := json.Marshal(body)
-	req, err := http.NewRequest("POST", p.info.Endpoint, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	dec := json.NewDecoder(resp.Body)
-	for {
-		var chunk map[string]interface{}
-		if err := dec.Decode(&chunk); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		if data, ok := chunk["choices"].([]interface{}); ok && len(data) > 0 {
-			delta := data[0].(map[string]interface{})["delta"].(map[string]interface{})
-			if content, ok := delta["content"].(string); ok {
-				onData(content)
-			}
-		}
-	}
-	return nil
+func (p *OpenRouterProvider) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	return sendChatCompletion(ctx, p.info, p.info.Endpoint, p.headers(apiKey), req)
 }
 
+func (p *OpenRouterProvider) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	return streamChatCompletion(ctx, p.info, p.info.Endpoint, p.headers(apiKey), req, onChunk)
+}
+
+// ListModels queries the /models endpoint next to the chat completions URL.
+func (p *OpenRouterProvider) ListModels(ctx context.Context, apiKey string) ([]types.ModelInfo, error) {
+	return listOpenAIModels(ctx, p.info, strings.TrimSuffix(p.info.Endpoint, "/chat/completions")+"/models", p.headers(apiKey))
+}
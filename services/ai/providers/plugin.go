@@ -0,0 +1,423 @@
+package providers
+
+// plugin.go - Providers implemented by an external executable speaking
+// JSON-RPC 2.0 over stdio; the protocol is described in
+// documentation/plugins.md.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"bufio"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PluginType is the ProviderInfo.Type value selecting a PluginProvider; its
+// Command and Args launch the plugin.
+const PluginType = "plugin"
+
+// PluginProtocolVersion is the protocol version a plugin must report from
+// "info".
+const PluginProtocolVersion = 1
+
+// pluginStartTimeout bounds the "info" handshake of a freshly started
+// plugin.
+const pluginStartTimeout = 10 * time.Second
+
+// PluginProvider forwards requests to a plugin subprocess. The process is
+// started on first use and serves concurrent requests; if it exits, pending
+// requests fail and the next request starts it again.
+type PluginProvider struct {
+	info types.ProviderInfo
+	mu   sync.Mutex
+	proc *pluginProcess
+}
+
+// NewPluginProvider builds a provider from its configuration. The plugin is
+// not launched until a request needs it.
+func NewPluginProvider(info types.ProviderInfo) *PluginProvider {
+	info.Type = PluginType
+	return &PluginProvider{info: info}
+}
+
+func (p *PluginProvider) Info() types.ProviderInfo {
+	return p.info
+}
+
+// pluginParams are the params of "send" and "stream".
+type pluginParams struct {
+	Request pluginRequest `json:"request"`
+	APIKey  string        `json:"api_key,omitempty"`
+}
+
+// pluginRequest is a ChatRequest as sent to plugins: attachments carry their
+// data, base64 encoded, since a plugin may not see the app's files.
+type pluginRequest struct {
+	types.ChatRequest
+	Messages []pluginMessage `json:"messages"`
+}
+
+type pluginMessage struct {
+	types.Message
+	Attachments []pluginAttachment `json:"attachments,omitempty"`
+}
+
+type pluginAttachment struct {
+	types.Attachment
+	Data []byte `json:"data"`
+}
+
+// newPluginParams reads the attachments of req into the params of a call.
+func newPluginParams(req types.ChatRequest, apiKey string) (pluginParams, error) {
+	req, err := loadAttachments(req)
+	if err != nil {
+		return pluginParams{}, err
+	}
+	wire := pluginRequest{ChatRequest: req, Messages: make([]pluginMessage, len(req.Messages))}
+	for i, m := range req.Messages {
+		wire.Messages[i].Message = m
+		for _, a := range m.Attachments {
+			wire.Messages[i].Attachments = append(wire.Messages[i].Attachments, pluginAttachment{Attachment: a, Data: a.Data})
+		}
+	}
+	return pluginParams{Request: wire, APIKey: apiKey}, nil
+}
+
+// pluginInfo is the result of "info".
+type pluginInfo struct {
+	Protocol  int    `json:"protocol"`
+	Name      string `json:"name"`
+	Streaming bool   `json:"streaming"`
+}
+
+func (p *PluginProvider) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	proc, err := p.process(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params, err := newPluginParams(req, p.key(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	result, err := proc.call(ctx, "send", params, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp types.ChatResponse
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, errors.NewAIStreamError(p.info.Name, "invalid_result", "malformed send result: "+err.Error(), false)
+	}
+	return &resp, nil
+}
+
+// StreamMessage streams through "stream", or delivers the "send" result as
+// a single chunk when the plugin does not stream.
+func (p *PluginProvider) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	proc, err := p.process(ctx)
+	if err != nil {
+		return err
+	}
+	if !proc.streaming {
+		resp, err := p.SendMessage(ctx, req, apiKey)
+		if err != nil {
+			return err
+		}
+		onChunk(types.StreamChunk{Content: resp.Content, Reasoning: resp.Reasoning, ToolCalls: resp.ToolCalls, FinishReason: resp.FinishReason, Usage: resp.Usage})
+		return nil
+	}
+	params, err := newPluginParams(req, p.key(apiKey))
+	if err != nil {
+		return err
+	}
+	_, err = proc.call(ctx, "stream", params, onChunk)
+	return err
+}
+
+// key falls back to the key stored with the provider.
+func (p *PluginProvider) key(apiKey string) string {
+	if apiKey == "" {
+		return p.info.APIKey
+	}
+	return apiKey
+}
+
+// process returns the running plugin, starting it if needed.
+func (p *PluginProvider) process(ctx context.Context) (*pluginProcess, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc != nil && !p.proc.exited() {
+		return p.proc, nil
+	}
+	if p.info.Command == "" {
+		return nil, errors.NewConfigurationError("providers."+p.info.Name+".command", "no plugin command configured")
+	}
+	proc, err := startPlugin(p.info)
+	if err != nil {
+		return nil, err
+	}
+	hsCtx, cancel := context.WithTimeout(ctx, pluginStartTimeout)
+	defer cancel()
+	result, err := proc.call(hsCtx, "info", nil, nil)
+	var info pluginInfo
+	if err == nil {
+		err = json.Unmarshal(result, &info)
+	}
+	if err == nil && info.Protocol != PluginProtocolVersion {
+		err = fmt.Errorf("plugin speaks protocol %d, expected %d", info.Protocol, PluginProtocolVersion)
+	}
+	if err != nil {
+		proc.kill()
+		return nil, errors.NewAIServiceError(p.info.Name, "plugin handshake", err)
+	}
+	proc.streaming = info.Streaming
+	p.proc = proc
+	return proc, nil
+}
+
+// rpcRequest is a request (with ID) or notification (without) to a plugin.
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// rpcMessage is anything a plugin writes: a response to a request, or a
+// "chunk" notification for a stream.
+type rpcMessage struct {
+	ID     *int64          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// rpcError is a JSON-RPC error. Data may carry the HTTP status and
+// Retry-After seconds of an upstream failure, so rate limits and server
+// errors are classified like those of built-in providers.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    *struct {
+		Status     int     `json:"status"`
+		RetryAfter float64 `json:"retry_after"`
+	} `json:"data,omitempty"`
+}
+
+func (e *rpcError) toError(provider string) error {
+	if e.Data != nil && e.Data.Status != 0 {
+		return errors.NewAIHTTPError(provider, e.Data.Status, e.Message, time.Duration(e.Data.RetryAfter*float64(time.Second)))
+	}
+	return errors.NewAIStreamError(provider, strconv.Itoa(e.Code), e.Message, false)
+}
+
+// chunkParams are the params of a "chunk" notification.
+type chunkParams struct {
+	ID    int64             `json:"id"`
+	Chunk types.StreamChunk `json:"chunk"`
+}
+
+// pluginCall is a request waiting for its response.
+type pluginCall struct {
+	done    chan rpcMessage
+	onChunk func(types.StreamChunk)
+
+	mu     sync.Mutex
+	closed bool // Set by forget; no chunks are delivered after it
+}
+
+// pluginProcess is one running plugin and its in-flight requests.
+type pluginProcess struct {
+	name      string
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	writeMu   sync.Mutex
+	streaming bool
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pluginCall
+	err     error // Why the process exited; nil while it runs
+}
+
+func startPlugin(info types.ProviderInfo) (*pluginProcess, error) {
+	cmd := exec.Command(info.Command, info.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.NewAIServiceError(info.Name, "plugin start", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.NewAIServiceError(info.Name, "plugin start", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, errors.NewAIServiceError(info.Name, "plugin start", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.NewAIServiceError(info.Name, "plugin start", err)
+	}
+	proc := &pluginProcess{name: info.Name, cmd: cmd, stdin: stdin, pending: map[int64]*pluginCall{}}
+	go func() {
+		// The plugin's own diagnostics go to the application log.
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			slog.Debug("Plugin output", "plugin", info.Name, "line", scanner.Text())
+		}
+	}()
+	go proc.read(stdout)
+	return proc, nil
+}
+
+// read dispatches everything the plugin writes until it exits, then fails
+// the requests still waiting.
+func (p *pluginProcess) read(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	var err error
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if len(line) > 0 {
+			p.dispatch(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if waitErr := p.cmd.Wait(); waitErr != nil {
+		err = waitErr
+	} else if err == io.EOF {
+		err = stderrors.New("plugin exited")
+	}
+	p.mu.Lock()
+	p.err = errors.NewAIServiceError(p.name, "plugin", err)
+	pending := p.pending
+	p.pending = map[int64]*pluginCall{}
+	p.mu.Unlock()
+	for _, call := range pending {
+		close(call.done)
+	}
+}
+
+func (p *pluginProcess) dispatch(line []byte) {
+	var msg rpcMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		slog.Warn("Ignoring malformed plugin message", "plugin", p.name, "error", err)
+		return
+	}
+	if msg.Method == "chunk" {
+		var params chunkParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			slog.Warn("Ignoring malformed plugin chunk", "plugin", p.name, "error", err)
+			return
+		}
+		p.mu.Lock()
+		call := p.pending[params.ID]
+		p.mu.Unlock()
+		if call == nil || call.onChunk == nil {
+			return
+		}
+		// Chunks of cancelled requests are dropped. Holding the call's lock
+		// keeps forget from returning while a chunk is being delivered.
+		call.mu.Lock()
+		defer call.mu.Unlock()
+		if !call.closed {
+			call.onChunk(params.Chunk)
+		}
+		return
+	}
+	if msg.ID == nil {
+		return
+	}
+	p.mu.Lock()
+	call := p.pending[*msg.ID]
+	delete(p.pending, *msg.ID)
+	p.mu.Unlock()
+	if call != nil {
+		call.done <- msg
+	}
+}
+
+// call sends a request and waits for its response, passing "chunk"
+// notifications for it to onChunk. A cancelled context sends "cancel" and
+// returns without waiting for the plugin to wind down.
+func (p *pluginProcess) call(ctx context.Context, method string, params any, onChunk func(types.StreamChunk)) (json.RawMessage, error) {
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		return nil, p.err
+	}
+	p.nextID++
+	id := p.nextID
+	call := &pluginCall{done: make(chan rpcMessage, 1), onChunk: onChunk}
+	p.pending[id] = call
+	p.mu.Unlock()
+
+	if err := p.write(rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		p.forget(id)
+		return nil, errors.NewAIServiceError(p.name, method, err)
+	}
+	select {
+	case msg, ok := <-call.done:
+		if !ok {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			return nil, p.err
+		}
+		if msg.Error != nil {
+			return nil, msg.Error.toError(p.name)
+		}
+		return msg.Result, nil
+	case <-ctx.Done():
+		p.forget(id)
+		_ = p.write(rpcRequest{JSONRPC: "2.0", Method: "cancel", Params: map[string]int64{"id": id}})
+		return nil, ctx.Err()
+	}
+}
+
+// forget drops a request, after which none of its chunks are delivered.
+func (p *pluginProcess) forget(id int64) {
+	p.mu.Lock()
+	call := p.pending[id]
+	delete(p.pending, id)
+	p.mu.Unlock()
+	if call != nil {
+		call.mu.Lock()
+		call.closed = true
+		call.mu.Unlock()
+	}
+}
+
+// write sends one message as a line of JSON.
+func (p *pluginProcess) write(msg rpcRequest) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err = p.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (p *pluginProcess) exited() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err != nil
+}
+
+// kill stops a plugin that failed its handshake.
+func (p *pluginProcess) kill() {
+	p.stdin.Close()
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,65 @@
+package providers
+
+// retry.go - Retry policy and rate-limit header parsing for provider requests.
+
+import (
+	"aichat/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryConfig is applied to every provider HTTP request. Only establishing
+// the response is retried, so a stream is never replayed after its first
+// byte reached the caller.
+var RetryConfig = errors.RetryConfig{
+	MaxAttempts:  5,
+	InitialDelay: time.Second,
+	MaxDelay:     30 * time.Second,
+	Backoff:      errors.ExponentialBackoff,
+}
+
+// maxRetryAfter caps server-suggested delays; a longer wait is better
+// reported than sat through.
+const maxRetryAfter = 2 * time.Minute
+
+// retryAfter returns the delay a response asks for before retrying, or 0.
+// It reads the standard Retry-After header (seconds or an HTTP date) and the
+// vendor variants: retry-after-ms, OpenAI's x-ratelimit-reset-* durations and
+// Anthropic's anthropic-ratelimit-*-reset timestamps. The reset headers are
+// only consulted for rate limits, and the longest reset wins.
+func retryAfter(h http.Header, status int, now time.Time) time.Duration {
+	var d time.Duration
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil {
+		d = time.Duration(ms * float64(time.Millisecond))
+	} else if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			d = time.Duration(secs * float64(time.Second))
+		} else if at, err := http.ParseTime(v); err == nil {
+			d = at.Sub(now)
+		}
+	}
+	if d == 0 && status == http.StatusTooManyRequests {
+		for name, values := range h {
+			name = strings.ToLower(name)
+			if len(values) == 0 {
+				continue
+			}
+			var reset time.Duration
+			switch {
+			case strings.HasPrefix(name, "x-ratelimit-reset"):
+				reset, _ = time.ParseDuration(values[0])
+			case strings.HasPrefix(name, "anthropic-ratelimit-") && strings.HasSuffix(name, "-reset"):
+				if at, err := time.Parse(time.RFC3339, values[0]); err == nil {
+					reset = at.Sub(now)
+				}
+			}
+			d = max(d, reset)
+		}
+	}
+	if d < 0 {
+		return 0
+	}
+	return min(d, maxRetryAfter)
+}
@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		want    time.Duration
+	}{
+		{name: "no headers", status: http.StatusServiceUnavailable, want: 0},
+		{name: "seconds", headers: map[string]string{"Retry-After": "3"}, status: http.StatusServiceUnavailable, want: 3 * time.Second},
+		{name: "fractional seconds", headers: map[string]string{"Retry-After": "1.5"}, status: http.StatusTooManyRequests, want: 1500 * time.Millisecond},
+		{name: "HTTP date", headers: map[string]string{"Retry-After": "Wed, 01 May 2024 12:00:20 GMT"}, status: http.StatusTooManyRequests, want: 20 * time.Second},
+		{name: "HTTP date in the past", headers: map[string]string{"Retry-After": "Wed, 01 May 2024 11:59:00 GMT"}, status: http.StatusTooManyRequests, want: 0},
+		{name: "garbage", headers: map[string]string{"Retry-After": "soon"}, status: http.StatusServiceUnavailable, want: 0},
+		{name: "milliseconds win", headers: map[string]string{"Retry-After-Ms": "250", "Retry-After": "9"}, status: http.StatusTooManyRequests, want: 250 * time.Millisecond},
+		{name: "capped", headers: map[string]string{"Retry-After": "3600"}, status: http.StatusTooManyRequests, want: maxRetryAfter},
+		{
+			name:    "OpenAI resets, longest wins",
+			headers: map[string]string{"X-Ratelimit-Reset-Requests": "1s", "X-Ratelimit-Reset-Tokens": "6m0s"},
+			status:  http.StatusTooManyRequests,
+			want:    maxRetryAfter,
+		},
+		{
+			name:    "OpenAI reset",
+			headers: map[string]string{"X-Ratelimit-Reset-Tokens": "750ms"},
+			status:  http.StatusTooManyRequests,
+			want:    750 * time.Millisecond,
+		},
+		{
+			name:    "Anthropic reset",
+			headers: map[string]string{"Anthropic-Ratelimit-Tokens-Reset": "2024-05-01T12:00:07Z", "Anthropic-Ratelimit-Requests-Reset": "2024-05-01T12:00:02Z"},
+			status:  http.StatusTooManyRequests,
+			want:    7 * time.Second,
+		},
+		{
+			name:    "resets ignored unless rate limited",
+			headers: map[string]string{"X-Ratelimit-Reset-Tokens": "5s"},
+			status:  http.StatusInternalServerError,
+			want:    0,
+		},
+		{
+			name:    "Retry-After beats resets",
+			headers: map[string]string{"Retry-After": "2", "X-Ratelimit-Reset-Tokens": "20s"},
+			status:  http.StatusTooManyRequests,
+			want:    2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			if got := retryAfter(h, tt.status, now); got != tt.want {
+				t.Errorf("retryAfter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package providers
+
+// scripted.go - Offline providers that answer without a server: echo repeats
+// the last user message, lorem produces placeholder text.
+
+import (
+	"aichat/services/ai/types"
+	"context"
+	"strings"
+	"time"
+)
+
+// ProviderInfo.Type values selecting a ScriptedProvider.
+const (
+	EchoType  = "echo"
+	LoremType = "lorem"
+)
+
+const loremText = "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod " +
+	"tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, " +
+	"quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat. " +
+	"Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu " +
+	"fugiat nulla pariatur. Excepteur sint occaecat cupidatat non proident, sunt in " +
+	"culpa qui officia deserunt mollit anim id est laborum."
+
+// ScriptedProvider answers deterministically, streaming one word every
+// Delay, so chats can be exercised without network access.
+type ScriptedProvider struct {
+	info  types.ProviderInfo
+	Delay time.Duration
+}
+
+// NewScriptedProvider builds an echo or lorem provider from its
+// configuration (info.Type selects which).
+func NewScriptedProvider(info types.ProviderInfo) *ScriptedProvider {
+	if info.Type != LoremType {
+		info.Type = EchoType
+	}
+	info.Stream = true
+	return &ScriptedProvider{info: info, Delay: 30 * time.Millisecond}
+}
+
+func (p *ScriptedProvider) Info() types.ProviderInfo {
+	return p.info
+}
+
+// SupportsTools is false: scripted answers never call tools.
+func (p *ScriptedProvider) SupportsTools(model string) bool {
+	return false
+}
+
+// reply returns the scripted answer to req.
+func (p *ScriptedProvider) reply(req types.ChatRequest) string {
+	var last string
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			last = req.Messages[i].Content
+			break
+		}
+	}
+	if p.info.Type == LoremType {
+		// Answer length follows the question so output varies predictably.
+		words := strings.Fields(loremText)
+		n := min(len(words), max(8, len(strings.Fields(last))*4))
+		return strings.Join(words[:n], " ")
+	}
+	return "Echo: " + last
+}
+
+// usage counts words as tokens, enough to exercise usage reporting.
+func (p *ScriptedProvider) usage(req types.ChatRequest, reply string) *types.Usage {
+	prompt := 0
+	for _, m := range req.Messages {
+		prompt += len(strings.Fields(m.Content))
+	}
+	return &types.Usage{PromptTokens: prompt, CompletionTokens: len(strings.Fields(reply))}
+}
+
+func (p *ScriptedProvider) SendMessage(ctx context.Context, req types.ChatRequest, apiKey string) (*types.ChatResponse, error) {
+	reply := p.reply(req)
+	return &types.ChatResponse{Model: req.Model, Content: reply, FinishReason: "stop", Usage: p.usage(req, reply)}, nil
+}
+
+func (p *ScriptedProvider) StreamMessage(ctx context.Context, req types.ChatRequest, apiKey string, onChunk func(chunk types.StreamChunk)) error {
+	reply := p.reply(req)
+	for i, word := range strings.Fields(reply) {
+		if i > 0 {
+			word = " " + word
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.Delay):
+		}
+		onChunk(types.StreamChunk{Content: word})
+	}
+	onChunk(types.StreamChunk{FinishReason: "stop", Usage: p.usage(req, reply)})
+	return nil
+}
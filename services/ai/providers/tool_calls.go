@@ -0,0 +1,71 @@
+package providers
+
+// tool_calls.go - Helpers shared by providers that support tool calling.
+
+import (
+	"aichat/services/ai/types"
+	"encoding/json"
+)
+
+// emptyObject is sent where an API requires a JSON object but none was given.
+var emptyObject = json.RawMessage(`{}`)
+
+// toolSchema returns the tool's parameter schema, defaulting to an object
+// with no properties since most APIs reject a missing schema.
+func toolSchema(tool types.Tool) json.RawMessage {
+	if len(tool.Parameters) == 0 {
+		return json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return tool.Parameters
+}
+
+// toolArguments returns a call's arguments as a JSON object.
+func toolArguments(args string) json.RawMessage {
+	if args == "" {
+		return emptyObject
+	}
+	return json.RawMessage(args)
+}
+
+// toolCallBuilder assembles tool calls whose id, name and arguments arrive
+// in fragments across stream events, keyed by the index the provider uses.
+type toolCallBuilder struct {
+	order []int
+	calls map[int]*types.ToolCall
+}
+
+func (b *toolCallBuilder) add(index int, id, name, args string) {
+	if b.calls == nil {
+		b.calls = make(map[int]*types.ToolCall)
+	}
+	call, ok := b.calls[index]
+	if !ok {
+		call = &types.ToolCall{}
+		b.calls[index] = call
+		b.order = append(b.order, index)
+	}
+	if id != "" {
+		call.ID = id
+	}
+	if name != "" {
+		call.Name = name
+	}
+	call.Arguments += args
+}
+
+// flush returns the assembled calls in arrival order and resets the builder.
+func (b *toolCallBuilder) flush() []types.ToolCall {
+	if len(b.order) == 0 {
+		return nil
+	}
+	out := make([]types.ToolCall, 0, len(b.order))
+	for _, index := range b.order {
+		call := *b.calls[index]
+		if call.Arguments == "" {
+			call.Arguments = string(emptyObject)
+		}
+		out = append(out, call)
+	}
+	b.order, b.calls = nil, nil
+	return out
+}
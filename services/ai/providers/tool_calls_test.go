@@ -0,0 +1,69 @@
+package providers
+
+import (
+	"aichat/services/ai/types"
+	"reflect"
+	"testing"
+)
+
+func TestToolCallBuilder(t *testing.T) {
+	type fragment struct {
+		index          int
+		id, name, args string
+	}
+	tests := []struct {
+		name      string
+		fragments []fragment
+		want      []types.ToolCall
+	}{
+		{name: "nothing added", want: nil},
+		{
+			name: "arguments in fragments",
+			fragments: []fragment{
+				{index: 0, id: "call_1", name: "get_weather"},
+				{index: 0, args: `{"city":`},
+				{index: 0, args: `"Oslo"}`},
+			},
+			want: []types.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}},
+		},
+		{
+			name: "interleaved calls keep arrival order",
+			fragments: []fragment{
+				{index: 3, id: "b", name: "second", args: `{"x"`},
+				{index: 1, id: "a", name: "first", args: `{}`},
+				{index: 3, args: `:1}`},
+			},
+			want: []types.ToolCall{
+				{ID: "b", Name: "second", Arguments: `{"x":1}`},
+				{ID: "a", Name: "first", Arguments: `{}`},
+			},
+		},
+		{
+			name: "later id and name fill in",
+			fragments: []fragment{
+				{index: 0, args: `{"q":"go"}`},
+				{index: 0, id: "late", name: "search"},
+			},
+			want: []types.ToolCall{{ID: "late", Name: "search", Arguments: `{"q":"go"}`}},
+		},
+		{
+			name:      "no arguments become an empty object",
+			fragments: []fragment{{index: 0, id: "c", name: "now"}},
+			want:      []types.ToolCall{{ID: "c", Name: "now", Arguments: `{}`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b toolCallBuilder
+			for _, f := range tt.fragments {
+				b.add(f.index, f.id, f.name, f.args)
+			}
+			if got := b.flush(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flush = %+v, want %+v", got, tt.want)
+			}
+			if again := b.flush(); again != nil {
+				t.Errorf("second flush = %+v, want nil after reset", again)
+			}
+		})
+	}
+}
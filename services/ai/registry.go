@@ -2,38 +2,275 @@ package ai
 package ai
 
 import (
+	"aichat/errors"
 	"aichat/services/ai/providers"
 	aitypes "aichat/services/ai/types"
 	"encoding/json"
-	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 )
 
-var providerRegistry = map[string]AIProvider{}
+// ProviderFactory builds a provider from its definition.
+type ProviderFactory func(def aitypes.ProviderInfo) AIProvider
 
+// providerTypes maps each definition type to its factory.
+var providerTypes = map[string]ProviderFactory{
+	providers.OpenAIType:           func(def aitypes.ProviderInfo) AIProvider { return providers.NewOpenAIProvider(def) },
+	providers.OpenRouterType:       func(def aitypes.ProviderInfo) AIProvider { return providers.NewOpenRouterProvider(def) },
+	providers.AnthropicType:        func(def aitypes.ProviderInfo) AIProvider { return providers.NewAnthropicProvider(def) },
+	providers.GeminiType:           func(def aitypes.ProviderInfo) AIProvider { return providers.NewGeminiProvider(def) },
+	providers.OpenAICompatibleType: func(def aitypes.ProviderInfo) AIProvider { return providers.NewOpenAICompatibleProvider(def) },
+	providers.ReplayType:           func(def aitypes.ProviderInfo) AIProvider { return providers.NewReplayProvider(def) },
+	providers.PluginType:           func(def aitypes.ProviderInfo) AIProvider { return providers.NewPluginProvider(def) },
+	providers.EchoType:             func(def aitypes.ProviderInfo) AIProvider { return providers.NewScriptedProvider(def) },
+	providers.LoremType:            func(def aitypes.ProviderInfo) AIProvider { return providers.NewScriptedProvider(def) },
+}
+
+// legacyTypes are the types of built-in providers listed by name only, as
+// providers files were written before definitions had types.
+var legacyTypes = map[string]string{
+	"OpenAI":         providers.OpenAIType,
+	"OpenAI (s)":     providers.OpenAIType,
+	"OpenRouter":     providers.OpenRouterType,
+	"OpenRouter (s)": providers.OpenRouterType,
+	"Anthropic":      providers.AnthropicType,
+	"Anthropic (s)":  providers.AnthropicType,
+	"Gemini":         providers.GeminiType,
+	"Gemini (s)":     providers.GeminiType,
+}
+
+var (
+	registryMu       sync.RWMutex
+	providerRegistry = map[string]AIProvider{}
+	// definitions and providersPath are the providers file, kept so
+	// SaveProvider can rewrite it.
+	definitions   []aitypes.ProviderInfo
+	providersPath string
+	// keyStore holds the keys of definitions, which refer to them by KeyRef.
+	keyStore KeyStore
+)
+
+// KeyStore keeps provider keys out of the providers file. Keys are stored
+// under a reference, e.g. the title of an API key entry.
+type KeyStore interface {
+	Key(ref string) (string, bool)
+	SetKey(ref, key string) error
+}
+
+// SetKeyStore sets where provider keys are kept. Call it before loading the
+// providers file so the keys of definitions can be resolved.
+func SetKeyStore(s KeyStore) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	keyStore = s
+}
+
+// providerKeyRef is the reference a provider's key is stored under when its
+// definition names none.
+func providerKeyRef(name string) string {
+	return "Provider: " + name
+}
+
+// storeKey moves an inline key of def into the key store, leaving only a
+// reference in the definition.
+func storeKey(def aitypes.ProviderInfo) (aitypes.ProviderInfo, error) {
+	if def.APIKey == "" {
+		return def, nil
+	}
+	registryMu.RLock()
+	store := keyStore
+	registryMu.RUnlock()
+	if store == nil {
+		return def, errors.NewConfigurationError("providers", "no key store to keep the API key of "+def.Name)
+	}
+	if def.KeyRef == "" {
+		def.KeyRef = providerKeyRef(def.Name)
+	}
+	if err := store.SetKey(def.KeyRef, def.APIKey); err != nil {
+		return def, errors.NewStorageError("save provider key", def.KeyRef, err)
+	}
+	def.APIKey = ""
+	return def, nil
+}
+
+// RegisterProviderType adds (or replaces) the factory for a definition type.
+func RegisterProviderType(typ string, factory ProviderFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	providerTypes[typ] = factory
+}
+
+// ProviderTypes lists the definition types that can be instantiated.
+func ProviderTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(providerTypes))
+	for typ := range providerTypes {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewProvider validates a definition and instantiates it through the factory
+// of its type. A definition without a type naming a built-in provider is
+// taken as that provider's type.
+func NewProvider(def aitypes.ProviderInfo) (AIProvider, error) {
+	if def.Name == "" {
+		return nil, errors.NewValidationError("name", "providers need a name")
+	}
+	if def.APIKey == "" && def.KeyRef != "" {
+		registryMu.RLock()
+		store := keyStore
+		registryMu.RUnlock()
+		if store != nil {
+			def.APIKey, _ = store.Key(def.KeyRef)
+		}
+	}
+	if def.Type == "" {
+		def.Type = legacyTypes[def.Name]
+	}
+	registryMu.RLock()
+	factory := providerTypes[def.Type]
+	registryMu.RUnlock()
+	if factory == nil {
+		return nil, errors.NewValidationError("type", "unknown provider type \""+def.Type+"\" for "+def.Name)
+	}
+	switch def.Auth {
+	case "", aitypes.AuthBearer, aitypes.AuthQuery, aitypes.AuthNone:
+	case aitypes.AuthHeader:
+		if def.AuthHeader == "" {
+			return nil, errors.NewValidationError("auth_header", "auth \"header\" needs the header name")
+		}
+	default:
+		return nil, errors.NewValidationError("auth", "unknown auth scheme \""+string(def.Auth)+"\"; use bearer, header, query or none")
+	}
+	if c := def.Capabilities; c != nil && c.Streaming != nil {
+		// A declared capability decides; the older flag only follows it.
+		def.Stream = *c.Streaming
+	}
+	p := factory(def)
+	if def.Cassette != "" && def.Type != providers.ReplayType {
+		p = providers.NewRecordingProvider(p, def.Cassette)
+	}
+	return p, nil
+}
+
+// LoadProvidersFromJSON registers the providers defined in path. A broken
+// definition does not keep the others from loading; the first one is
+// reported. A missing file is not an error. Keys written inline by older
+// versions are moved to the key store and the file is rewritten without
+// them.
 func LoadProvidersFromJSON(path string) error {
-	data, err := ioutil.ReadFile(path)
+	data, err := os.ReadFile(path)
+	var entries []aitypes.ProviderInfo
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return errors.NewStorageError("load providers", path, err)
+	default:
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return errors.NewConfigurationError(path, err.Error())
+		}
+	}
+	var first error
+	moved := false
+	for i, entry := range entries {
+		p, err := NewProvider(entry)
+		if err != nil {
+			if first == nil {
+				first = errors.NewConfigurationError(path, err.Error())
+			}
+			continue
+		}
+		registerProvider(entry.Name, p)
+		if entry.APIKey != "" {
+			if entries[i], err = storeKey(entry); err != nil {
+				if first == nil {
+					first = err
+				}
+				continue
+			}
+			moved = true
+		}
+	}
+	registryMu.Lock()
+	providersPath, definitions = path, entries
+	registryMu.Unlock()
+	if moved {
+		if err := writeDefinitions(path, entries); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// SaveProvider validates a definition, registers the provider and writes the
+// definition to the providers file, replacing one of the same name. Its key
+// goes to the key store; the file only refers to it.
+func SaveProvider(def aitypes.ProviderInfo) error {
+	p, err := NewProvider(def)
 	if err != nil {
 		return err
 	}
-	var entries []aitypes.ProviderInfo
-	if err := json.Unmarshal(data, &entries); err != nil {
+	if def, err = storeKey(def); err != nil {
 		return err
 	}
-	for _, entry := range entries {
-		var p AIProvider
-		if entry.Name == "OpenAI" || entry.Name == "OpenAI (s)" {
-			p = providers.NewOpenAIProvider(entry.Stream)
-		} else if entry.Name == "OpenRouter" || entry.Name == "OpenRouter (s)" {
-			p = providers.NewOpenRouterProvider(entry.Stream)
-		}
-		if p != nil {
-			providerRegistry[entry.Name] = p
+	registryMu.Lock()
+	path := providersPath
+	if path == "" {
+		registryMu.Unlock()
+		return errors.NewConfigurationError("providers", "no providers file loaded")
+	}
+	replaced := false
+	for i := range definitions {
+		if definitions[i].Name == def.Name {
+			definitions[i], replaced = def, true
 		}
 	}
+	if !replaced {
+		definitions = append(definitions, def)
+	}
+	providerRegistry[def.Name] = p
+	all := append([]aitypes.ProviderInfo(nil), definitions...)
+	registryMu.Unlock()
+	return writeDefinitions(path, all)
+}
+
+// writeDefinitions writes the providers file.
+func writeDefinitions(path string, defs []aitypes.ProviderInfo) error {
+	data, err := json.MarshalIndent(defs, "", "  ")
+	if err != nil {
+		return errors.NewStorageError("save providers", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.NewStorageError("save providers", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return errors.NewStorageError("save providers", path, err)
+	}
 	return nil
 }
 
+// ProviderDefinitions returns the definitions of the providers file, in file
+// order.
+func ProviderDefinitions() []aitypes.ProviderInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]aitypes.ProviderInfo(nil), definitions...)
+}
+
+func registerProvider(name string, p AIProvider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	providerRegistry[name] = p
+}
+
 func GetAllProviders() []AIProvider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	providers := []AIProvider{}
 	for _, p := range providerRegistry {
 		providers = append(providers, p)
@@ -42,6 +279,7 @@ func GetAllProviders() []AIProvider {
 }
 
 func GetProviderByName(name string) AIProvider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	return providerRegistry[name]
 }
-
@@ -0,0 +1,203 @@
+// Package sse decodes text/event-stream bodies as described by the WHATWG
+// Server-Sent Events specification, with the extras AI providers layer on top:
+// "[DONE]" end-of-stream sentinels and JSON error payloads sent mid-stream.
+package sse
+
+import (
+	"aichat/errors"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DoneSentinel is the data payload OpenAI-style APIs send to end a stream.
+const DoneSentinel = "[DONE]"
+
+// maxLineSize bounds a single line of the stream.
+const maxLineSize = 16 * 1024 * 1024
+
+// Event is a single dispatched server-sent event.
+type Event struct {
+	Type  string        // "event" field, "message" when absent
+	Data  string        // "data" lines joined with "\n"
+	ID    string        // last seen "id" field
+	Retry time.Duration // "retry" field, zero when absent
+}
+
+// Decoder reads events from a stream.
+type Decoder struct {
+	scanner *bufio.Scanner
+	source  string
+	lastID  string
+}
+
+// NewDecoder returns a decoder reading from r. source names the provider in
+// any errors the decoder returns.
+func NewDecoder(r io.Reader, source string) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	scanner.Split(scanLines)
+	return &Decoder{scanner: scanner, source: source}
+}
+
+// Next returns the next event. It returns io.EOF when the stream ends or a
+// "[DONE]" sentinel arrives, and a *errors.DomainError when the event carries
+// an error payload.
+func (d *Decoder) Next() (*Event, error) {
+	var (
+		eventType string
+		data      strings.Builder
+		hasData   bool
+		retry     time.Duration
+	)
+	for d.scanner.Scan() {
+		line := d.scanner.Text()
+		if line == "" {
+			if !hasData {
+				eventType, retry = "", 0
+				continue
+			}
+			return d.dispatch(eventType, data.String(), retry)
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment / keep-alive
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+	// The spec discards an event not terminated by a blank line, but servers
+	// closing the connection right after their last data line are common
+	// enough that dropping it would lose the end of the response.
+	if hasData {
+		return d.dispatch(eventType, data.String(), retry)
+	}
+	return nil, io.EOF
+}
+
+// dispatch builds the event of the collected fields, turning the "[DONE]"
+// sentinel into io.EOF and error payloads into errors.
+func (d *Decoder) dispatch(eventType, data string, retry time.Duration) (*Event, error) {
+	ev := &Event{Type: eventType, Data: data, ID: d.lastID, Retry: retry}
+	if ev.Type == "" {
+		ev.Type = "message"
+	}
+	if ev.Data == DoneSentinel {
+		return nil, io.EOF
+	}
+	if err := d.payloadError(ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// errorPayload matches the error shapes used by OpenAI, OpenRouter,
+// Anthropic and Gemini.
+type errorPayload struct {
+	Error json.RawMessage `json:"error"`
+}
+
+type errorBody struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Status  string          `json:"status"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// payloadError converts an error event into a DomainError, or returns nil.
+func (d *Decoder) payloadError(ev *Event) error {
+	if ev.Type != "error" && !strings.Contains(ev.Data, `"error"`) {
+		return nil
+	}
+	var payload errorPayload
+	if err := json.Unmarshal([]byte(ev.Data), &payload); err != nil || len(payload.Error) == 0 || bytes.Equal(payload.Error, []byte("null")) {
+		if ev.Type == "error" {
+			return errors.NewAIStreamError(d.source, "", ev.Data, false)
+		}
+		return nil
+	}
+	var body errorBody
+	if err := json.Unmarshal(payload.Error, &body); err != nil {
+		// Some servers send {"error": "message"}.
+		var msg string
+		if json.Unmarshal(payload.Error, &msg) != nil {
+			msg = string(payload.Error)
+		}
+		return errors.NewAIStreamError(d.source, "", msg, false)
+	}
+	code := strings.Trim(string(body.Code), `"`)
+	if code == "" || code == "null" {
+		code = body.Type
+	}
+	if code == "" {
+		code = body.Status
+	}
+	if body.Message == "" {
+		body.Message = fmt.Sprintf("error %s", code)
+	}
+	return errors.NewAIStreamError(d.source, code, body.Message, retryableCode(code, body.Type, body.Status))
+}
+
+// retryableCode reports whether an error code signals a transient condition.
+func retryableCode(codes ...string) bool {
+	for _, c := range codes {
+		switch strings.ToLower(c) {
+		case "429", "500", "502", "503", "504", "529",
+			"rate_limit_error", "rate_limit_exceeded", "overloaded_error",
+			"api_error", "server_error", "unavailable", "resource_exhausted", "internal":
+			return true
+		}
+	}
+	return false
+}
+
+// scanLines splits on "\r\n", "\n" or a lone "\r", the three line endings
+// the event-stream format allows.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\r' {
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if !atEOF {
+				return 0, nil, nil // need more data to tell "\r" from "\r\n"
+			}
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,193 @@
+package sse
+
+import (
+	"aichat/errors"
+	stderrors "errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeAll reads events until the decoder stops, returning them along with
+// the error that ended the stream (nil for io.EOF).
+func decodeAll(t *testing.T, stream string) ([]Event, error) {
+	t.Helper()
+	d := NewDecoder(strings.NewReader(stream), "test")
+	var events []Event
+	for {
+		ev, err := d.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, *ev)
+		if len(events) > 100 {
+			t.Fatal("decoder does not stop")
+		}
+	}
+}
+
+func TestDecoderEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []Event
+	}{
+		{
+			name:   "single event",
+			stream: "data: hello\n\n",
+			want:   []Event{{Type: "message", Data: "hello"}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: first\ndata: second\ndata:third\n\n",
+			want:   []Event{{Type: "message", Data: "first\nsecond\nthird"}},
+		},
+		{
+			name:   "CRLF line endings",
+			stream: "event: delta\r\ndata: a\r\ndata: b\r\n\r\ndata: c\r\n\r\n",
+			want:   []Event{{Type: "delta", Data: "a\nb"}, {Type: "message", Data: "c"}},
+		},
+		{
+			name:   "lone CR line endings",
+			stream: "data: a\r\rdata: b\r\r",
+			want:   []Event{{Type: "message", Data: "a"}, {Type: "message", Data: "b"}},
+		},
+		{
+			name:   "comments, id and retry",
+			stream: ": keep-alive\nid: 7\nretry: 1500\ndata: x\n\ndata: y\n\n",
+			want:   []Event{{Type: "message", Data: "x", ID: "7", Retry: 1500 * time.Millisecond}, {Type: "message", Data: "y", ID: "7"}},
+		},
+		{
+			name:   "event without data is skipped",
+			stream: "event: ping\n\ndata: z\n\n",
+			want:   []Event{{Type: "message", Data: "z"}},
+		},
+		{
+			name:   "DONE ends the stream",
+			stream: "data: a\n\ndata: [DONE]\n\ndata: after\n\n",
+			want:   []Event{{Type: "message", Data: "a"}},
+		},
+		{
+			name:   "unterminated final event",
+			stream: "data: a\n\ndata: {\"last\":true}",
+			want:   []Event{{Type: "message", Data: "a"}, {Type: "message", Data: `{"last":true}`}},
+		},
+		{
+			name:   "unterminated final event ending in a newline",
+			stream: "event: end\ndata: b\n",
+			want:   []Event{{Type: "end", Data: "b"}},
+		},
+		{
+			name:   "unterminated DONE",
+			stream: "data: a\n\ndata: [DONE]",
+			want:   []Event{{Type: "message", Data: "a"}},
+		},
+		{
+			name:   "empty stream",
+			stream: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAll(t, tt.stream)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderErrorPayloads(t *testing.T) {
+	tests := []struct {
+		name      string
+		stream    string
+		before    int // events decoded before the error
+		code      string
+		message   string
+		retryable bool
+	}{
+		{
+			name:      "OpenAI error object",
+			stream:    "data: {\"x\":1}\n\ndata: {\"error\":{\"message\":\"slow down\",\"type\":\"rate_limit_exceeded\",\"code\":null}}\n\n",
+			before:    1,
+			code:      "rate_limit_exceeded",
+			message:   "slow down",
+			retryable: true,
+		},
+		{
+			name:      "Anthropic error event",
+			stream:    "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+			code:      "overloaded_error",
+			message:   "Overloaded",
+			retryable: true,
+		},
+		{
+			name:    "Gemini status",
+			stream:  "data: {\"error\":{\"code\":400,\"message\":\"bad request\",\"status\":\"INVALID_ARGUMENT\"}}\n\n",
+			code:    "400",
+			message: "bad request",
+		},
+		{
+			name:    "error as a string",
+			stream:  "data: {\"error\":\"boom\"}\n\n",
+			message: "boom",
+		},
+		{
+			name:    "error event with plain text",
+			stream:  "event: error\ndata: upstream closed\n\n",
+			message: "upstream closed",
+		},
+		{
+			name:      "unterminated error payload",
+			stream:    "data: {\"error\":{\"message\":\"cut off\",\"code\":\"500\"}}",
+			code:      "500",
+			message:   "cut off",
+			retryable: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAll(t, tt.stream)
+			if len(got) != tt.before {
+				t.Errorf("decoded %d events before the error, want %d", len(got), tt.before)
+			}
+			var de *errors.DomainError
+			if !stderrors.As(err, &de) {
+				t.Fatalf("error = %v, want a *errors.DomainError", err)
+			}
+			if de.Code != "AI_STREAM_ERROR" {
+				t.Errorf("code = %q, want AI_STREAM_ERROR", de.Code)
+			}
+			if code, _ := de.Details["code"].(string); code != tt.code {
+				t.Errorf("detail code = %q, want %q", code, tt.code)
+			}
+			if !strings.Contains(de.Message, tt.message) {
+				t.Errorf("message = %q, want it to contain %q", de.Message, tt.message)
+			}
+			if de.Retryable != tt.retryable {
+				t.Errorf("retryable = %v, want %v", de.Retryable, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestDecoderIgnoresErrorFieldsInContent(t *testing.T) {
+	// A chunk whose "error" key is null, or that only mentions "error" in
+	// its content, is an ordinary event.
+	stream := "data: {\"error\":null,\"text\":\"hi\"}\n\ndata: {\"text\":\"\\\"error\\\"\"}\n\n"
+	got, err := decodeAll(t, stream)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("decoded %d events, want 2", len(got))
+	}
+}
@@ -0,0 +1,82 @@
+package structured
+
+// generate.go - Request, validate and reprompt until a reply is valid JSON.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai"
+	"aichat/services/ai/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxAttempts bounds how often Generate asks for a reply, the first
+// request included.
+const DefaultMaxAttempts = 3
+
+// Generate sends req with format attached and returns the reply once it is
+// JSON matching the schema. An invalid reply is answered with the validation
+// error and a request to correct it, up to DefaultMaxAttempts times; the
+// last error is returned as a ValidationError.
+func Generate(ctx context.Context, provider ai.AIProvider, req types.ChatRequest, apiKey string, format types.ResponseFormat) (json.RawMessage, error) {
+	req.ResponseFormat = &format
+	req.Messages = append([]types.Message(nil), req.Messages...)
+	var problem error
+	for attempt := 0; attempt < DefaultMaxAttempts; attempt++ {
+		resp, err := provider.SendMessage(ctx, req, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		data := Extract(resp.Content)
+		if problem = Validate(format.Schema, data); problem == nil {
+			return data, nil
+		}
+		req.Messages = append(req.Messages,
+			types.Message{Role: "assistant", Content: resp.Content},
+			types.Message{Role: "user", Content: "That reply is not valid: " + problem.Error() + ". Reply again with only the corrected JSON."},
+		)
+	}
+	return nil, errors.NewValidationError("response", problem.Error())
+}
+
+// Extract returns the JSON in a reply, dropping code fences and any text
+// around the outermost object or array that models sometimes add.
+func Extract(content string) []byte {
+	s := strings.TrimSpace(content)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s[3:], "json")
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+		s = strings.TrimSpace(s)
+	}
+	if json.Valid([]byte(s)) {
+		return []byte(s)
+	}
+	start := strings.IndexAny(s, "{[")
+	end := strings.LastIndexAny(s, "}]")
+	if start >= 0 && end > start {
+		return []byte(s[start : end+1])
+	}
+	return []byte(s)
+}
+
+// SaveFile writes validated output to path, indented for reading.
+func SaveFile(path string, data json.RawMessage) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return errors.NewValidationError("json", err.Error())
+	}
+	out.WriteByte('\n')
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.NewStorageError("save json", path, err)
+		}
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		return errors.NewStorageError("save json", path, err)
+	}
+	return nil
+}
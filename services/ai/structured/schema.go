@@ -0,0 +1,234 @@
+// Package structured requests JSON replies, validates them against a JSON
+// Schema and reprompts the model with the validation error until they pass.
+package structured
+
+// schema.go - Validation against the commonly used subset of JSON Schema.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// schema is the supported subset of JSON Schema: type, enum, const,
+// properties, required, additionalProperties, items, anyOf, oneOf, allOf,
+// the numeric and length bounds, and pattern. Other keywords are ignored.
+type schema struct {
+	Type                 any                `json:"type"` // string or []string
+	Enum                 []any              `json:"enum"`
+	Const                any                `json:"const"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	AnyOf                []*schema          `json:"anyOf"`
+	OneOf                []*schema          `json:"oneOf"`
+	AllOf                []*schema          `json:"allOf"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Pattern              string             `json:"pattern"`
+}
+
+// Validate checks that data is JSON matching the schema. The error names
+// the offending location as a JSON pointer, e.g. "/menu/textColor: ...", so
+// it can be handed back to the model as-is. An empty schema accepts any JSON.
+func Validate(rawSchema json.RawMessage, data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	if len(rawSchema) == 0 {
+		return nil
+	}
+	var s schema
+	if err := json.Unmarshal(rawSchema, &s); err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+	return s.validate("", value)
+}
+
+func (s *schema) validate(path string, v any) error {
+	if s == nil {
+		return nil
+	}
+	at := func(format string, args ...any) error {
+		where := path
+		if where == "" {
+			where = "/"
+		}
+		return fmt.Errorf("%s: %s", where, fmt.Sprintf(format, args...))
+	}
+	if types := s.types(); len(types) > 0 && !matchesType(types, v) {
+		return at("expected %s, got %s", strings.Join(types, " or "), typeName(v))
+	}
+	if s.Const != nil && !equal(s.Const, v) {
+		return at("must be %v", s.Const)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if equal(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return at("must be one of %v", s.Enum)
+		}
+	}
+	for _, sub := range s.AllOf {
+		if err := sub.validate(path, v); err != nil {
+			return err
+		}
+	}
+	if len(s.AnyOf) > 0 && countMatches(s.AnyOf, path, v) == 0 {
+		return at("matches none of the allowed alternatives")
+	}
+	if len(s.OneOf) > 0 && countMatches(s.OneOf, path, v) != 1 {
+		return at("must match exactly one of the allowed alternatives")
+	}
+	switch val := v.(type) {
+	case string:
+		n := len([]rune(val))
+		if s.MinLength != nil && n < *s.MinLength {
+			return at("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return at("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			re, err := regexp.Compile(s.Pattern)
+			if err == nil && !re.MatchString(val) {
+				return at("%q does not match pattern %s", val, s.Pattern)
+			}
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			return at("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			return at("must be at most %v", *s.Maximum)
+		}
+	case []any:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			return at("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			return at("must have at most %d items", *s.MaxItems)
+		}
+		for i, item := range val {
+			if err := s.Items.validate(fmt.Sprintf("%s/%d", path, i), item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				return at("missing required property %q", name)
+			}
+		}
+		var additional *schema
+		closed := string(s.AdditionalProperties) == "false"
+		if len(s.AdditionalProperties) > 0 && !closed && string(s.AdditionalProperties) != "true" {
+			additional = &schema{}
+			if err := json.Unmarshal(s.AdditionalProperties, additional); err != nil {
+				additional = nil
+			}
+		}
+		for name, prop := range val {
+			sub, known := s.Properties[name]
+			switch {
+			case known:
+			case closed:
+				return at("unexpected property %q", name)
+			default:
+				sub = additional
+			}
+			if err := sub.validate(path+"/"+escapePointer(name), prop); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *schema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var out []string
+		for _, name := range t {
+			if str, ok := name.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func countMatches(schemas []*schema, path string, v any) int {
+	n := 0
+	for _, sub := range schemas {
+		if sub.validate(path, v) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func matchesType(types []string, v any) bool {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if f, ok := v.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		default:
+			if typeName(v) == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeName returns the JSON Schema type of a decoded JSON value.
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// equal compares decoded JSON values.
+func equal(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,90 @@
+package structured
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const theme = `{
+		"type": "object",
+		"required": ["name", "menu"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "maxLength": 8},
+			"dark": {"type": "boolean"},
+			"menu": {
+				"type": "object",
+				"properties": {"textColor": {"type": "string", "pattern": "^#[0-9a-f]{6}$"}},
+				"additionalProperties": {"type": "integer"}
+			},
+			"tags": {"type": "array", "items": {"enum": ["a", "b"]}, "minItems": 1, "maxItems": 2}
+		}
+	}`
+	tests := []struct {
+		name    string
+		schema  string
+		data    string
+		wantErr string // substring of the error, empty when valid
+	}{
+		{name: "valid", schema: theme, data: `{"name":"x","menu":{"textColor":"#00ff00","width":3},"tags":["a"]}`},
+		{name: "invalid JSON", schema: theme, data: `{"name":`, wantErr: "invalid JSON"},
+		{name: "empty schema accepts anything", schema: ``, data: `[1,"two",null]`},
+		{name: "invalid schema", schema: `{"type":`, data: `{}`, wantErr: "invalid schema"},
+		{name: "wrong root type", schema: theme, data: `[]`, wantErr: "/: expected object, got array"},
+		{name: "missing required", schema: theme, data: `{"name":"x"}`, wantErr: `/: missing required property "menu"`},
+		{name: "closed object", schema: theme, data: `{"name":"x","menu":{},"extra":1}`, wantErr: `/: unexpected property "extra"`},
+		{name: "min length", schema: theme, data: `{"name":"","menu":{}}`, wantErr: "/name: must be at least 1 characters"},
+		{name: "max length counts runes", schema: theme, data: `{"name":"ééééééééé","menu":{}}`, wantErr: "/name: must be at most 8 characters"},
+		{name: "pattern", schema: theme, data: `{"name":"x","menu":{"textColor":"green"}}`, wantErr: `/menu/textColor: "green" does not match pattern`},
+		{name: "additional properties schema", schema: theme, data: `{"name":"x","menu":{"width":1.5}}`, wantErr: "/menu/width: expected integer, got number"},
+		{name: "enum in items", schema: theme, data: `{"name":"x","menu":{},"tags":["c"]}`, wantErr: "/tags/0: must be one of [a b]"},
+		{name: "min items", schema: theme, data: `{"name":"x","menu":{},"tags":[]}`, wantErr: "/tags: must have at least 1 items"},
+		{name: "max items", schema: theme, data: `{"name":"x","menu":{},"tags":["a","b","a"]}`, wantErr: "/tags: must have at most 2 items"},
+		{name: "type list", schema: `{"type":["string","null"]}`, data: `null`},
+		{name: "type list mismatch", schema: `{"type":["string","null"]}`, data: `1`, wantErr: "/: expected string or null, got number"},
+		{name: "integer", schema: `{"type":"integer"}`, data: `4.0`},
+		{name: "bounds", schema: `{"type":"number","minimum":0,"maximum":1}`, data: `1.5`, wantErr: "/: must be at most 1"},
+		{name: "const", schema: `{"const":{"a":1}}`, data: `{"a":2}`, wantErr: "/: must be map[a:1]"},
+		{name: "anyOf", schema: `{"anyOf":[{"type":"string"},{"type":"number"}]}`, data: `true`, wantErr: "matches none"},
+		{name: "oneOf overlap", schema: `{"oneOf":[{"type":"integer"},{"type":"number"}]}`, data: `2`, wantErr: "exactly one"},
+		{name: "oneOf single", schema: `{"oneOf":[{"type":"integer"},{"type":"string"}]}`, data: `2`},
+		{name: "allOf", schema: `{"allOf":[{"type":"string"},{"maxLength":2}]}`, data: `"abc"`, wantErr: "/: must be at most 2 characters"},
+		{name: "escaped pointer", schema: `{"properties":{"a/b":{"type":"string"}}}`, data: `{"a/b":1}`, wantErr: "/a~1b: expected string"},
+		{name: "unknown keywords ignored", schema: `{"format":"email","type":"string"}`, data: `"nope"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]byte(tt.schema), []byte(tt.data))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("no error, want one containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "bare JSON", content: ` {"a":1} `, want: `{"a":1}`},
+		{name: "json fence", content: "```json\n{\"a\":1}\n```", want: `{"a":1}`},
+		{name: "plain fence", content: "```\n[1,2]\n```", want: `[1,2]`},
+		{name: "surrounding text", content: "Here it is: {\"a\":{\"b\":2}} Hope that helps.", want: `{"a":{"b":2}}`},
+		{name: "no JSON", content: "sorry", want: "sorry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Extract(tt.content)); got != tt.want {
+				t.Errorf("Extract(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
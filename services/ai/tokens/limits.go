@@ -0,0 +1,88 @@
+package tokens
+
+// limits.go - Per-model context window sizes.
+
+import (
+	"aichat/services/ai/types"
+	"strings"
+	"sync"
+)
+
+// DefaultContextWindow is assumed for models we know nothing about. It is
+// deliberately small so unknown models are trimmed rather than rejected.
+const DefaultContextWindow = 8192
+
+// knownWindows holds published context windows by model ID prefix; the
+// longest matching prefix wins. Provider metadata takes precedence.
+var knownWindows = map[string]int{
+	"gpt-3.5-turbo":  16385,
+	"gpt-4":          8192,
+	"gpt-4-32k":      32768,
+	"gpt-4-turbo":    128000,
+	"gpt-4o":         128000,
+	"gpt-4.1":        1047576,
+	"o1":             200000,
+	"o3":             200000,
+	"o4-mini":        200000,
+	"claude":         200000,
+	"claude-2":       100000,
+	"claude-instant": 100000,
+	"gemini":         1048576,
+	"gemini-1.5-pro": 2097152,
+	"gemma":          8192,
+	"gemma-3":        131072,
+	"llama3":         8192,
+	"llama-3":        8192,
+	"llama3.1":       131072,
+	"llama-3.1":      131072,
+	"mistral-small":  32768,
+	"mistral-large":  131072,
+	"mixtral-8x7b":   32768,
+	"mixtral-8x22b":  65536,
+	"qwen2.5":        32768,
+	"deepseek":       65536,
+	"command-r":      128000,
+	"phi-3":          4096,
+}
+
+var (
+	learnedMu sync.RWMutex
+	learned   = map[string]int{}
+)
+
+// RegisterModels records the context windows reported by a provider's models
+// endpoint so later lookups use them instead of the built-in table.
+func RegisterModels(models []types.ModelInfo) {
+	learnedMu.Lock()
+	defer learnedMu.Unlock()
+	for _, m := range models {
+		if m.ContextWindow > 0 {
+			learned[m.ID] = m.ContextWindow
+		}
+	}
+}
+
+// ContextWindow returns the context window of model in tokens. Vendor
+// prefixes such as OpenRouter's "openai/" are ignored when matching.
+func ContextWindow(model string) int {
+	learnedMu.RLock()
+	window, ok := learned[model]
+	learnedMu.RUnlock()
+	if ok {
+		return window
+	}
+	id := strings.ToLower(model)
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	best := ""
+	for prefix, size := range knownWindows {
+		if strings.HasPrefix(id, prefix) && len(prefix) > len(best) {
+			best, window = prefix, size
+		}
+	}
+	if best == "" {
+		return DefaultContextWindow
+	}
+	return window
+}
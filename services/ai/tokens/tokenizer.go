@@ -0,0 +1,77 @@
+// Package tokens estimates token usage and keeps chat requests within a
+// model's context window.
+package tokens
+
+import (
+	"aichat/services/ai/types"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a piece of text costs.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// Estimator approximates token counts without a model-specific vocabulary.
+// English text averages about four characters per token across the
+// tokenizers in use today, which is close enough for budgeting.
+type Estimator struct {
+	CharsPerToken float64
+}
+
+// DefaultTokenizer is used when a Manager is given no Tokenizer.
+var DefaultTokenizer Tokenizer = Estimator{CharsPerToken: 4}
+
+func (e Estimator) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	perToken := e.CharsPerToken
+	if perToken <= 0 {
+		perToken = 4
+	}
+	return int(float64(utf8.RuneCountInString(text))/perToken) + 1
+}
+
+const (
+	// messageOverhead covers the role and separator tokens each message adds.
+	messageOverhead = 4
+	// imageTokens is a flat estimate for an image attachment; providers bill
+	// images by resolution, typically a few hundred to ~1,600 tokens.
+	imageTokens = 1000
+	// bytesPerToken estimates other attachments from their size.
+	bytesPerToken = 4
+)
+
+// CountMessage estimates the tokens a single message costs in a request.
+// Attachments other than images are counted by the size recorded when they
+// were attached, or of their data once loaded; files are never read here.
+func CountMessage(t Tokenizer, m types.Message) int {
+	n := messageOverhead + t.Count(m.Content)
+	for _, call := range m.ToolCalls {
+		n += t.Count(call.Name) + t.Count(call.Arguments)
+	}
+	for _, a := range m.Attachments {
+		if a.Kind == types.AttachmentImage {
+			n += imageTokens
+			continue
+		}
+		size := a.Size
+		if size == 0 {
+			size = int64(len(a.Data))
+		}
+		if size > 0 {
+			n += int(size/bytesPerToken) + 1
+		}
+	}
+	return n
+}
+
+// CountMessages estimates the tokens a message history costs.
+func CountMessages(t Tokenizer, messages []types.Message) int {
+	n := 0
+	for _, m := range messages {
+		n += CountMessage(t, m)
+	}
+	return n
+}
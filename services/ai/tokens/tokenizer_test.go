@@ -0,0 +1,28 @@
+package tokens
+
+import (
+	"aichat/services/ai/types"
+	"testing"
+)
+
+func TestCountMessageAttachments(t *testing.T) {
+	// The paths do not exist: attachments are counted without reading them.
+	tests := []struct {
+		name       string
+		attachment types.Attachment
+		want       int
+	}{
+		{name: "image", attachment: types.Attachment{Kind: types.AttachmentImage, Path: "/missing.png", Size: 1 << 20}, want: imageTokens},
+		{name: "document by recorded size", attachment: types.Attachment{Kind: types.AttachmentDocument, Path: "/missing.txt", Size: 400}, want: 101},
+		{name: "document by loaded data", attachment: types.Attachment{Kind: types.AttachmentDocument, Data: make([]byte, 40)}, want: 11},
+		{name: "document of unknown size", attachment: types.Attachment{Kind: types.AttachmentDocument, Path: "/missing.txt"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := types.Message{Role: "user", Attachments: []types.Attachment{tt.attachment}}
+			if got := CountMessage(charTokenizer{}, m) - messageOverhead; got != tt.want {
+				t.Errorf("attachment tokens = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
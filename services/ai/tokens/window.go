@@ -0,0 +1,194 @@
+package tokens
+
+// window.go - Trimming chat history to fit a model's context window.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai"
+	"aichat/services/ai/types"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Strategy selects how history is reduced when a request is too large.
+type Strategy string
+
+const (
+	// DropOldest removes the oldest turns until the request fits.
+	DropOldest Strategy = "drop-oldest"
+	// KeepLastN keeps system messages plus the last KeepTurns turns.
+	KeepLastN Strategy = "last-n"
+	// Summarize replaces older turns with a model-written summary.
+	Summarize Strategy = "summarize"
+)
+
+// ParseStrategy maps a settings value to a Strategy, defaulting to DropOldest.
+func ParseStrategy(s string) Strategy {
+	switch Strategy(strings.ToLower(strings.TrimSpace(s))) {
+	case KeepLastN:
+		return KeepLastN
+	case Summarize:
+		return Summarize
+	default:
+		return DropOldest
+	}
+}
+
+const (
+	// DefaultOutputReserve is kept free for the reply when a request does
+	// not set MaxTokens.
+	DefaultOutputReserve = 1024
+	// DefaultKeepTurns is used by KeepLastN and Summarize when KeepTurns is unset.
+	DefaultKeepTurns = 6
+)
+
+// Summarizer condenses messages into a short text that replaces them.
+type Summarizer func(ctx context.Context, messages []types.Message) (string, error)
+
+// Manager applies a context strategy to requests before they are sent.
+type Manager struct {
+	Tokenizer Tokenizer
+	Strategy  Strategy
+	// KeepTurns is how many recent turns KeepLastN keeps and Summarize
+	// leaves verbatim. A turn is a user message and everything answering it.
+	KeepTurns int
+	// Summarize is required by the Summarize strategy; without it the
+	// manager falls back to dropping the oldest turns.
+	Summarize Summarizer
+}
+
+func (m *Manager) tokenizer() Tokenizer {
+	if m.Tokenizer == nil {
+		return DefaultTokenizer
+	}
+	return m.Tokenizer
+}
+
+func (m *Manager) keepTurns() int {
+	if m.KeepTurns <= 0 {
+		return DefaultKeepTurns
+	}
+	return m.KeepTurns
+}
+
+// Usage returns the estimated tokens of messages and the context window of
+// model, for display.
+func (m *Manager) Usage(model string, messages []types.Message) (used, limit int) {
+	return CountMessages(m.tokenizer(), messages), ContextWindow(model)
+}
+
+// Fit returns req with its history reduced by the configured strategy so the
+// messages plus room for the reply fit the model's context window. System
+// messages and the latest turn are always kept; if those alone are too large
+// a context window error is returned. A MaxTokens leaving no room for any
+// message is a configuration error.
+func (m *Manager) Fit(ctx context.Context, req types.ChatRequest) (types.ChatRequest, error) {
+	t := m.tokenizer()
+	reserve := req.MaxTokens
+	if reserve <= 0 {
+		reserve = DefaultOutputReserve
+	}
+	limit := ContextWindow(req.Model)
+	budget := limit - reserve
+	if budget <= 0 {
+		return req, errors.NewConfigurationError("MaxTokens", fmt.Sprintf("%d leaves no room for messages in the %d token context window of %s; lower it", reserve, limit, req.Model))
+	}
+	if CountMessages(t, req.Messages) <= budget {
+		return req, nil
+	}
+
+	var system []types.Message
+	var rest []types.Message
+	for _, msg := range req.Messages {
+		if msg.Role == "system" {
+			system = append(system, msg)
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+	turns := splitTurns(rest)
+	budget -= CountMessages(t, system)
+
+	switch m.Strategy {
+	case KeepLastN:
+		if n := m.keepTurns(); len(turns) > n {
+			turns = turns[len(turns)-n:]
+		}
+	case Summarize:
+		if m.Summarize != nil && len(turns) > 1 {
+			keep := min(m.keepTurns(), len(turns)-1)
+			for keep > 1 && countTurns(t, turns[len(turns)-keep:]) > budget/2 {
+				keep--
+			}
+			older := flatten(turns[:len(turns)-keep])
+			summary, err := m.Summarize(ctx, older)
+			if err != nil {
+				return req, err
+			}
+			system = append(system, types.Message{Role: "system", Content: "Summary of the earlier conversation:\n" + summary})
+			budget -= CountMessage(t, system[len(system)-1])
+			turns = turns[len(turns)-keep:]
+		}
+	}
+	for len(turns) > 1 && countTurns(t, turns) > budget {
+		turns = turns[1:]
+	}
+	if used := countTurns(t, turns); used > budget {
+		return req, errors.NewContextWindowError(req.Model, used+limit-budget, limit)
+	}
+	req.Messages = append(system, flatten(turns)...)
+	return req, nil
+}
+
+// splitTurns groups messages into turns, each starting at a user message.
+// Keeping turns whole means a tool result is never sent without the
+// assistant message that requested it.
+func splitTurns(messages []types.Message) [][]types.Message {
+	var turns [][]types.Message
+	for _, msg := range messages {
+		if msg.Role == "user" || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+	}
+	return turns
+}
+
+func countTurns(t Tokenizer, turns [][]types.Message) int {
+	n := 0
+	for _, turn := range turns {
+		n += CountMessages(t, turn)
+	}
+	return n
+}
+
+func flatten(turns [][]types.Message) []types.Message {
+	var out []types.Message
+	for _, turn := range turns {
+		out = append(out, turn...)
+	}
+	return out
+}
+
+// summaryPrompt asks for a summary that can stand in for the transcript.
+const summaryPrompt = "Summarize the following conversation so it can replace the original messages as context. Keep facts, decisions, names, code identifiers and open questions. Be concise.\n\n"
+
+// ProviderSummarizer returns a Summarizer that asks provider to summarize.
+func ProviderSummarizer(provider ai.AIProvider, model, apiKey string) Summarizer {
+	return func(ctx context.Context, messages []types.Message) (string, error) {
+		var transcript strings.Builder
+		transcript.WriteString(summaryPrompt)
+		for _, msg := range messages {
+			transcript.WriteString(msg.Role + ": " + msg.Content + "\n")
+		}
+		resp, err := provider.SendMessage(ctx, types.ChatRequest{
+			Model:    model,
+			Messages: []types.Message{{Role: "user", Content: transcript.String()}},
+		}, apiKey)
+		if err != nil {
+			return "", err
+		}
+		return resp.Content, nil
+	}
+}
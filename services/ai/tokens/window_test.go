@@ -0,0 +1,189 @@
+package tokens
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"context"
+	stderrors "errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// charTokenizer counts one token per byte, so message costs are easy to
+// work out: messageOverhead plus the content length.
+type charTokenizer struct{}
+
+func (charTokenizer) Count(text string) int { return len(text) }
+
+// fitModel has a 200 token window; with MaxTokens 50 requests get 150.
+const fitModel = "fit-test-model"
+
+func init() {
+	RegisterModels([]types.ModelInfo{{ID: fitModel, ContextWindow: 200}})
+}
+
+func msg(role, content string) types.Message {
+	return types.Message{Role: role, Content: content}
+}
+
+// history is a 14 token system message and four 48 token turns: 206 tokens.
+func history() []types.Message {
+	messages := []types.Message{msg("system", "be helpful")}
+	for _, n := range []string{"1", "2", "3", "4"} {
+		messages = append(messages,
+			msg("user", "question number "+n+"..."),
+			msg("assistant", "answer number "+n+"....."))
+	}
+	return messages
+}
+
+func contents(messages []types.Message) []string {
+	var out []string
+	for _, m := range messages {
+		out = append(out, m.Content)
+	}
+	return out
+}
+
+func TestFit(t *testing.T) {
+	var summarized []types.Message
+	summarize := func(ctx context.Context, messages []types.Message) (string, error) {
+		summarized = messages
+		return "S", nil
+	}
+	toolTurn := []types.Message{
+		msg("user", "look it up please...."),
+		{Role: "assistant", ToolCalls: []types.ToolCall{{ID: "c1", Name: "find", Arguments: "{}"}}},
+		{Role: "tool", ToolCallID: "c1", Content: "found"},
+	}
+	tests := []struct {
+		name           string
+		manager        Manager
+		messages       []types.Message
+		want           []string
+		wantSummarized int
+	}{
+		{
+			name:     "fits unchanged",
+			manager:  Manager{Strategy: DropOldest},
+			messages: history()[:5],
+			want:     contents(history()[:5]),
+		},
+		{
+			name:     "drop oldest keeps system and recent turns",
+			manager:  Manager{Strategy: DropOldest},
+			messages: history(),
+			want:     []string{"be helpful", "question number 3...", "answer number 3.....", "question number 4...", "answer number 4....."},
+		},
+		{
+			name:     "last n",
+			manager:  Manager{Strategy: KeepLastN, KeepTurns: 1},
+			messages: history(),
+			want:     []string{"be helpful", "question number 4...", "answer number 4....."},
+		},
+		{
+			name:           "summarize replaces older turns",
+			manager:        Manager{Strategy: Summarize, KeepTurns: 2, Summarize: summarize},
+			messages:       history(),
+			want:           []string{"be helpful", "Summary of the earlier conversation:\nS", "question number 4...", "answer number 4....."},
+			wantSummarized: 6,
+		},
+		{
+			name:     "summarize without a summarizer drops oldest",
+			manager:  Manager{Strategy: Summarize},
+			messages: history(),
+			want:     []string{"be helpful", "question number 3...", "answer number 3.....", "question number 4...", "answer number 4....."},
+		},
+		{
+			name:     "tool results stay with their turn",
+			manager:  Manager{Strategy: DropOldest},
+			messages: append([]types.Message{msg("user", strings.Repeat("x", 120))}, toolTurn...),
+			want:     []string{"look it up please....", "", "found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summarized = nil
+			tt.manager.Tokenizer = charTokenizer{}
+			req := types.ChatRequest{Model: fitModel, MaxTokens: 50, Messages: tt.messages}
+			got, err := tt.manager.Fit(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c := contents(got.Messages); !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("messages = %q, want %q", c, tt.want)
+			}
+			if len(summarized) != tt.wantSummarized {
+				t.Errorf("summarized %d messages, want %d", len(summarized), tt.wantSummarized)
+			}
+		})
+	}
+}
+
+func TestFitErrors(t *testing.T) {
+	failure := stderrors.New("summary failed")
+	tests := []struct {
+		name      string
+		manager   Manager
+		maxTokens int
+		messages  []types.Message
+		check     func(error) bool
+	}{
+		{
+			name:     "latest turn too large",
+			manager:  Manager{Strategy: DropOldest},
+			messages: []types.Message{msg("system", "s"), msg("user", strings.Repeat("x", 200))},
+			check: func(err error) bool {
+				var de *errors.DomainError
+				return stderrors.As(err, &de) && de.Code == "CONTEXT_WINDOW_EXCEEDED"
+			},
+		},
+		{
+			name: "summarizer error",
+			manager: Manager{Strategy: Summarize, Summarize: func(context.Context, []types.Message) (string, error) {
+				return "", failure
+			}},
+			messages: history(),
+			check:    func(err error) bool { return err == failure },
+		},
+		{
+			name:      "MaxTokens fills the window",
+			manager:   Manager{Strategy: DropOldest},
+			maxTokens: 200,
+			messages:  []types.Message{msg("user", "hi")},
+			check: func(err error) bool {
+				var de *errors.DomainError
+				return stderrors.As(err, &de) && de.Type == errors.ConfigurationError && strings.Contains(de.Message, "MaxTokens")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.manager.Tokenizer = charTokenizer{}
+			if tt.maxTokens == 0 {
+				tt.maxTokens = 50
+			}
+			req := types.ChatRequest{Model: fitModel, MaxTokens: tt.maxTokens, Messages: tt.messages}
+			if _, err := tt.manager.Fit(context.Background(), req); !tt.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFitDefaultReserve(t *testing.T) {
+	// Without MaxTokens, DefaultOutputReserve is kept free for the reply.
+	m := Manager{Tokenizer: charTokenizer{}}
+	req := types.ChatRequest{Model: "unknown-model", Messages: []types.Message{
+		msg("user", strings.Repeat("a", DefaultContextWindow-DefaultOutputReserve)),
+		msg("user", "b"),
+	}}
+	got, err := m.Fit(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c := contents(got.Messages); !reflect.DeepEqual(c, []string{"b"}) {
+		t.Errorf("messages = %q, want only the latest turn", c)
+	}
+}
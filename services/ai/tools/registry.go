@@ -0,0 +1,103 @@
+// Package tools registers functions the model may call and runs the
+// request/tool-call/result loop until the model produces a final answer.
+package tools
+
+// registry.go - Tool registration and dispatch.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai/types"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Handler executes a tool call. args is the JSON arguments object produced by
+// the model; the returned string is sent back to the model as the result.
+type Handler func(ctx context.Context, args json.RawMessage) (string, error)
+
+type registeredTool struct {
+	tool    types.Tool
+	handler Handler
+}
+
+// Registry holds the tools offered to the model, in registration order.
+type Registry struct {
+	mu    sync.RWMutex
+	order []string
+	tools map[string]registeredTool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]registeredTool)}
+}
+
+// Register adds a tool. Names must be unique and the schema, if given,
+// must be valid JSON.
+func (r *Registry) Register(tool types.Tool, handler Handler) error {
+	if tool.Name == "" {
+		return errors.NewValidationError("name", "tool name is required")
+	}
+	if handler == nil {
+		return errors.NewValidationError("handler", "tool "+tool.Name+" has no handler")
+	}
+	if len(tool.Parameters) > 0 && !json.Valid(tool.Parameters) {
+		return errors.NewValidationError("parameters", "tool "+tool.Name+" has an invalid JSON schema")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[tool.Name]; exists {
+		return errors.NewValidationError("name", "tool "+tool.Name+" is already registered")
+	}
+	r.tools[tool.Name] = registeredTool{tool: tool, handler: handler}
+	r.order = append(r.order, tool.Name)
+	return nil
+}
+
+// Tools returns the registered tool definitions for a ChatRequest.
+func (r *Registry) Tools() []types.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]types.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, r.tools[name].tool)
+	}
+	return out
+}
+
+// Len reports how many tools are registered.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.order)
+}
+
+// Call runs the handler for call and returns the "tool" message answering it.
+// Unknown tools and handler failures are reported to the model as the result
+// rather than aborting the conversation, so it can recover or explain.
+func (r *Registry) Call(ctx context.Context, call types.ToolCall) types.Message {
+	result := types.Message{Role: "tool", ToolCallID: call.ID, Name: call.Name}
+	r.mu.RLock()
+	registered, ok := r.tools[call.Name]
+	r.mu.RUnlock()
+	if !ok {
+		result.Content = fmt.Sprintf("Error: unknown tool %q", call.Name)
+		return result
+	}
+	args := json.RawMessage(call.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	if !json.Valid(args) {
+		result.Content = "Error: arguments are not valid JSON"
+		return result
+	}
+	out, err := registered.handler(ctx, args)
+	if err != nil {
+		result.Content = "Error: " + err.Error()
+		return result
+	}
+	result.Content = out
+	return result
+}
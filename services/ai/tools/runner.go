@@ -0,0 +1,54 @@
+package tools
+
+// runner.go - Drives a chat through tool calls until a final answer.
+
+import (
+	"aichat/errors"
+	"aichat/services/ai"
+	"aichat/services/ai/types"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxRounds bounds how many tool-call round trips Run allows before
+// giving up, guarding against a model that keeps calling tools forever.
+const DefaultMaxRounds = 8
+
+// Run streams req through provider with the registry's tools attached. Each
+// time the model answers with tool calls, the assistant turn and the tool
+// results are appended to the conversation, reported through onMessage (so
+// callers can persist them) and the request is sent again. Text streamed
+// during a tool-call turn also reaches onChunk before that turn is reported.
+// Run returns once the model replies without calling a tool.
+func Run(ctx context.Context, provider ai.AIProvider, req types.ChatRequest, apiKey string, registry *Registry, onChunk func(types.StreamChunk), onMessage func(types.Message)) error {
+	req.Tools = registry.Tools()
+	req.Messages = append([]types.Message(nil), req.Messages...)
+	for round := 0; round < DefaultMaxRounds; round++ {
+		var content strings.Builder
+		var calls []types.ToolCall
+		err := provider.StreamMessage(ctx, req, apiKey, func(chunk types.StreamChunk) {
+			content.WriteString(chunk.Content)
+			calls = append(calls, chunk.ToolCalls...)
+			onChunk(chunk)
+		})
+		if err != nil {
+			return err
+		}
+		if len(calls) == 0 {
+			return nil
+		}
+		assistant := types.Message{Role: "assistant", Content: content.String(), ToolCalls: calls}
+		req.Messages = append(req.Messages, assistant)
+		onMessage(assistant)
+		for _, call := range calls {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			result := registry.Call(ctx, call)
+			req.Messages = append(req.Messages, result)
+			onMessage(result)
+		}
+	}
+	return errors.NewAIServiceError(provider.Info().Name, "tool calls", fmt.Errorf("no final answer after %d tool rounds", DefaultMaxRounds))
+}
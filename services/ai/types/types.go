@@ -1,9 +1,353 @@
 package types
-package ai
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
 
+// ProviderInfo is the declarative definition of a provider, as listed in the
+// providers file, and what a running provider reports about itself.
 type ProviderInfo struct {
 	Name     string `json:"name"`
 	Endpoint string `json:"endpoint"`
 	Stream   bool   `json:"stream"`
+
+	// Type selects the implementation, e.g. "openai" or "openai-compatible".
+	// Files written before types existed leave it empty; their built-in
+	// providers are then recognised by Name.
+	Type string `json:"type,omitempty"`
+	// BaseURL is the API root, e.g. "http://localhost:11434/v1". It replaces
+	// a built-in type's default; Endpoint is derived from it.
+	BaseURL string `json:"base_url,omitempty"`
+	// Auth is how the key is sent. Built-in types authenticate as their API
+	// requires; "openai-compatible" honours every scheme.
+	Auth AuthScheme `json:"auth,omitempty"`
+	// AuthHeader is the header carrying the key; "Authorization" (the default)
+	// sends "Bearer <key>", any other header receives the raw key.
+	AuthHeader string `json:"auth_header,omitempty"`
+	// AuthParam is the query parameter carrying the key under AuthQuery;
+	// "key" by default.
+	AuthParam string `json:"auth_param,omitempty"`
+	// Headers are sent with every request. Network headers and the auth
+	// header take precedence.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Capabilities declares what the API supports; nil leaves it to the
+	// implementation.
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	// APIKey is an optional key of the provider, used when the caller passes
+	// none. Local servers usually need no key at all. The providers file only
+	// holds it in older versions; the key now lives in the API key store
+	// under KeyRef and is filled in when the provider is instantiated.
+	APIKey string `json:"api_key,omitempty"`
+	// KeyRef is the title of the provider's key in the API key store.
+	KeyRef string `json:"key_ref,omitempty"`
+	// Cassette is the file a "replay" provider plays. On any other provider it
+	// records every exchange into that file for later replay.
+	Cassette string `json:"cassette,omitempty"`
+	// Command and Args launch the executable of a "plugin" provider.
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// Network configures how the provider is reached; nil uses the
+	// environment's proxy settings and the system CA pool.
+	Network *NetworkSettings `json:"network,omitempty"`
+}
+
+// AuthScheme is how a provider receives its API key.
+type AuthScheme string
+
+const (
+	AuthBearer AuthScheme = "bearer" // "Authorization: Bearer <key>"
+	AuthHeader AuthScheme = "header" // The raw key in the AuthHeader header
+	AuthQuery  AuthScheme = "query"  // The key in the AuthParam query parameter
+	AuthNone   AuthScheme = "none"   // No key, e.g. a local server
+)
+
+// Capabilities are the features a provider's API supports. Streaming is
+// only decided when set, so a block listing other features keeps the
+// definition's stream setting.
+type Capabilities struct {
+	Streaming  *bool `json:"streaming,omitempty"`
+	Tools      bool  `json:"tools,omitempty"`
+	Vision     bool  `json:"vision,omitempty"` // Image attachments
+	Embeddings bool  `json:"embeddings,omitempty"`
+	// StreamUsage is the stream_options.include_usage request option of
+	// OpenAI-compatible servers, which some reject.
+	StreamUsage bool `json:"stream_usage,omitempty"`
+}
+
+// NetworkSettings are per-provider transport options. Timeouts are Go
+// durations such as "10s"; empty means no limit beyond the request context.
+type NetworkSettings struct {
+	// Proxy is an http://, https:// or socks5:// URL. Empty falls back to
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+	Proxy string `json:"proxy,omitempty"`
+	// CAFile is a PEM bundle trusted in addition to the system roots, e.g.
+	// the certificate of a TLS-inspecting corporate proxy.
+	CAFile string `json:"ca_file,omitempty"`
+	// Headers are sent with every request; the provider's own auth headers
+	// take precedence.
+	Headers map[string]string `json:"headers,omitempty"`
+	// ConnectTimeout bounds establishing the connection, TLS included.
+	ConnectTimeout string `json:"connect_timeout,omitempty"`
+	// ReadTimeout bounds waiting for the response headers and, while a
+	// response streams, the silence between two reads.
+	ReadTimeout string `json:"read_timeout,omitempty"`
+}
+
+// ModelInfo describes a model advertised by a provider's models endpoint.
+// Zero values mean the endpoint did not report the field. It is tagged
+// because the model catalog caches it.
+type ModelInfo struct {
+	ID            string   `json:"id"`
+	OwnedBy       string   `json:"owned_by,omitempty"`
+	ContextWindow int      `json:"context_window,omitempty"`
+	InputPrice    float64  `json:"input_price,omitempty"`  // USD per million prompt tokens
+	OutputPrice   float64  `json:"output_price,omitempty"` // USD per million completion tokens
+	Modalities    []string `json:"modalities,omitempty"`   // Accepted inputs, e.g. "text", "image"
+}
+
+// Message is a single role/content entry in a chat request.
+// Assistant turns may carry ToolCalls; their results come back as "tool"
+// messages naming the call (ToolCallID) and the tool (Name) they answer.
+// Like the request it is tagged for plugins and cassettes.
+type Message struct {
+	Role        string       `json:"role"`
+	Content     string       `json:"content"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	ToolCalls   []ToolCall   `json:"tool_calls,omitempty"`
+	ToolCallID  string       `json:"tool_call_id,omitempty"`
+	Name        string       `json:"name,omitempty"`
+}
+
+// AttachmentKind tells providers how to present an attachment to the model.
+type AttachmentKind string
+
+const (
+	AttachmentImage    AttachmentKind = "image"
+	AttachmentDocument AttachmentKind = "document"
+)
+
+// Attachment is a file sent alongside a message's text. Chats persist only
+// the reference; Data is filled from Path when the message is sent.
+type Attachment struct {
+	Kind      AttachmentKind `json:"kind"`
+	MediaType string         `json:"media_type"`
+	Name      string         `json:"name,omitempty"`
+	Path      string         `json:"path,omitempty"`
+	// Size is the length of the file in bytes, recorded when it is
+	// attached so token estimates need not read it again.
+	Size int64  `json:"size,omitempty"`
+	Data []byte `json:"-"`
+}
+
+// Bytes returns the attachment contents, reading Path if Data is not set.
+func (a Attachment) Bytes() ([]byte, error) {
+	if a.Data != nil {
+		return a.Data, nil
+	}
+	return os.ReadFile(a.Path)
+}
+
+// Tool describes a function the model may call. Parameters is the JSON
+// Schema of the arguments object; nil means the tool takes no arguments.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a model's request to run a tool. Arguments holds the raw JSON
+// object produced by the model. It is tagged because chats persist it.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ChatRequest is the provider-agnostic description of a chat completion.
+// Optional sampling parameters are pointers so that "unset" can be told
+// apart from an explicit zero (e.g. temperature 0). It is tagged because
+// plugins receive it and cassettes record it.
+type ChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	TopP        *float64  `json:"top_p,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Stop        []string  `json:"stop,omitempty"`
+	Tools       []Tool    `json:"tools,omitempty"`
+	// ThinkingBudget, when positive, lets a reasoning model think with up to
+	// that many tokens before answering, for providers that take a budget.
+	ThinkingBudget int `json:"thinking_budget_tokens,omitempty"`
+	// ResponseFormat, when set, asks for a JSON reply (see ResponseFormat).
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat asks for a JSON reply. With a Schema the reply must match
+// it; providers that support schemas enforce it server-side, the others are
+// instructed in the prompt. Without one any JSON object is accepted.
+type ResponseFormat struct {
+	Name   string          `json:"name,omitempty"`   // Identifies the schema, e.g. "theme"
+	Schema json.RawMessage `json:"schema,omitempty"` // JSON Schema of the reply; nil for any object
 }
 
+// Instruction is the prompt text asking for the format, for providers that
+// cannot enforce it.
+func (f *ResponseFormat) Instruction() string {
+	if len(f.Schema) == 0 {
+		return "Respond only with a JSON object, without explanations or code fences."
+	}
+	return "Respond only with a JSON value matching this JSON Schema, without explanations or code fences:\n" + string(f.Schema)
+}
+
+// GenerationParams are the sampling settings of a request; nil or zero
+// fields leave the provider's default. It is tagged because chats and model
+// defaults persist it.
+type GenerationParams struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	// ThinkingBudget enables extended thinking with that many tokens; 0
+	// leaves it off.
+	ThinkingBudget int `json:"thinking_budget_tokens,omitempty"`
+}
+
+// Merge returns p with every field set in override replacing its own, e.g.
+// chat settings over a model's defaults.
+func (p GenerationParams) Merge(override *GenerationParams) GenerationParams {
+	if override == nil {
+		return p
+	}
+	if override.Temperature != nil {
+		p.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		p.TopP = override.TopP
+	}
+	if override.MaxTokens > 0 {
+		p.MaxTokens = override.MaxTokens
+	}
+	if len(override.Stop) > 0 {
+		p.Stop = override.Stop
+	}
+	if override.ThinkingBudget > 0 {
+		p.ThinkingBudget = override.ThinkingBudget
+	}
+	return p
+}
+
+// Apply sets the request's sampling settings from p.
+func (p GenerationParams) Apply(req *ChatRequest) {
+	req.Temperature = p.Temperature
+	req.TopP = p.TopP
+	req.MaxTokens = p.MaxTokens
+	req.Stop = p.Stop
+	req.ThinkingBudget = p.ThinkingBudget
+}
+
+// ChatResponse is the result of a non-streaming chat completion.
+type ChatResponse struct {
+	Model        string     `json:"model,omitempty"`
+	Content      string     `json:"content"`
+	FinishReason string     `json:"finish_reason,omitempty"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	Usage        *Usage     `json:"usage,omitempty"`
+	Provider     string     `json:"provider,omitempty"`  // Set by fallback chains to the provider that answered
+	Reasoning    string     `json:"reasoning,omitempty"` // The model's thinking, for providers that expose it
+}
+
+// StreamChunk is a single delta delivered while a response is streaming.
+// Tool calls are assembled by the provider and delivered whole, never as
+// partial fragments. Usage, when the provider reports it, arrives once with
+// the totals for the whole response. Reasoning carries thinking deltas of
+// reasoning models; they precede the answer and are never part of Content.
+type StreamChunk struct {
+	Content      string     `json:"content,omitempty"`
+	Reasoning    string     `json:"reasoning,omitempty"`
+	FinishReason string     `json:"finish_reason,omitempty"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	Usage        *Usage     `json:"usage,omitempty"`
+	Provider     string     `json:"provider,omitempty"` // Set by fallback chains to the provider that answered
+	Model        string     `json:"model,omitempty"`    // Set by fallback chains to the model that answered
+}
+
+// Embedding is the vector an embedding model assigned to one input.
+type Embedding []float32
+
+// Cosine returns the cosine similarity of e and other, from -1 to 1, or 0
+// when either is a zero vector or their dimensions differ.
+func (e Embedding) Cosine(other Embedding) float64 {
+	if len(e) != len(other) {
+		return 0
+	}
+	var dot, a, b float64
+	for i := range e {
+		dot += float64(e[i]) * float64(other[i])
+		a += float64(e[i]) * float64(e[i])
+		b += float64(other[i]) * float64(other[i])
+	}
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(a*b)
+}
+
+// EmbeddingResponse is the result of embedding a list of inputs.
+type EmbeddingResponse struct {
+	Model      string
+	Embeddings []Embedding // One per input, in input order
+	Usage      *Usage      // Prompt tokens only; embeddings produce no completion
+}
+
+// Usage is the token count a provider reported for one exchange, plus its
+// cost in USD once priced. It is tagged because chats persist it.
+type Usage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	ReasoningTokens  int     `json:"reasoning_tokens,omitempty"` // Part of CompletionTokens spent thinking
+	Cost             float64 `json:"cost,omitempty"`
+}
+
+// Add accumulates other into u.
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.ReasoningTokens += other.ReasoningTokens
+	u.Cost += other.Cost
+}
+
+// StreamMetrics are the timings of one streamed response, from sending the
+// request to the end of the stream. It is tagged because chats persist it,
+// so providers can be compared over time.
+type StreamMetrics struct {
+	TimeToFirstToken time.Duration `json:"ttft_ns"`
+	Duration         time.Duration `json:"duration_ns"`
+	Tokens           int           `json:"tokens"`              // Completion tokens, reasoning included
+	Estimated        bool          `json:"estimated,omitempty"` // Tokens were counted locally; the provider reported none
+}
+
+// TokensPerSecond is the generation rate after the first token, or 0 before
+// one arrived.
+func (m StreamMetrics) TokensPerSecond() float64 {
+	generating := m.Duration - m.TimeToFirstToken
+	if m.Tokens == 0 || m.TimeToFirstToken == 0 || generating <= 0 {
+		return 0
+	}
+	return float64(m.Tokens) / generating.Seconds()
+}
+
+// String describes the metrics for a status line, e.g. "TTFT 0.8s · 42
+// tok/s · 3.1s". Before the first token only the wait is shown.
+func (m StreamMetrics) String() string {
+	total := m.Duration.Round(100 * time.Millisecond)
+	if m.TimeToFirstToken == 0 {
+		return fmt.Sprintf("waiting %s", total)
+	}
+	ttft := m.TimeToFirstToken.Round(10 * time.Millisecond)
+	return fmt.Sprintf("TTFT %s · %.0f tok/s · %s", ttft, m.TokensPerSecond(), total)
+}
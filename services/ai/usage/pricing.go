@@ -0,0 +1,127 @@
+// Package usage prices token usage and aggregates spend for reporting.
+package usage
+
+import (
+	"aichat/services/ai/types"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Price is what a model charges in USD per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// defaultPrices holds list prices by model ID prefix; the longest matching
+// prefix wins. Entries loaded with LoadPricing take precedence.
+var defaultPrices = map[string]Price{
+	"gpt-3.5-turbo":     {Input: 0.50, Output: 1.50},
+	"gpt-4":             {Input: 30, Output: 60},
+	"gpt-4-turbo":       {Input: 10, Output: 30},
+	"gpt-4o":            {Input: 2.50, Output: 10},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4.1":           {Input: 2, Output: 8},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":      {Input: 0.10, Output: 0.40},
+	"o1":                {Input: 15, Output: 60},
+	"o3":                {Input: 2, Output: 8},
+	"o3-mini":           {Input: 1.10, Output: 4.40},
+	"o4-mini":           {Input: 1.10, Output: 4.40},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-sonnet-4":   {Input: 3, Output: 15},
+	"claude-3-opus":     {Input: 15, Output: 75},
+	"claude-opus-4":     {Input: 15, Output: 75},
+	"gemini-1.5-flash":  {Input: 0.075, Output: 0.30},
+	"gemini-1.5-pro":    {Input: 1.25, Output: 5},
+	"gemini-2.0-flash":  {Input: 0.10, Output: 0.40},
+	"gemini-2.5-flash":  {Input: 0.30, Output: 2.50},
+	"gemini-2.5-pro":    {Input: 1.25, Output: 10},
+}
+
+var (
+	pricesMu sync.RWMutex
+	custom   = map[string]Price{}
+	catalog  = map[string]Price{}
+)
+
+// RegisterModels records prices reported by a provider's model list (e.g.
+// OpenRouter's). They rank below LoadPricing overrides and above the
+// built-in table.
+func RegisterModels(models []types.ModelInfo) {
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	for _, m := range models {
+		if m.InputPrice > 0 || m.OutputPrice > 0 {
+			catalog[strings.ToLower(m.ID)] = Price{Input: m.InputPrice, Output: m.OutputPrice}
+		}
+	}
+}
+
+// LoadPricing reads a JSON object of model ID (or prefix) to Price and uses
+// it ahead of the built-in table, e.g. for negotiated rates or new models.
+// A missing file is not an error.
+func LoadPricing(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	prices := map[string]Price{}
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return err
+	}
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	for model, price := range prices {
+		custom[strings.ToLower(model)] = price
+	}
+	return nil
+}
+
+// PriceFor returns the price of model and whether one is known. Vendor
+// prefixes such as OpenRouter's "anthropic/" are ignored when matching.
+func PriceFor(model string) (Price, bool) {
+	id := strings.ToLower(model)
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+	if price, ok := custom[id]; ok {
+		return price, true
+	}
+	if price, ok := catalog[id]; ok {
+		return price, true
+	}
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	for _, table := range []map[string]Price{custom, defaultPrices} {
+		best, found := "", false
+		var price Price
+		for prefix, p := range table {
+			if strings.HasPrefix(id, prefix) && len(prefix) >= len(best) {
+				best, price, found = prefix, p, true
+			}
+		}
+		if found {
+			return price, true
+		}
+	}
+	return Price{}, false
+}
+
+// Cost returns the USD cost of u on model, 0 when the model is not priced
+// (e.g. local models).
+func Cost(model string, u types.Usage) float64 {
+	price, ok := PriceFor(model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.Input + float64(u.CompletionTokens)*price.Output) / 1e6
+}
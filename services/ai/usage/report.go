@@ -0,0 +1,111 @@
+package usage
+
+// report.go - Usage records and spend breakdowns.
+
+import (
+	"aichat/services/ai/types"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Record is one priced exchange with a provider. APIKey is the key's title,
+// never the secret itself.
+type Record struct {
+	Time     time.Time   `json:"time"`
+	Chat     string      `json:"chat,omitempty"`
+	Model    string      `json:"model"`
+	Provider string      `json:"provider"`
+	APIKey   string      `json:"api_key,omitempty"`
+	Usage    types.Usage `json:"usage"`
+}
+
+// Recorder stores usage records, e.g. in the global usage log.
+type Recorder interface {
+	Record(r Record) error
+}
+
+// Line is the spend of one group in a breakdown.
+type Line struct {
+	Key      string
+	Requests int
+	Usage    types.Usage
+}
+
+// Report breaks spend down the ways reimbursement needs it.
+type Report struct {
+	Total      Line
+	ByDay      []Line
+	ByModel    []Line
+	ByProvider []Line
+	ByAPIKey   []Line
+}
+
+// BuildReport aggregates records. Days are in local time and sorted
+// chronologically; other breakdowns are sorted by cost, highest first.
+func BuildReport(records []Record) Report {
+	report := Report{Total: Line{Key: "Total"}}
+	for _, r := range records {
+		report.Total.Requests++
+		report.Total.Usage.Add(r.Usage)
+	}
+	report.ByDay = group(records, func(r Record) string { return r.Time.Local().Format("2006-01-02") })
+	sort.Slice(report.ByDay, func(i, j int) bool { return report.ByDay[i].Key < report.ByDay[j].Key })
+	report.ByModel = byCost(group(records, func(r Record) string { return r.Model }))
+	report.ByProvider = byCost(group(records, func(r Record) string { return r.Provider }))
+	report.ByAPIKey = byCost(group(records, func(r Record) string { return r.APIKey }))
+	return report
+}
+
+func group(records []Record, key func(Record) string) []Line {
+	index := map[string]int{}
+	var lines []Line
+	for _, r := range records {
+		k := key(r)
+		if k == "" {
+			k = "(unknown)"
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(lines)
+			index[k] = i
+			lines = append(lines, Line{Key: k})
+		}
+		lines[i].Requests++
+		lines[i].Usage.Add(r.Usage)
+	}
+	return lines
+}
+
+func byCost(lines []Line) []Line {
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Usage.Cost > lines[j].Usage.Cost })
+	return lines
+}
+
+// String formats the line as a fixed-width row. Thinking tokens are the part
+// of the output reasoning models spent before answering.
+func (l Line) String() string {
+	return fmt.Sprintf("%-28s %6d req %10d in %10d out %9d thinking  $%9.4f", l.Key, l.Requests, l.Usage.PromptTokens, l.Usage.CompletionTokens, l.Usage.ReasoningTokens, l.Usage.Cost)
+}
+
+// Lines renders the report as text rows with section headings.
+func (r Report) Lines() []string {
+	out := []string{r.Total.String()}
+	sections := []struct {
+		title string
+		lines []Line
+	}{
+		{"By day", r.ByDay},
+		{"By model", r.ByModel},
+		{"By provider", r.ByProvider},
+		{"By API key", r.ByAPIKey},
+	}
+	for _, section := range sections {
+		out = append(out, "", section.title+" "+strings.Repeat("-", 20))
+		for _, line := range section.lines {
+			out = append(out, line.String())
+		}
+	}
+	return out
+}
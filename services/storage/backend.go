@@ -0,0 +1,104 @@
+package storage
+
+// backend.go - Chooses between the JSON and SQLite repositories.
+
+import (
+	"aichat/errors"
+	"log/slog"
+	"path/filepath"
+)
+
+// Backend names, as written in settings.ini.
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
+// sqliteFile is the database of the SQLite backend, next to the JSON files.
+const sqliteFile = "aichat.db"
+
+// Backend is the set of repositories of one storage backend.
+type Backend struct {
+	Name    string
+	Chats   ChatRepository
+	Prompts PromptRepository
+	Models  ModelRepository
+	Keys    APIKeyRepository // nil for JSON; keys stay in api_keys.json
+	store   *SQLiteStore
+}
+
+// OpenBackend opens the named backend over the config directory dir
+// (src/.config by default). The SQLite backend imports the JSON files on
+// first open; the JSON files are kept, so switching back loses nothing
+// written before the switch.
+func OpenBackend(name, dir string) (*Backend, error) {
+	if dir == "" {
+		dir = "src/.config"
+	}
+	switch name {
+	case "", BackendJSON:
+		return &Backend{
+			Name:    BackendJSON,
+			Chats:   NewJSONChatRepository(filepath.Join(dir, "chats")),
+			Prompts: NewJSONPromptRepository(filepath.Join(dir, "prompts.json")),
+			Models:  NewJSONModelRepository(filepath.Join(dir, "models.json")),
+		}, nil
+	case BackendSQLite:
+		path := filepath.Join(dir, sqliteFile)
+		store, err := OpenSQLiteStore(path)
+		if err != nil {
+			return nil, errors.NewStorageError("open database", path, err)
+		}
+		report, err := store.ImportJSON(dir)
+		if err != nil {
+			store.Close()
+			return nil, errors.NewStorageError("import JSON", dir, err)
+		}
+		if !report.Skipped {
+			slog.Info("Imported JSON storage into SQLite", "chats", report.Chats, "prompts", report.Prompts, "models", report.Models, "keys", report.Keys,
+				"model_params", report.ModelParams, "catalogs", report.Catalogs, "usage", report.Usage)
+		}
+		return &Backend{
+			Name:    BackendSQLite,
+			Chats:   store.Chats(),
+			Prompts: store.Prompts(),
+			Models:  store.Models(),
+			Keys:    store.APIKeys(),
+			store:   store,
+		}, nil
+	default:
+		return nil, errors.NewValidationError("backend", "unknown storage backend \""+name+"\"; use json or sqlite")
+	}
+}
+
+// SQLite returns the backend's database, or nil for the JSON backend. The
+// app's repositories (services/storage/repositories) keep their files unless
+// it is set.
+func (b *Backend) SQLite() *SQLiteStore {
+	return b.store
+}
+
+// Close releases the backend's database, if it has one.
+func (b *Backend) Close() error {
+	if b.store == nil {
+		return nil
+	}
+	return b.store.Close()
+}
+
+var current *Backend
+
+// SetCurrent makes b the backend returned by Current.
+func SetCurrent(b *Backend) {
+	current = b
+}
+
+// Current returns the backend opened at startup, or the JSON backend when
+// none was set. Repositories pick their storage from it when created, so it
+// must be set before the first one is.
+func Current() *Backend {
+	if current == nil {
+		current, _ = OpenBackend(BackendJSON, "")
+	}
+	return current
+}
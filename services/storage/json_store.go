@@ -5,6 +5,7 @@ package storage
 import (
 	"aichat/types"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -59,6 +60,9 @@ func (r *JSONChatRepository) Save(chat *types.ChatFile) error {
 	if chat == nil || chat.Metadata.Title == "" {
 		return os.ErrInvalid
 	}
+	if err := writeAttachments(filepath.Join(r.dir, "attachments"), chat); err != nil {
+		return err
+	}
 	path := filepath.Join(r.dir, chat.Metadata.Title+".json")
 	data, err := json.MarshalIndent(chat, "", "  ")
 	if err != nil {
@@ -71,6 +75,35 @@ func (r *JSONChatRepository) Save(chat *types.ChatFile) error {
 	return os.Rename(tmp, path)
 }
 
+// writeAttachments writes attachments that only exist in memory (e.g. pasted
+// images) to dir, the attachments/ folder next to the chats, so the chat can
+// store a reference to them instead of the data itself.
+func writeAttachments(dir string, chat *types.ChatFile) error {
+	for i := range chat.Messages {
+		msg := &chat.Messages[i]
+		for j := range msg.Attachments {
+			a := &msg.Attachments[j]
+			if a.Path != "" || a.Data == nil {
+				continue
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			base := "attachment"
+			if a.Name != "" {
+				base = filepath.Base(a.Name)
+			}
+			name := fmt.Sprintf("%s-%d-%d-%s", chat.Metadata.Title, msg.MessageNumber, j, base)
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, a.Data, 0644); err != nil {
+				return err
+			}
+			a.Path = path
+		}
+	}
+	return nil
+}
+
 func (r *JSONChatRepository) Delete(name string) error {
 	path := filepath.Join(r.dir, name+".json")
 	return os.Remove(path)
@@ -1,5 +1,284 @@
 package storage
 package storage
 
-// TODO: Implement data migration and version handling.
+// migrations.go - Schema versions of the SQLite store, and the one-shot
+// import of the JSON layout into it.
 
+import (
+	aitypes "aichat/services/ai/types"
+	"aichat/services/ai/usage"
+	"aichat/types"
+	"aichat/types/flows"
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// schema holds one statement list per version; a database at version n runs
+// schema[n:] in order. Append new versions, never edit old ones.
+var schema = [][]string{
+	{
+		`CREATE TABLE meta (key TEXT PRIMARY KEY, value TEXT NOT NULL)`,
+		`CREATE TABLE chats (
+			title       TEXT PRIMARY KEY,
+			created_at  INTEGER NOT NULL DEFAULT 0,
+			modified_at INTEGER NOT NULL DEFAULT 0,
+			favorite    INTEGER NOT NULL DEFAULT 0,
+			model       TEXT NOT NULL DEFAULT '',
+			metadata    TEXT NOT NULL
+		)`,
+		`CREATE INDEX chats_title_nocase ON chats (title COLLATE NOCASE)`,
+		`CREATE INDEX chats_created ON chats (created_at)`,
+		`CREATE INDEX chats_modified ON chats (modified_at)`,
+		`CREATE INDEX chats_favorite ON chats (favorite, modified_at)`,
+		`CREATE TABLE messages (
+			chat_title TEXT NOT NULL REFERENCES chats (title) ON DELETE CASCADE ON UPDATE CASCADE,
+			position   INTEGER NOT NULL,
+			role       TEXT NOT NULL,
+			data       TEXT NOT NULL,
+			PRIMARY KEY (chat_title, position)
+		)`,
+		`CREATE TABLE prompts (
+			name       TEXT PRIMARY KEY,
+			content    TEXT NOT NULL,
+			is_default INTEGER NOT NULL DEFAULT 0,
+			position   INTEGER NOT NULL
+		)`,
+		`CREATE TABLE models (
+			name     TEXT PRIMARY KEY,
+			provider TEXT NOT NULL DEFAULT '',
+			data     TEXT NOT NULL,
+			position INTEGER NOT NULL
+		)`,
+		`CREATE TABLE api_keys (
+			title    TEXT PRIMARY KEY,
+			key      TEXT NOT NULL,
+			url      TEXT NOT NULL DEFAULT '',
+			active   INTEGER NOT NULL DEFAULT 0,
+			position INTEGER NOT NULL
+		)`,
+	},
+	{
+		`CREATE TABLE model_params (
+			model  TEXT PRIMARY KEY,
+			params TEXT NOT NULL
+		)`,
+		`CREATE TABLE model_catalog (
+			provider   TEXT PRIMARY KEY,
+			fetched_at INTEGER NOT NULL DEFAULT 0,
+			models     TEXT NOT NULL
+		)`,
+		`CREATE TABLE usage (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			recorded_at INTEGER NOT NULL DEFAULT 0,
+			data        TEXT NOT NULL
+		)`,
+		`CREATE INDEX usage_recorded ON usage (recorded_at)`,
+	},
+}
+
+// migrate brings the schema to the latest version, each version in its own
+// transaction. The version is SQLite's user_version.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(schema) {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", version, len(schema))
+	}
+	for v := version; v < len(schema); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range schema[v] {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migrating to schema version %d: %w", v+1, err)
+			}
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, v+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importedKey marks in meta that the JSON layout was imported.
+const importedKey = "json_imported_at"
+
+// ImportReport counts what ImportJSON copied.
+type ImportReport struct {
+	Chats, Prompts, Models, Keys int
+	ModelParams, Catalogs, Usage int
+	Skipped                      bool // Already imported earlier; nothing was read
+}
+
+// ImportJSON copies the JSON layout under dir into the store: chats.json and
+// the per-chat files in chats/, prompts.json, models.json, api_keys.json,
+// model_params.json, model_catalog.json and usage.jsonl. Missing files are
+// skipped. It runs once: everything is copied in one
+// transaction that also records the import, and later calls do nothing.
+// The JSON files are left in place.
+func (s *SQLiteStore) ImportJSON(dir string) (ImportReport, error) {
+	var report ImportReport
+	var done string
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, importedKey).Scan(&done)
+	switch {
+	case err == nil:
+		report.Skipped = true
+		return report, nil
+	case err != sql.ErrNoRows:
+		return report, err
+	}
+
+	var chats []types.ChatFile
+	if err := readJSON(filepath.Join(dir, "chats.json"), &chats); err != nil {
+		return report, err
+	}
+	// Per-chat files win over chats.json for the same title.
+	files, err := NewJSONChatRepository(filepath.Join(dir, "chats")).GetAll()
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	for _, c := range files {
+		chats = append(chats, *c)
+	}
+	var prompts []*flows.Prompt
+	if err := readJSON(filepath.Join(dir, "prompts.json"), &prompts); err != nil {
+		return report, err
+	}
+	var models types.ModelsConfig
+	if err := readJSON(filepath.Join(dir, "models.json"), &models); err != nil {
+		return report, err
+	}
+	var keys types.APIKeysConfig
+	if err := readJSON(filepath.Join(dir, "api_keys.json"), &keys); err != nil {
+		return report, err
+	}
+	var params map[string]aitypes.GenerationParams
+	if err := readJSON(filepath.Join(dir, "model_params.json"), &params); err != nil {
+		return report, err
+	}
+	var catalog map[string]ProviderCatalog
+	if err := readJSON(filepath.Join(dir, "model_catalog.json"), &catalog); err != nil {
+		return report, err
+	}
+	records, err := readUsageLog(filepath.Join(dir, "usage.jsonl"))
+	if err != nil {
+		return report, err
+	}
+
+	err = s.inTx(func(tx *sql.Tx) error {
+		titles := map[string]bool{}
+		for i := range chats {
+			if strings.TrimSpace(chats[i].Metadata.Title) == "" {
+				continue
+			}
+			if err := saveChat(tx, &chats[i]); err != nil {
+				return fmt.Errorf("importing chat %q: %w", chats[i].Metadata.Title, err)
+			}
+			titles[chats[i].Metadata.Title] = true
+		}
+		report.Chats = len(titles)
+		for _, p := range prompts {
+			if p == nil || p.Name == "" {
+				continue
+			}
+			if err := savePrompt(tx, p); err != nil {
+				return fmt.Errorf("importing prompt %q: %w", p.Name, err)
+			}
+			report.Prompts++
+		}
+		for i := range models.Models {
+			if models.Models[i].Name == "" {
+				continue
+			}
+			if err := saveModel(tx, &models.Models[i]); err != nil {
+				return fmt.Errorf("importing model %q: %w", models.Models[i].Name, err)
+			}
+			report.Models++
+		}
+		for _, k := range keys.Keys {
+			if k.Title == "" {
+				continue
+			}
+			if err := saveAPIKey(tx, k); err != nil {
+				return fmt.Errorf("importing key %q: %w", k.Title, err)
+			}
+			report.Keys++
+		}
+		for model, p := range params {
+			if err := saveModelParams(tx, model, p); err != nil {
+				return fmt.Errorf("importing settings of %q: %w", model, err)
+			}
+			report.ModelParams++
+		}
+		for provider, entry := range catalog {
+			if err := saveProviderCatalog(tx, provider, entry); err != nil {
+				return fmt.Errorf("importing model catalog of %q: %w", provider, err)
+			}
+			report.Catalogs++
+		}
+		for _, record := range records {
+			if err := saveUsage(tx, record); err != nil {
+				return fmt.Errorf("importing usage: %w", err)
+			}
+			report.Usage++
+		}
+		_, err := tx.Exec(`INSERT INTO meta (key, value) VALUES (?, ?)`, importedKey, time.Now().UTC().Format(time.RFC3339))
+		return err
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+	return report, nil
+}
+
+// readJSON decodes the file at path into out, leaving out untouched when the
+// file does not exist.
+func readJSON(path string, out any) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// readUsageLog reads the JSON lines of the usage log at path, skipping lines
+// torn by an interrupted write. A missing log has no records.
+func readUsageLog(path string) ([]usage.Record, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []usage.Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record usage.Record
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
@@ -5,6 +5,7 @@ package repositories
 import (
 	"aichat/errors"
 	"aichat/services/cache"
+	"aichat/services/storage"
 	"aichat/types"
 )
 
@@ -12,18 +13,30 @@ import (
 type CachedAPIKeyRepository struct {
 	cacheManager *cache.CacheManager
 	filePath     string
+	db           *storage.SQLiteAPIKeyRepository // set for the SQLite backend, which needs no cache
 }
 
 // NewCachedAPIKeyRepository creates a new cached API key repository
 func NewCachedAPIKeyRepository() *CachedAPIKeyRepository {
-	return &CachedAPIKeyRepository{
+	r := &CachedAPIKeyRepository{
 		cacheManager: cache.NewCacheManager(),
 		filePath:     "src/.config/api_keys.json",
 	}
+	if store := storage.Current().SQLite(); store != nil {
+		r.db = store.APIKeys()
+	}
+	return r
 }
 
 // GetAll retrieves all API keys from cache or loads from file
 func (r *CachedAPIKeyRepository) GetAll() ([]types.APIKey, error) {
+	if r.db != nil {
+		keys, err := r.db.GetAll()
+		if err != nil {
+			return nil, errors.NewStorageError("get_apikeys", "api_keys", err)
+		}
+		return keys, nil
+	}
 	keys, err := r.cacheManager.GetAPIKeys(r.filePath)
 	if err != nil {
 		return nil, errors.NewCacheError("get_apikeys", err)
@@ -200,8 +213,11 @@ func (r *CachedAPIKeyRepository) GetStats() map[string]cache.CacheStats {
 	return r.cacheManager.GetStats()
 }
 
-// saveToFile saves API keys to the JSON file
+// saveToFile saves API keys to the JSON file, or the database
 func (r *CachedAPIKeyRepository) saveToFile(keys []types.APIKey) error {
+	if r.db != nil {
+		return r.db.SaveAll(keys)
+	}
 	config := types.APIKeysConfig{Keys: keys}
 	return types.SaveAPIKeysToFile(config, r.filePath)
 }
@@ -5,6 +5,7 @@ package repositories
 import (
 	"aichat/errors"
 	"aichat/services/cache"
+	"aichat/services/storage"
 	"aichat/types"
 )
 
@@ -12,18 +13,34 @@ import (
 type CachedModelRepository struct {
 	cacheManager *cache.CacheManager
 	filePath     string
+	db           *storage.SQLiteModelRepository // set for the SQLite backend, which needs no cache
 }
 
 // NewCachedModelRepository creates a new cached model repository
 func NewCachedModelRepository() *CachedModelRepository {
-	return &CachedModelRepository{
+	r := &CachedModelRepository{
 		cacheManager: cache.NewCacheManager(),
 		filePath:     "src/.config/models.json",
 	}
+	if store := storage.Current().SQLite(); store != nil {
+		r.db = store.Models()
+	}
+	return r
 }
 
 // GetAll retrieves all models from cache or loads from file
 func (r *CachedModelRepository) GetAll() ([]types.Model, error) {
+	if r.db != nil {
+		stored, err := r.db.GetAll()
+		if err != nil {
+			return nil, errors.NewStorageError("get_models", "models", err)
+		}
+		modelList := make([]types.Model, len(stored))
+		for i, m := range stored {
+			modelList[i] = *m
+		}
+		return modelList, nil
+	}
 	modelList, err := r.cacheManager.GetModels(r.filePath)
 	if err != nil {
 		return nil, errors.NewCacheError("get_models", err)
@@ -174,8 +191,15 @@ func (r *CachedModelRepository) GetStats() map[string]cache.CacheStats {
 	return r.cacheManager.GetStats()
 }
 
-// saveToFile saves models to the JSON file
+// saveToFile saves models to the JSON file, or the database
 func (r *CachedModelRepository) saveToFile(modelList []types.Model) error {
+	if r.db != nil {
+		stored := make([]*types.Model, len(modelList))
+		for i := range modelList {
+			stored[i] = &modelList[i]
+		}
+		return r.db.SaveAll(stored)
+	}
 	return types.SaveModelsToFile(modelList, r.filePath)
 }
 
@@ -5,6 +5,7 @@ package repositories
 import (
 	"aichat/errors"
 	"aichat/services/cache"
+	"aichat/services/storage"
 	"aichat/types/flows"
 )
 
@@ -12,18 +13,34 @@ import (
 type CachedPromptRepository struct {
 	cacheManager *cache.CacheManager
 	filePath     string
+	db           *storage.SQLitePromptRepository // set for the SQLite backend, which needs no cache
 }
 
 // NewCachedPromptRepository creates a new cached prompt repository
 func NewCachedPromptRepository() *CachedPromptRepository {
-	return &CachedPromptRepository{
+	r := &CachedPromptRepository{
 		cacheManager: cache.NewCacheManager(),
 		filePath:     "src/.config/prompts.json",
 	}
+	if store := storage.Current().SQLite(); store != nil {
+		r.db = store.Prompts()
+	}
+	return r
 }
 
 // GetAll retrieves all prompts from cache or loads from file
 func (r *CachedPromptRepository) GetAll() ([]flows.Prompt, error) {
+	if r.db != nil {
+		stored, err := r.db.GetAll()
+		if err != nil {
+			return nil, errors.NewStorageError("get_prompts", "prompts", err)
+		}
+		prompts := make([]flows.Prompt, len(stored))
+		for i, p := range stored {
+			prompts[i] = *p
+		}
+		return prompts, nil
+	}
 	prompts, err := r.cacheManager.GetPrompts(r.filePath)
 	if err != nil {
 		return nil, errors.NewCacheError("get_prompts", err)
@@ -174,8 +191,15 @@ func (r *CachedPromptRepository) GetStats() map[string]cache.CacheStats {
 	return r.cacheManager.GetStats()
 }
 
-// saveToFile saves prompts to the JSON file
+// saveToFile saves prompts to the JSON file, or the database
 func (r *CachedPromptRepository) saveToFile(prompts []flows.Prompt) error {
+	if r.db != nil {
+		stored := make([]*flows.Prompt, len(prompts))
+		for i := range prompts {
+			stored[i] = &prompts[i]
+		}
+		return r.db.SaveAll(stored)
+	}
 	return flows.SavePromptsToFile(prompts, r.filePath)
 }
 
@@ -6,20 +6,39 @@ import (
 	"os"
 	"path/filepath"
 
+	"aichat/services/storage"
 	"aichat/types"
 )
 
 const chatsConfigPath = "src/.config/chats.json"
 
+// ChatRepository keeps chats in chats.json, or in the database when the
+// current storage backend is SQLite.
 type ChatRepository struct {
 	file string
+	db   *storage.SQLiteChatRepository
 }
 
 func NewChatRepository() *ChatRepository {
-	return &ChatRepository{file: chatsConfigPath}
+	r := &ChatRepository{file: chatsConfigPath}
+	if store := storage.Current().SQLite(); store != nil {
+		r.db = store.Chats()
+	}
+	return r
 }
 
 func (r *ChatRepository) GetAll() ([]types.ChatFile, error) {
+	if r.db != nil {
+		stored, err := r.db.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		chats := make([]types.ChatFile, len(stored))
+		for i, c := range stored {
+			chats[i] = *c
+		}
+		return chats, nil
+	}
 	data, err := os.ReadFile(r.file)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -35,6 +54,13 @@ func (r *ChatRepository) GetAll() ([]types.ChatFile, error) {
 }
 
 func (r *ChatRepository) SaveAll(chats []types.ChatFile) error {
+	if r.db != nil {
+		stored := make([]*types.ChatFile, len(chats))
+		for i := range chats {
+			stored[i] = &chats[i]
+		}
+		return r.db.SaveAll(stored)
+	}
 	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
 		return err
 	}
@@ -46,6 +72,9 @@ func (r *ChatRepository) SaveAll(chats []types.ChatFile) error {
 }
 
 func (r *ChatRepository) Add(chat types.ChatFile) error {
+	if r.db != nil {
+		return r.db.Save(&chat)
+	}
 	chats, err := r.GetAll()
 	if err != nil {
 		return err
@@ -54,7 +83,31 @@ func (r *ChatRepository) Add(chat types.ChatFile) error {
 	return r.SaveAll(chats)
 }
 
+// Save replaces the chat with the same title, or adds it.
+func (r *ChatRepository) Save(chat types.ChatFile) error {
+	if r.db != nil {
+		return r.db.Save(&chat)
+	}
+	chats, err := r.GetAll()
+	if err != nil {
+		return err
+	}
+	for i := range chats {
+		if chats[i].Metadata.Title == chat.Metadata.Title {
+			chats[i] = chat
+			return r.SaveAll(chats)
+		}
+	}
+	return r.SaveAll(append(chats, chat))
+}
+
 func (r *ChatRepository) Remove(title string) error {
+	if r.db != nil {
+		if err := r.db.Delete(title); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
 	chats, err := r.GetAll()
 	if err != nil {
 		return err
@@ -69,6 +122,9 @@ func (r *ChatRepository) Remove(title string) error {
 }
 
 func (r *ChatRepository) GetByTitle(title string) (*types.ChatFile, error) {
+	if r.db != nil {
+		return r.db.GetByID(title)
+	}
 	chats, err := r.GetAll()
 	if err != nil {
 		return nil, err
@@ -3,23 +3,35 @@ package repositories
 
 import (
 	"encoding/json"
+	"net/url"
 	"os"
 	"path/filepath"
 
+	"aichat/services/storage"
 	"aichat/types"
 )
 
 const apiKeysConfigPath = "src/.config/api_keys.json"
 
+// APIKeyRepository keeps API keys in api_keys.json, or in the database when
+// the current storage backend is SQLite.
 type APIKeyRepository struct {
 	file string
+	db   *storage.SQLiteAPIKeyRepository
 }
 
 func NewAPIKeyRepository() *APIKeyRepository {
-	return &APIKeyRepository{file: apiKeysConfigPath}
+	r := &APIKeyRepository{file: apiKeysConfigPath}
+	if store := storage.Current().SQLite(); store != nil {
+		r.db = store.APIKeys()
+	}
+	return r
 }
 
 func (r *APIKeyRepository) GetAll() ([]types.APIKey, error) {
+	if r.db != nil {
+		return r.db.GetAll()
+	}
 	data, err := os.ReadFile(r.file)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -34,7 +46,67 @@ func (r *APIKeyRepository) GetAll() ([]types.APIKey, error) {
 	return config.Keys, nil
 }
 
+// KeyForEndpoint returns the stored key whose URL has the same host as
+// endpoint, or "" when there is none. Matching by host keeps a key from being
+// sent to another vendor.
+func (r *APIKeyRepository) KeyForEndpoint(endpoint string) string {
+	k, _ := r.ForEndpoint(endpoint)
+	return k.Key
+}
+
+// ForEndpoint is KeyForEndpoint returning the whole entry, e.g. for its title.
+func (r *APIKeyRepository) ForEndpoint(endpoint string) (types.APIKey, bool) {
+	target, err := url.Parse(endpoint)
+	if err != nil || target.Host == "" {
+		return types.APIKey{}, false
+	}
+	keys, err := r.GetAll()
+	if err != nil {
+		return types.APIKey{}, false
+	}
+	for _, k := range keys {
+		if keyURL, err := url.Parse(k.URL); err == nil && keyURL.Host == target.Host {
+			return k, true
+		}
+	}
+	return types.APIKey{}, false
+}
+
+// Key returns the key titled ref, e.g. the key a provider definition refers
+// to (see ai.KeyStore).
+func (r *APIKeyRepository) Key(ref string) (string, bool) {
+	keys, err := r.GetAll()
+	if err != nil {
+		return "", false
+	}
+	for _, k := range keys {
+		if k.Title == ref {
+			return k.Key, true
+		}
+	}
+	return "", false
+}
+
+// SetKey stores key under the title ref, replacing the key of an entry with
+// that title or adding an inactive one.
+func (r *APIKeyRepository) SetKey(ref, key string) error {
+	keys, err := r.GetAll()
+	if err != nil {
+		return err
+	}
+	for i := range keys {
+		if keys[i].Title == ref {
+			keys[i].Key = key
+			return r.SaveAll(keys)
+		}
+	}
+	return r.SaveAll(append(keys, types.APIKey{Title: ref, Key: key}))
+}
+
 func (r *APIKeyRepository) SaveAll(keys []types.APIKey) error {
+	if r.db != nil {
+		return r.db.SaveAll(keys)
+	}
 	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
 		return err
 	}
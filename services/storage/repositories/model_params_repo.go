@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"aichat/errors"
+	aitypes "aichat/services/ai/types"
+	"aichat/services/storage"
+)
+
+const modelParamsPath = "src/.config/model_params.json"
+
+// ModelParamsRepository stores default sampling settings per model ID, in
+// model_params.json or the SQLite database. Chat settings are applied over
+// them.
+type ModelParamsRepository struct {
+	file string
+	db   *storage.SQLiteModelParamsRepository
+}
+
+func NewModelParamsRepository() *ModelParamsRepository {
+	r := &ModelParamsRepository{file: modelParamsPath}
+	if store := storage.Current().SQLite(); store != nil {
+		r.db = store.ModelParams()
+	}
+	return r
+}
+
+func (r *ModelParamsRepository) GetAll() (map[string]aitypes.GenerationParams, error) {
+	if r.db != nil {
+		params, err := r.db.GetAll()
+		if err != nil {
+			return nil, errors.NewStorageError("load model params", "model_params", err)
+		}
+		return params, nil
+	}
+	params := map[string]aitypes.GenerationParams{}
+	data, err := os.ReadFile(r.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return params, nil
+		}
+		return nil, errors.NewStorageError("load model params", r.file, err)
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, errors.NewStorageError("load model params", r.file, err)
+	}
+	return params, nil
+}
+
+// Get returns a model's defaults, or zero params when none are stored.
+func (r *ModelParamsRepository) Get(model string) (aitypes.GenerationParams, error) {
+	params, err := r.GetAll()
+	if err != nil {
+		return aitypes.GenerationParams{}, err
+	}
+	return params[model], nil
+}
+
+// Set stores a model's defaults; zero params remove them.
+func (r *ModelParamsRepository) Set(model string, p aitypes.GenerationParams) error {
+	empty := p.Temperature == nil && p.TopP == nil && p.MaxTokens == 0 && len(p.Stop) == 0 && p.ThinkingBudget == 0
+	if r.db != nil {
+		var err error
+		if empty {
+			err = r.db.Delete(model)
+		} else {
+			err = r.db.Set(model, p)
+		}
+		if err != nil {
+			return errors.NewStorageError("save model params", "model_params", err)
+		}
+		return nil
+	}
+	params, err := r.GetAll()
+	if err != nil {
+		return err
+	}
+	if empty {
+		delete(params, model)
+	} else {
+		params[model] = p
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return errors.NewStorageError("save model params", r.file, err)
+	}
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return errors.NewStorageError("save model params", r.file, err)
+	}
+	if err := os.WriteFile(r.file, data, 0644); err != nil {
+		return errors.NewStorageError("save model params", r.file, err)
+	}
+	return nil
+}
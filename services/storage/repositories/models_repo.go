@@ -1,5 +1,196 @@
 package repositories
-package repositories
 
-// TODO: Implement AI model configurations and metadata repository.
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"aichat/errors"
+	aitypes "aichat/services/ai/types"
+	"aichat/services/storage"
+	"aichat/types"
+)
+
+const modelCatalogPath = "src/.config/model_catalog.json"
+
+// customProvider groups models added by hand rather than fetched.
+const customProvider = "Custom"
+
+// ModelCatalogRepository caches the models each provider advertises, so the
+// model list keeps working offline. Models are identified by ID; an ID
+// offered by several providers resolves to the first in provider order. The
+// catalog is model_catalog.json, or a table of the SQLite database.
+type ModelCatalogRepository struct {
+	file string
+	db   *storage.SQLiteModelCatalogRepository
+}
+
+func NewModelCatalogRepository() *ModelCatalogRepository {
+	r := &ModelCatalogRepository{file: modelCatalogPath}
+	if store := storage.Current().SQLite(); store != nil {
+		r.db = store.ModelCatalog()
+	}
+	return r
+}
+
+func (r *ModelCatalogRepository) load() (map[string]storage.ProviderCatalog, error) {
+	if r.db != nil {
+		catalog, err := r.db.GetAll()
+		if err != nil {
+			return nil, errors.NewStorageError("load model catalog", "model_catalog", err)
+		}
+		return catalog, nil
+	}
+	catalog := map[string]storage.ProviderCatalog{}
+	data, err := os.ReadFile(r.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return catalog, nil
+		}
+		return nil, errors.NewStorageError("load model catalog", r.file, err)
+	}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, errors.NewStorageError("load model catalog", r.file, err)
+	}
+	return catalog, nil
+}
+
+func (r *ModelCatalogRepository) save(catalog map[string]storage.ProviderCatalog) error {
+	if r.db != nil {
+		if err := r.db.SaveAll(catalog); err != nil {
+			return errors.NewStorageError("save model catalog", "model_catalog", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return errors.NewStorageError("save model catalog", r.file, err)
+	}
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return errors.NewStorageError("save model catalog", r.file, err)
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.NewStorageError("save model catalog", r.file, err)
+	}
+	return os.Rename(tmp, r.file)
+}
+
+// GetByProvider returns a provider's cached models and when they were fetched.
+func (r *ModelCatalogRepository) GetByProvider(provider string) ([]aitypes.ModelInfo, time.Time, error) {
+	catalog, err := r.load()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	entry, ok := catalog[provider]
+	if !ok {
+		return nil, time.Time{}, errors.NewNotFoundError("model catalog", provider)
+	}
+	return entry.Models, entry.FetchedAt, nil
+}
+
+// ReplaceProvider stores a freshly fetched model list for a provider.
+func (r *ModelCatalogRepository) ReplaceProvider(provider string, models []aitypes.ModelInfo, fetchedAt time.Time) error {
+	catalog, err := r.load()
+	if err != nil {
+		return err
+	}
+	catalog[provider] = storage.ProviderCatalog{FetchedAt: fetchedAt, Models: models}
+	return r.save(catalog)
+}
+
+// GetAll returns every cached model, grouped by provider in name order.
+func (r *ModelCatalogRepository) GetAll() ([]*types.Model, error) {
+	catalog, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	providers := make([]string, 0, len(catalog))
+	for name := range catalog {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	var models []*types.Model
+	for _, provider := range providers {
+		for _, info := range catalog[provider].Models {
+			info := info
+			models = append(models, &types.Model{Name: info.ID, Provider: provider, Info: &info})
+		}
+	}
+	return models, nil
+}
+
+func (r *ModelCatalogRepository) GetByID(name string) (*types.Model, error) {
+	models, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range models {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, errors.NewNotFoundError("model", name)
+}
+
+// Save adds or updates a model under its provider, or under "Custom" for
+// hand-entered models.
+func (r *ModelCatalogRepository) Save(model *types.Model) error {
+	if model == nil || model.Name == "" {
+		return errors.NewValidationError("model", "invalid model data")
+	}
+	catalog, err := r.load()
+	if err != nil {
+		return err
+	}
+	provider := model.Provider
+	if provider == "" {
+		provider = customProvider
+	}
+	info := aitypes.ModelInfo{ID: model.Name}
+	if model.Info != nil {
+		info = *model.Info
+		info.ID = model.Name
+	}
+	entry := catalog[provider]
+	replaced := false
+	for i, m := range entry.Models {
+		if m.ID == info.ID {
+			entry.Models[i] = info
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		entry.Models = append(entry.Models, info)
+	}
+	catalog[provider] = entry
+	return r.save(catalog)
+}
 
+// Delete removes a model from every provider that lists it.
+func (r *ModelCatalogRepository) Delete(name string) error {
+	catalog, err := r.load()
+	if err != nil {
+		return err
+	}
+	found := false
+	for provider, entry := range catalog {
+		kept := entry.Models[:0]
+		for _, m := range entry.Models {
+			if m.ID == name {
+				found = true
+				continue
+			}
+			kept = append(kept, m)
+		}
+		entry.Models = kept
+		catalog[provider] = entry
+	}
+	if !found {
+		return errors.NewNotFoundError("model", name)
+	}
+	return r.save(catalog)
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"aichat/services/ai/usage"
+	"aichat/services/storage"
+)
+
+const usageLogPath = "src/.config/usage.jsonl"
+
+// UsageRepository is the global usage log: one JSON record per line, so
+// recording an exchange is a single append, or one row per record in the
+// SQLite database.
+type UsageRepository struct {
+	file string
+	db   *storage.SQLiteUsageRepository
+}
+
+func NewUsageRepository() *UsageRepository {
+	r := &UsageRepository{file: usageLogPath}
+	if store := storage.Current().SQLite(); store != nil {
+		r.db = store.Usage()
+	}
+	return r
+}
+
+func (r *UsageRepository) GetAll() ([]usage.Record, error) {
+	if r.db != nil {
+		return r.db.GetAll()
+	}
+	f, err := os.Open(r.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []usage.Record{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var records []usage.Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record usage.Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue // skip a line torn by an interrupted write
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Record appends a record to the log.
+func (r *UsageRepository) Record(record usage.Record) error {
+	if r.db != nil {
+		return r.db.Record(record)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -33,3 +33,13 @@ type ModelRepository interface {
 	Delete(name string) error
 }
 
+
+// APIKeyRepository defines operations on the stored API keys.
+type APIKeyRepository interface {
+	GetAll() ([]types.APIKey, error)
+	SaveAll(keys []types.APIKey) error
+	Add(key types.APIKey) error
+	Remove(title string) error
+	SetActive(title string) error
+	KeyForEndpoint(endpoint string) string
+}
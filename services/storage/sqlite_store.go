@@ -0,0 +1,696 @@
+package storage
+
+// sqlite_store.go - SQLite-backed repositories for chats, prompts, models, API
+// keys, per-model settings, the model catalog and the usage log, through the
+// pure-Go modernc.org/sqlite driver (no cgo).
+
+import (
+	aitypes "aichat/services/ai/types"
+	"aichat/services/ai/usage"
+	"aichat/types"
+	"aichat/types/flows"
+	"database/sql"
+	"encoding/json"
+	stderrors "errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore is one database file holding every repository. Each write is a
+// single transaction, so a crash never leaves a chat half-saved.
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// OpenSQLiteStore opens (or creates) the database at path and brings its
+// schema up to date.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	// The database holds API keys; create it readable by the owner only, as
+	// api_keys.json is. SQLite gives its WAL files the same mode.
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	// WAL lets the UI read while a save is in progress; the busy timeout
+	// covers writers from other goroutines.
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer; a single connection serializes them here
+	// instead of failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db, path: path}, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// inTx runs fn in a transaction, committed only if fn succeeds.
+func (s *SQLiteStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// --- Chat Repository ---
+// Chats are rows keyed by title with their metadata in indexed columns;
+// messages are rows of their own, rewritten with the chat on each save.
+
+type SQLiteChatRepository struct {
+	store *SQLiteStore
+}
+
+func (s *SQLiteStore) Chats() *SQLiteChatRepository {
+	return &SQLiteChatRepository{store: s}
+}
+
+// GetAll returns every chat, most recently modified first, reading chats
+// and messages in one joined query.
+func (r *SQLiteChatRepository) GetAll() ([]*types.ChatFile, error) {
+	rows, err := r.store.db.Query(`SELECT c.title, c.metadata, m.data
+		FROM chats c LEFT JOIN messages m ON m.chat_title = c.title
+		ORDER BY c.modified_at DESC, c.title, m.position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	chats := []*types.ChatFile{}
+	var chat *types.ChatFile
+	var last string
+	for rows.Next() {
+		var title, metadata string
+		var data sql.NullString
+		if err := rows.Scan(&title, &metadata, &data); err != nil {
+			return nil, err
+		}
+		if chat == nil || title != last {
+			chat, last = &types.ChatFile{}, title
+			if err := json.Unmarshal([]byte(metadata), &chat.Metadata); err != nil {
+				return nil, err
+			}
+			chats = append(chats, chat)
+		}
+		if !data.Valid {
+			continue // a chat without messages
+		}
+		var msg types.Message
+		if err := json.Unmarshal([]byte(data.String), &msg); err != nil {
+			return nil, err
+		}
+		chat.Messages = append(chat.Messages, msg)
+	}
+	return chats, rows.Err()
+}
+
+func (r *SQLiteChatRepository) GetByID(name string) (*types.ChatFile, error) {
+	var chat types.ChatFile
+	var metadata string
+	err := r.store.db.QueryRow(`SELECT metadata FROM chats WHERE title = ?`, name).Scan(&metadata)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(metadata), &chat.Metadata); err != nil {
+		return nil, err
+	}
+	rows, err := r.store.db.Query(`SELECT data FROM messages WHERE chat_title = ? ORDER BY position`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		var msg types.Message
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, err
+		}
+		chat.Messages = append(chat.Messages, msg)
+	}
+	return &chat, rows.Err()
+}
+
+// Save writes the chat and its messages in one transaction. Attachments held
+// only in memory are first written next to the database, as the JSON
+// repository does next to its chat files.
+func (r *SQLiteChatRepository) Save(chat *types.ChatFile) error {
+	if chat == nil || chat.Metadata.Title == "" {
+		return os.ErrInvalid
+	}
+	if err := writeAttachments(filepath.Join(filepath.Dir(r.store.path), "attachments"), chat); err != nil {
+		return err
+	}
+	return r.store.inTx(func(tx *sql.Tx) error {
+		return saveChat(tx, chat)
+	})
+}
+
+// saveChat upserts a chat and replaces its messages within tx.
+func saveChat(tx *sql.Tx, chat *types.ChatFile) error {
+	metadata, err := json.Marshal(chat.Metadata)
+	if err != nil {
+		return err
+	}
+	modified := chat.Metadata.ModifiedAt
+	if modified == 0 {
+		modified = time.Now().Unix()
+	}
+	_, err = tx.Exec(`INSERT INTO chats (title, created_at, modified_at, favorite, model, metadata)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (title) DO UPDATE SET created_at = excluded.created_at, modified_at = excluded.modified_at,
+			favorite = excluded.favorite, model = excluded.model, metadata = excluded.metadata`,
+		chat.Metadata.Title, chat.Metadata.CreatedAt.Unix(), modified, chat.Metadata.Favorite, chat.Metadata.Model, string(metadata))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE chat_title = ?`, chat.Metadata.Title); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO messages (chat_title, position, role, data) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for i, msg := range chat.Messages {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(chat.Metadata.Title, i, msg.Role, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveAll replaces every chat in one transaction.
+func (r *SQLiteChatRepository) SaveAll(chats []*types.ChatFile) error {
+	dir := filepath.Join(filepath.Dir(r.store.path), "attachments")
+	for _, chat := range chats {
+		if err := writeAttachments(dir, chat); err != nil {
+			return err
+		}
+	}
+	return r.store.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM chats`); err != nil {
+			return err
+		}
+		for _, chat := range chats {
+			if chat.Metadata.Title == "" {
+				continue
+			}
+			if err := saveChat(tx, chat); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes a chat; its messages go with it.
+func (r *SQLiteChatRepository) Delete(name string) error {
+	res, err := r.store.db.Exec(`DELETE FROM chats WHERE title = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// GetChatFileInfo describes a chat as a file would be: its title, the size
+// of its messages and its modification time.
+func (r *SQLiteChatRepository) GetChatFileInfo(name string) (os.FileInfo, error) {
+	var modified, size int64
+	err := r.store.db.QueryRow(`SELECT c.modified_at, COALESCE(SUM(LENGTH(m.data)), 0)
+		FROM chats c LEFT JOIN messages m ON m.chat_title = c.title
+		WHERE c.title = ? GROUP BY c.title`, name).Scan(&modified, &size)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return chatFileInfo{name: name, size: size, modTime: time.Unix(modified, 0)}, nil
+}
+
+// chatFileInfo is the os.FileInfo of a chat stored in the database.
+type chatFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (i chatFileInfo) Name() string       { return i.name }
+func (i chatFileInfo) Size() int64        { return i.size }
+func (i chatFileInfo) Mode() os.FileMode  { return 0644 }
+func (i chatFileInfo) ModTime() time.Time { return i.modTime }
+func (i chatFileInfo) IsDir() bool        { return false }
+func (i chatFileInfo) Sys() any           { return nil }
+
+// --- Prompt Repository ---
+
+type SQLitePromptRepository struct {
+	store *SQLiteStore
+}
+
+func (s *SQLiteStore) Prompts() *SQLitePromptRepository {
+	return &SQLitePromptRepository{store: s}
+}
+
+func (r *SQLitePromptRepository) GetAll() ([]*flows.Prompt, error) {
+	rows, err := r.store.db.Query(`SELECT name, content, is_default FROM prompts ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var prompts []*flows.Prompt
+	for rows.Next() {
+		var p flows.Prompt
+		if err := rows.Scan(&p.Name, &p.Content, &p.Default); err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, &p)
+	}
+	return prompts, rows.Err()
+}
+
+func (r *SQLitePromptRepository) GetByID(name string) (*flows.Prompt, error) {
+	var p flows.Prompt
+	err := r.store.db.QueryRow(`SELECT name, content, is_default FROM prompts WHERE name = ?`, name).Scan(&p.Name, &p.Content, &p.Default)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *SQLitePromptRepository) Save(prompt *flows.Prompt) error {
+	if prompt == nil || prompt.Name == "" {
+		return os.ErrInvalid
+	}
+	return r.store.inTx(func(tx *sql.Tx) error {
+		return savePrompt(tx, prompt)
+	})
+}
+
+// savePrompt upserts a prompt within tx; a new one goes last.
+func savePrompt(tx *sql.Tx, prompt *flows.Prompt) error {
+	_, err := tx.Exec(`INSERT INTO prompts (name, content, is_default, position)
+		VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM prompts))
+		ON CONFLICT (name) DO UPDATE SET content = excluded.content, is_default = excluded.is_default`,
+		prompt.Name, prompt.Content, prompt.Default)
+	return err
+}
+
+// SaveAll replaces every prompt in one transaction, keeping their order.
+func (r *SQLitePromptRepository) SaveAll(prompts []*flows.Prompt) error {
+	return r.store.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM prompts`); err != nil {
+			return err
+		}
+		for _, p := range prompts {
+			if err := savePrompt(tx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *SQLitePromptRepository) Delete(name string) error {
+	_, err := r.store.db.Exec(`DELETE FROM prompts WHERE name = ?`, name)
+	return err
+}
+
+// --- Model Repository ---
+
+type SQLiteModelRepository struct {
+	store *SQLiteStore
+}
+
+func (s *SQLiteStore) Models() *SQLiteModelRepository {
+	return &SQLiteModelRepository{store: s}
+}
+
+func (r *SQLiteModelRepository) GetAll() ([]*types.Model, error) {
+	rows, err := r.store.db.Query(`SELECT data FROM models ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var models []*types.Model
+	for rows.Next() {
+		var data string
+		var m types.Model
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			return nil, err
+		}
+		models = append(models, &m)
+	}
+	return models, rows.Err()
+}
+
+func (r *SQLiteModelRepository) GetByID(name string) (*types.Model, error) {
+	var data string
+	err := r.store.db.QueryRow(`SELECT data FROM models WHERE name = ?`, name).Scan(&data)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m types.Model
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *SQLiteModelRepository) Save(model *types.Model) error {
+	if model == nil || model.Name == "" {
+		return os.ErrInvalid
+	}
+	return r.store.inTx(func(tx *sql.Tx) error {
+		return saveModel(tx, model)
+	})
+}
+
+// saveModel upserts a model within tx; a new one goes last.
+func saveModel(tx *sql.Tx, model *types.Model) error {
+	data, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO models (name, provider, data, position)
+		VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM models))
+		ON CONFLICT (name) DO UPDATE SET provider = excluded.provider, data = excluded.data`,
+		model.Name, model.Provider, string(data))
+	return err
+}
+
+// SaveAll replaces every model in one transaction, keeping their order.
+func (r *SQLiteModelRepository) SaveAll(models []*types.Model) error {
+	return r.store.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM models`); err != nil {
+			return err
+		}
+		for _, m := range models {
+			if err := saveModel(tx, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *SQLiteModelRepository) Delete(name string) error {
+	_, err := r.store.db.Exec(`DELETE FROM models WHERE name = ?`, name)
+	return err
+}
+
+// --- API Key Repository ---
+
+type SQLiteAPIKeyRepository struct {
+	store *SQLiteStore
+}
+
+func (s *SQLiteStore) APIKeys() *SQLiteAPIKeyRepository {
+	return &SQLiteAPIKeyRepository{store: s}
+}
+
+func (r *SQLiteAPIKeyRepository) GetAll() ([]types.APIKey, error) {
+	rows, err := r.store.db.Query(`SELECT title, key, url, active FROM api_keys ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []types.APIKey{}
+	for rows.Next() {
+		var k types.APIKey
+		if err := rows.Scan(&k.Title, &k.Key, &k.URL, &k.Active); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// KeyForEndpoint returns the stored key whose URL has the same host as
+// endpoint, or "" when there is none.
+func (r *SQLiteAPIKeyRepository) KeyForEndpoint(endpoint string) string {
+	target, err := url.Parse(endpoint)
+	if err != nil || target.Host == "" {
+		return ""
+	}
+	keys, err := r.GetAll()
+	if err != nil {
+		return ""
+	}
+	for _, k := range keys {
+		if keyURL, err := url.Parse(k.URL); err == nil && keyURL.Host == target.Host {
+			return k.Key
+		}
+	}
+	return ""
+}
+
+// SaveAll replaces every key in one transaction.
+func (r *SQLiteAPIKeyRepository) SaveAll(keys []types.APIKey) error {
+	return r.store.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM api_keys`); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := saveAPIKey(tx, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveAPIKey upserts a key within tx; a new one goes last.
+func saveAPIKey(tx *sql.Tx, k types.APIKey) error {
+	_, err := tx.Exec(`INSERT INTO api_keys (title, key, url, active, position)
+		VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM api_keys))
+		ON CONFLICT (title) DO UPDATE SET key = excluded.key, url = excluded.url, active = excluded.active`,
+		k.Title, k.Key, k.URL, k.Active)
+	return err
+}
+
+func (r *SQLiteAPIKeyRepository) Add(key types.APIKey) error {
+	return r.store.inTx(func(tx *sql.Tx) error {
+		return saveAPIKey(tx, key)
+	})
+}
+
+func (r *SQLiteAPIKeyRepository) Remove(title string) error {
+	_, err := r.store.db.Exec(`DELETE FROM api_keys WHERE title = ?`, title)
+	return err
+}
+
+func (r *SQLiteAPIKeyRepository) SetActive(title string) error {
+	_, err := r.store.db.Exec(`UPDATE api_keys SET active = (title = ?)`, title)
+	return err
+}
+
+// --- Model Params Repository ---
+
+type SQLiteModelParamsRepository struct {
+	store *SQLiteStore
+}
+
+func (s *SQLiteStore) ModelParams() *SQLiteModelParamsRepository {
+	return &SQLiteModelParamsRepository{store: s}
+}
+
+// GetAll returns the default generation settings of every model.
+func (r *SQLiteModelParamsRepository) GetAll() (map[string]aitypes.GenerationParams, error) {
+	rows, err := r.store.db.Query(`SELECT model, params FROM model_params`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	params := map[string]aitypes.GenerationParams{}
+	for rows.Next() {
+		var model, data string
+		if err := rows.Scan(&model, &data); err != nil {
+			return nil, err
+		}
+		var p aitypes.GenerationParams
+		if err := json.Unmarshal([]byte(data), &p); err != nil {
+			return nil, err
+		}
+		params[model] = p
+	}
+	return params, rows.Err()
+}
+
+// Set stores a model's defaults, replacing earlier ones.
+func (r *SQLiteModelParamsRepository) Set(model string, p aitypes.GenerationParams) error {
+	return r.store.inTx(func(tx *sql.Tx) error {
+		return saveModelParams(tx, model, p)
+	})
+}
+
+func saveModelParams(tx *sql.Tx, model string, p aitypes.GenerationParams) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO model_params (model, params) VALUES (?, ?)
+		ON CONFLICT (model) DO UPDATE SET params = excluded.params`, model, string(data))
+	return err
+}
+
+func (r *SQLiteModelParamsRepository) Delete(model string) error {
+	_, err := r.store.db.Exec(`DELETE FROM model_params WHERE model = ?`, model)
+	return err
+}
+
+// --- Model Catalog Repository ---
+
+// ProviderCatalog is the cached model list of one provider.
+type ProviderCatalog struct {
+	FetchedAt time.Time           `json:"fetched_at"`
+	Models    []aitypes.ModelInfo `json:"models"`
+}
+
+type SQLiteModelCatalogRepository struct {
+	store *SQLiteStore
+}
+
+func (s *SQLiteStore) ModelCatalog() *SQLiteModelCatalogRepository {
+	return &SQLiteModelCatalogRepository{store: s}
+}
+
+// GetAll returns the cached model list of every provider.
+func (r *SQLiteModelCatalogRepository) GetAll() (map[string]ProviderCatalog, error) {
+	rows, err := r.store.db.Query(`SELECT provider, fetched_at, models FROM model_catalog`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	catalog := map[string]ProviderCatalog{}
+	for rows.Next() {
+		var provider, data string
+		var fetched int64
+		if err := rows.Scan(&provider, &fetched, &data); err != nil {
+			return nil, err
+		}
+		entry := ProviderCatalog{FetchedAt: time.Unix(fetched, 0)}
+		if err := json.Unmarshal([]byte(data), &entry.Models); err != nil {
+			return nil, err
+		}
+		catalog[provider] = entry
+	}
+	return catalog, rows.Err()
+}
+
+// SaveAll replaces the whole catalog in one transaction.
+func (r *SQLiteModelCatalogRepository) SaveAll(catalog map[string]ProviderCatalog) error {
+	return r.store.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM model_catalog`); err != nil {
+			return err
+		}
+		for provider, entry := range catalog {
+			if err := saveProviderCatalog(tx, provider, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func saveProviderCatalog(tx *sql.Tx, provider string, entry ProviderCatalog) error {
+	data, err := json.Marshal(entry.Models)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO model_catalog (provider, fetched_at, models) VALUES (?, ?, ?)
+		ON CONFLICT (provider) DO UPDATE SET fetched_at = excluded.fetched_at, models = excluded.models`,
+		provider, entry.FetchedAt.Unix(), string(data))
+	return err
+}
+
+// --- Usage Repository ---
+
+type SQLiteUsageRepository struct {
+	store *SQLiteStore
+}
+
+func (s *SQLiteStore) Usage() *SQLiteUsageRepository {
+	return &SQLiteUsageRepository{store: s}
+}
+
+// GetAll returns every usage record in the order they were recorded.
+func (r *SQLiteUsageRepository) GetAll() ([]usage.Record, error) {
+	rows, err := r.store.db.Query(`SELECT data FROM usage ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []usage.Record{}
+	for rows.Next() {
+		var data string
+		var record usage.Record
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// Record appends a record.
+func (r *SQLiteUsageRepository) Record(record usage.Record) error {
+	return r.store.inTx(func(tx *sql.Tx) error {
+		return saveUsage(tx, record)
+	})
+}
+
+func saveUsage(tx *sql.Tx, record usage.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO usage (recorded_at, data) VALUES (?, ?)`, record.Time.Unix(), string(data))
+	return err
+}
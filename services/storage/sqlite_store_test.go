@@ -0,0 +1,362 @@
+package storage
+
+import (
+	aitypes "aichat/services/ai/types"
+	"aichat/services/ai/usage"
+	"aichat/types"
+	"aichat/types/flows"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func chat(title string, modified int64, contents ...string) *types.ChatFile {
+	c := &types.ChatFile{Metadata: types.ChatMetadata{Title: title, ModifiedAt: modified}}
+	for i, content := range contents {
+		c.Messages = append(c.Messages, types.Message{Role: "user", Content: content, MessageNumber: i})
+	}
+	return c
+}
+
+// summary lists each chat as its title followed by its message contents.
+func summary(chats []*types.ChatFile) [][]string {
+	out := [][]string{}
+	for _, c := range chats {
+		line := []string{c.Metadata.Title}
+		for _, m := range c.Messages {
+			line = append(line, m.Content)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func TestSQLiteChats(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(r *SQLiteChatRepository) error
+		want [][]string
+	}{
+		{
+			name: "empty",
+			run:  func(r *SQLiteChatRepository) error { return nil },
+			want: [][]string{},
+		},
+		{
+			name: "most recently modified first, messages in order",
+			run: func(r *SQLiteChatRepository) error {
+				for _, c := range []*types.ChatFile{chat("old", 100, "a", "b"), chat("new", 300, "c"), chat("mid", 200, "d", "e", "f")} {
+					if err := r.Save(c); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			want: [][]string{{"new", "c"}, {"mid", "d", "e", "f"}, {"old", "a", "b"}},
+		},
+		{
+			name: "chat without messages",
+			run: func(r *SQLiteChatRepository) error {
+				if err := r.Save(chat("empty", 200)); err != nil {
+					return err
+				}
+				return r.Save(chat("full", 100, "x"))
+			},
+			want: [][]string{{"empty"}, {"full", "x"}},
+		},
+		{
+			name: "save replaces messages",
+			run: func(r *SQLiteChatRepository) error {
+				if err := r.Save(chat("c", 100, "a", "b", "c")); err != nil {
+					return err
+				}
+				return r.Save(chat("c", 200, "z"))
+			},
+			want: [][]string{{"c", "z"}},
+		},
+		{
+			name: "delete takes the messages along",
+			run: func(r *SQLiteChatRepository) error {
+				if err := r.Save(chat("gone", 100, "a")); err != nil {
+					return err
+				}
+				if err := r.Save(chat("kept", 100, "b")); err != nil {
+					return err
+				}
+				return r.Delete("gone")
+			},
+			want: [][]string{{"kept", "b"}},
+		},
+		{
+			name: "save all replaces every chat",
+			run: func(r *SQLiteChatRepository) error {
+				if err := r.Save(chat("before", 100, "a")); err != nil {
+					return err
+				}
+				return r.SaveAll([]*types.ChatFile{chat("one", 100, "x"), chat("two", 200, "y")})
+			},
+			want: [][]string{{"two", "y"}, {"one", "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := openTestStore(t).Chats()
+			if err := tt.run(r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			all, err := r.GetAll()
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			if got := summary(all); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAll = %q, want %q", got, tt.want)
+			}
+			for _, c := range all {
+				one, err := r.GetByID(c.Metadata.Title)
+				if err != nil {
+					t.Fatalf("GetByID(%q): %v", c.Metadata.Title, err)
+				}
+				if !reflect.DeepEqual(summary([]*types.ChatFile{one}), summary([]*types.ChatFile{c})) {
+					t.Errorf("GetByID(%q) differs from GetAll", c.Metadata.Title)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteChatErrors(t *testing.T) {
+	r := openTestStore(t).Chats()
+	if _, err := r.GetByID("missing"); !os.IsNotExist(err) {
+		t.Errorf("GetByID of a missing chat = %v, want os.ErrNotExist", err)
+	}
+	if err := r.Delete("missing"); !os.IsNotExist(err) {
+		t.Errorf("Delete of a missing chat = %v, want os.ErrNotExist", err)
+	}
+	if err := r.Save(chat("", 100)); err != os.ErrInvalid {
+		t.Errorf("Save without a title = %v, want os.ErrInvalid", err)
+	}
+	if err := r.Save(chat("c", 1700000000, "12345")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := r.GetChatFileInfo("c")
+	if err != nil {
+		t.Fatalf("GetChatFileInfo: %v", err)
+	}
+	if info.Name() != "c" || info.Size() == 0 || info.ModTime().Unix() != 1700000000 {
+		t.Errorf("GetChatFileInfo = %q, %d bytes, %v", info.Name(), info.Size(), info.ModTime())
+	}
+}
+
+func TestSQLiteSettings(t *testing.T) {
+	store := openTestStore(t)
+	temp := 0.5
+
+	params := store.ModelParams()
+	if err := params.Set("m1", aitypes.GenerationParams{Temperature: &temp}); err != nil {
+		t.Fatal(err)
+	}
+	if err := params.Set("m2", aitypes.GenerationParams{MaxTokens: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := params.Delete("m2"); err != nil {
+		t.Fatal(err)
+	}
+	gotParams, err := params.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotParams) != 1 || gotParams["m1"].Temperature == nil || *gotParams["m1"].Temperature != temp {
+		t.Errorf("model params = %+v, want only m1 at temperature %v", gotParams, temp)
+	}
+
+	fetched := time.Unix(1700000000, 0)
+	catalog := map[string]ProviderCatalog{
+		"OpenAI": {FetchedAt: fetched, Models: []aitypes.ModelInfo{{ID: "gpt-4o", ContextWindow: 128000}}},
+		"Gemini": {FetchedAt: fetched, Models: []aitypes.ModelInfo{{ID: "gemini-pro"}}},
+	}
+	if err := store.ModelCatalog().SaveAll(catalog); err != nil {
+		t.Fatal(err)
+	}
+	delete(catalog, "Gemini")
+	if err := store.ModelCatalog().SaveAll(catalog); err != nil {
+		t.Fatal(err)
+	}
+	gotCatalog, err := store.ModelCatalog().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotCatalog) != 1 || !gotCatalog["OpenAI"].FetchedAt.Equal(fetched) || !reflect.DeepEqual(gotCatalog["OpenAI"].Models, catalog["OpenAI"].Models) {
+		t.Errorf("catalog = %+v, want %+v", gotCatalog, catalog)
+	}
+
+	for _, model := range []string{"a", "b", "c"} {
+		if err := store.Usage().Record(usage.Record{Time: fetched, Model: model}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := store.Usage().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var models []string
+	for _, r := range records {
+		models = append(models, r.Model)
+	}
+	if !reflect.DeepEqual(models, []string{"a", "b", "c"}) {
+		t.Errorf("usage models = %q, want them in recording order", models)
+	}
+}
+
+func TestMigrateReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Chats().Save(chat("c", 100, "a")); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("database mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+
+	store, err = OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer store.Close()
+	var version int
+	if err := store.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(schema) {
+		t.Errorf("schema version = %d, want %d", version, len(schema))
+	}
+	if _, err := store.Chats().GetByID("c"); err != nil {
+		t.Errorf("chat lost on reopen: %v", err)
+	}
+}
+
+// writeFiles writes files (name to content) under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImportJSON(t *testing.T) {
+	layout := map[string]string{
+		"chats.json": `[
+			{"metadata": {"title": "shared", "modified_at": 100}, "messages": [{"role": "user", "content": "from chats.json"}]},
+			{"metadata": {"title": "list only", "modified_at": 50}, "messages": []},
+			{"metadata": {"title": " "}, "messages": []}
+		]`,
+		"chats/shared.json":  `{"metadata": {"title": "shared", "modified_at": 300}, "messages": [{"role": "user", "content": "from chats/"}]}`,
+		"chats/file.json":    `{"metadata": {"title": "file only", "modified_at": 200}, "messages": [{"role": "user", "content": "hi"}]}`,
+		"prompts.json":       `[{"name": "terse", "content": "Be brief."}, {"name": "", "content": "nameless"}]`,
+		"models.json":        `{"models": [{"name": "gpt-4o", "provider": "OpenAI", "is_default": true}]}`,
+		"api_keys.json":      `{"keys": [{"title": "main", "key": "sk-1", "url": "https://api.openai.com/v1", "active": true}]}`,
+		"model_params.json":  `{"gpt-4o": {"temperature": 0.2}}`,
+		"model_catalog.json": `{"OpenAI": {"fetched_at": "2024-05-01T12:00:00Z", "models": [{"id": "gpt-4o"}]}}`,
+		"usage.jsonl":        "{\"model\": \"gpt-4o\"}\n{\"model\": \"gpt-4o-mi\n{\"model\": \"o3\"}\n",
+	}
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    ImportReport
+		wantErr bool
+	}{
+		{name: "nothing to import", files: map[string]string{}, want: ImportReport{}},
+		{
+			name:  "full layout",
+			files: layout,
+			want:  ImportReport{Chats: 3, Prompts: 1, Models: 1, Keys: 1, ModelParams: 1, Catalogs: 1, Usage: 2},
+		},
+		{
+			name:    "broken file",
+			files:   map[string]string{"prompts.json": `[{"name": `},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			store := openTestStore(t)
+			report, err := store.ImportJSON(dir)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("no error for a broken file")
+				}
+				// Nothing was recorded, so a fixed file is imported next time.
+				if again, err := store.ImportJSON(t.TempDir()); err != nil || again.Skipped {
+					t.Errorf("import after a failure = %+v, %v; want a fresh import", again, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ImportJSON: %v", err)
+			}
+			if report != tt.want {
+				t.Errorf("report = %+v, want %+v", report, tt.want)
+			}
+			again, err := store.ImportJSON(dir)
+			if err != nil || !again.Skipped {
+				t.Errorf("second import = %+v, %v; want it skipped", again, err)
+			}
+		})
+	}
+}
+
+func TestImportJSONContents(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"chats.json":        `[{"metadata": {"title": "shared", "modified_at": 100}, "messages": [{"role": "user", "content": "from chats.json"}]}]`,
+		"chats/shared.json": `{"metadata": {"title": "shared", "modified_at": 300}, "messages": [{"role": "user", "content": "from chats/"}]}`,
+		"prompts.json":      `[{"name": "b", "content": "2"}, {"name": "a", "content": "1", "default": true}]`,
+		"api_keys.json":     `{"keys": [{"title": "main", "key": "sk-1", "url": "https://api.openai.com/v1", "active": true}]}`,
+	})
+	store := openTestStore(t)
+	if _, err := store.ImportJSON(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	chats, err := store.Chats().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(chats), [][]string{{"shared", "from chats/"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chats = %q, want %q (per-chat files win)", got, want)
+	}
+	prompts, err := store.Prompts().GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*flows.Prompt{{Name: "b", Content: "2"}, {Name: "a", Content: "1", Default: true}}
+	if !reflect.DeepEqual(prompts, want) {
+		t.Errorf("prompts = %+v, want %+v in file order", prompts, want)
+	}
+	if key := store.APIKeys().KeyForEndpoint("https://api.openai.com/v1/chat/completions"); key != "sk-1" {
+		t.Errorf("KeyForEndpoint = %q, want the imported key", key)
+	}
+}
@@ -4,6 +4,8 @@ package types
 // Contains AppConfig and related configuration logic for the app package.
 
 import (
+	"strconv"
+
 	"gopkg.in/ini.v1"
 )
 
@@ -48,3 +50,75 @@ func SetCurrentTheme(themeName string) error {
 	cfg.Section("Theme").Key("currentTheme").SetValue(themeName)
 	return cfg.SaveTo(settingsPath)
 }
+
+// GetContextSettings reads the context-window strategy ("drop-oldest",
+// "last-n" or "summarize") and the number of turns to keep from settings.ini.
+func GetContextSettings() (strategy string, keepTurns int) {
+	cfg, err := ini.Load(settingsPath)
+	if err != nil {
+		return "drop-oldest", 0
+	}
+	section := cfg.Section("Context")
+	return section.Key("strategy").MustString("drop-oldest"), section.Key("keepTurns").MustInt(0)
+}
+
+// SetContextSettings writes the context-window strategy to the settings.ini file
+func SetContextSettings(strategy string, keepTurns int) error {
+	cfg, err := ini.LoadSources(ini.LoadOptions{Loose: true}, settingsPath)
+	if err != nil {
+		cfg = ini.Empty()
+	}
+	cfg.Section("Context").Key("strategy").SetValue(strategy)
+	cfg.Section("Context").Key("keepTurns").SetValue(strconv.Itoa(keepTurns))
+	return cfg.SaveTo(settingsPath)
+}
+
+// GetHTTPLogEnabled reports whether provider requests are logged for
+// debugging, off unless enabled in settings.ini.
+func GetHTTPLogEnabled() bool {
+	cfg, err := ini.Load(settingsPath)
+	if err != nil {
+		return false
+	}
+	return cfg.Section("Debug").Key("httpLog").MustBool(false)
+}
+
+// SetHTTPLogEnabled writes the HTTP logging switch to the settings.ini file
+func SetHTTPLogEnabled(enabled bool) error {
+	cfg, err := ini.LoadSources(ini.LoadOptions{Loose: true}, settingsPath)
+	if err != nil {
+		cfg = ini.Empty()
+	}
+	cfg.Section("Debug").Key("httpLog").SetValue(strconv.FormatBool(enabled))
+	return cfg.SaveTo(settingsPath)
+}
+
+// GetLogLevel reads the application log level ("debug", "info", "warn" or
+// "error") from settings.ini, "info" unless set.
+func GetLogLevel() string {
+	cfg, err := ini.Load(settingsPath)
+	if err != nil {
+		return "info"
+	}
+	return cfg.Section("Debug").Key("logLevel").MustString("info")
+}
+
+// GetStorageBackend reads the storage backend ("json" or "sqlite") from
+// settings.ini, "json" unless set.
+func GetStorageBackend() string {
+	cfg, err := ini.Load(settingsPath)
+	if err != nil {
+		return "json"
+	}
+	return cfg.Section("Storage").Key("backend").MustString("json")
+}
+
+// SetStorageBackend writes the storage backend to the settings.ini file
+func SetStorageBackend(backend string) error {
+	cfg, err := ini.LoadSources(ini.LoadOptions{Loose: true}, settingsPath)
+	if err != nil {
+		cfg = ini.Empty()
+	}
+	cfg.Section("Storage").Key("backend").SetValue(backend)
+	return cfg.SaveTo(settingsPath)
+}
@@ -5,14 +5,19 @@ package types
 // ├── Chats
 // │   ├── Add new chat (input modal)
 // │   ├── List Chats (list view: d=delete, f=favorite, r=rename)
-// │   └── Create custom chat (multi-step: name → select prompt → select model)
+// │   ├── Create custom chat (multi-step: name → select prompt → select model)
+// │   ├── Chat settings (list view → temperature, top_p, max tokens, stop)
+// │   ├── Compare models (list view → multi-select models → side-by-side answers, keep one)
+// │   └── View last request (modal: last provider HTTP exchange, keys redacted)
 // ├── Prompts
 // │   ├── Add new prompt (input modal - multi step: prompt name then prompt for the text)
 // │   ├── Set default prompt (list view)
 // │   └── Delete prompt (list view)
 // ├── Models
 // │   ├── Add model (input modal - multi step: prompt name then prompt for model string)
-// │   └── List models (list view: a=set active, d=delete, r=rename)
+// │   ├── List models (list view: a=set active, d=delete, r=rename)
+// │   ├── Model defaults (list view → default sampling settings per model)
+// │   └── Refresh model catalog (fetch each provider's /models, cached for offline use)
 // ├── Help
 // │   ├── Show control overview (modal)
 // │   └── Show about (modal)
@@ -21,7 +26,11 @@ package types
 // │   │   ├── Add key (input modal multi step - input name, then key, then select provider from list of providers) key stored in schema [name, key, provider, active] json
 // │   │   └── Set active key (list view)
 // │   ├── Providers
-// │   │   └── Add provider (input modal multi step - name then endpoint)
+// │   │   ├── List providers (list view → edit a definition from the providers file)
+// │   │   └── Add provider (form: name, type, base URL, auth scheme, headers, capabilities; saved to providers.json)
+// │   ├── Usage Report (token usage and spend by day, model, provider, key)
+// │   ├── HTTP Logging (toggle: redacted request/response log in .config/logs)
+// │   ├── MCP Servers (list view → show tools/resources/prompts, enable/disable, restart)
 // │   └── Themes
 // │       ├── List themes (list view: preview on highlight, set on enter, r rename, d delete)
 // │       └── Generate theme (input prompt for name then action)
@@ -117,6 +126,27 @@ func init() {
 			Description: "Multi-step: name → select prompt → select model",
 			Action:      menus.CustomChatAction,
 		},
+		{
+			Text:        "Chat Settings",
+			Description: "Temperature, top_p, max tokens and stop sequences per chat",
+			Action:      menus.ChatSettingsAction,
+		},
+		{
+			Text:        "Compare Models",
+			Description: "Send one prompt to several models side by side and keep the best answer",
+			Action: func(ctx interfaces.Context, nav interfaces.Controller) error {
+				// Indirect through high-level handler to avoid import cycle
+				if handler, ok := nav.(interface{ HandleCompare() }); ok {
+					handler.HandleCompare()
+				}
+				return nil
+			},
+		},
+		{
+			Text:        "View Last Request",
+			Description: "Last provider request and response, keys redacted",
+			Action:      menus.ViewLastRequestAction,
+		},
 		{
 			Text:   "Back",
 			Action: func(ctx interfaces.Context, nav interfaces.Controller) error { nav.Pop(); return nil },
@@ -163,6 +193,14 @@ func init() {
 			Text:   "Delete Model",
 			Action: menus.DeleteModelAction,
 		},
+		{
+			Text:   "Model Defaults",
+			Action: menus.ModelDefaultsAction,
+		},
+		{
+			Text:   "Refresh Model Catalog",
+			Action: menus.RefreshModelCatalogAction,
+		},
 		{
 			Text:   "Back",
 			Action: func(ctx interfaces.Context, nav interfaces.Controller) error { nav.Pop(); return nil },
@@ -184,6 +222,29 @@ func init() {
 				return nil
 			},
 		},
+		{
+			Text:   "Usage Report",
+			Action: menus.UsageReportAction,
+		},
+		{
+			Text:        "Context Window",
+			Description: "How chats too long for their model are shortened",
+			Action:      menus.ContextSettingsAction,
+		},
+		{
+			Text:        "Storage Backend",
+			Description: "JSON files or a SQLite database, from the next start",
+			Action:      menus.StorageBackendAction,
+		},
+		{
+			Text:   "HTTP Logging",
+			Action: menus.ToggleHTTPLogAction,
+		},
+		{
+			Text:        "MCP Servers",
+			Description: "Local tool servers: state, tools, resources and prompts",
+			Action:      menus.MCPServersAction,
+		},
 		{
 			Text: "Themes",
 			Action: func(ctx interfaces.Context, nav interfaces.Controller) error {
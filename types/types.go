@@ -2,6 +2,7 @@ package types
 package types
 
 import (
+	aitypes "aichat/services/ai/types"
 	"encoding/json"
 	"os"
 	"time"
@@ -19,20 +20,62 @@ type Event struct {
 type ModalType string
 
 // Message represents a chat message (shared across app, for JSON serialization).
+// Role is "system", "user", "assistant" or "tool". Assistant messages may
+// carry ToolCalls; "tool" messages hold a result for ToolCallID from ToolName.
+// Attachments are stored as file references, never inline data.
 type Message struct {
-	Role          string `json:"role"`
-	Content       string `json:"content"`
-	MessageNumber int    `json:"message_number"`
+	Role          string                 `json:"role"`
+	Content       string                 `json:"content"`
+	MessageNumber int                    `json:"message_number"`
+	Attachments   []aitypes.Attachment   `json:"attachments,omitempty"`
+	ToolCalls     []aitypes.ToolCall     `json:"tool_calls,omitempty"`
+	ToolCallID    string                 `json:"tool_call_id,omitempty"`
+	ToolName      string                 `json:"tool_name,omitempty"`
+	Usage         *aitypes.Usage         `json:"usage,omitempty"`    // Tokens and cost of the exchange that produced it
+	Provider      string                 `json:"provider,omitempty"` // Provider that answered, which may differ from the chat's under a fallback chain
+	Model         string                 `json:"model,omitempty"`    // Model that answered
+	Metrics       *aitypes.StreamMetrics `json:"metrics,omitempty"`  // Latency and throughput of the streamed answer
+	// Reasoning is the thinking a reasoning model streamed before answering.
+	// It is shown with the message but never sent back to the model.
+	Reasoning string `json:"reasoning,omitempty"`
+}
+
+// ToAI converts a stored message to the provider request format.
+func (m Message) ToAI() aitypes.Message {
+	return aitypes.Message{
+		Role:        m.Role,
+		Content:     m.Content,
+		Attachments: m.Attachments,
+		ToolCalls:   m.ToolCalls,
+		ToolCallID:  m.ToolCallID,
+		Name:        m.ToolName,
+	}
+}
+
+// MessageFromAI converts a provider message to a stored message.
+func MessageFromAI(m aitypes.Message, number int) Message {
+	return Message{
+		Role:          m.Role,
+		Content:       m.Content,
+		MessageNumber: number,
+		Attachments:   m.Attachments,
+		ToolCalls:     m.ToolCalls,
+		ToolCallID:    m.ToolCallID,
+		ToolName:      m.Name,
+	}
 }
 
 // ChatMetadata stores additional information about a chat session.
 type ChatMetadata struct {
-	Summary    string    `json:"summary,omitempty"`
-	Title      string    `json:"title,omitempty"`
-	CreatedAt  time.Time `json:"created_at,omitempty"`
-	Model      string    `json:"model,omitempty"`
-	Favorite   bool      `json:"favorite,omitempty"`
-	ModifiedAt int64     `json:"modified_at,omitempty"` // Unix timestamp for last modification
+	Summary    string         `json:"summary,omitempty"`
+	Title      string         `json:"title,omitempty"`
+	CreatedAt  time.Time      `json:"created_at,omitempty"`
+	Model      string         `json:"model,omitempty"`
+	Favorite   bool           `json:"favorite,omitempty"`
+	ModifiedAt int64          `json:"modified_at,omitempty"` // Unix timestamp for last modification
+	Usage      *aitypes.Usage `json:"usage,omitempty"`       // Running token and cost totals for the chat
+	// Params are the chat's sampling settings, applied over the model defaults
+	Params *aitypes.GenerationParams `json:"params,omitempty"`
 }
 
 // ChatFile represents the complete chat file structure for JSON storage.
@@ -41,10 +84,27 @@ type ChatFile struct {
 	Messages []Message    `json:"messages"`
 }
 
+// AddUsage adds an exchange's usage to the chat totals.
+func (c *ChatFile) AddUsage(u aitypes.Usage) {
+	if c.Metadata.Usage == nil {
+		c.Metadata.Usage = &aitypes.Usage{}
+	}
+	c.Metadata.Usage.Add(u)
+}
+
+// GenerationParams returns the chat's sampling settings applied over the
+// model's defaults.
+func (c *ChatFile) GenerationParams(defaults aitypes.GenerationParams) aitypes.GenerationParams {
+	return defaults.Merge(c.Metadata.Params)
+}
+
 // Model represents an AI model configuration.
+// Provider and Info are set for models from the model catalog.
 type Model struct {
-	Name      string `json:"name"`
-	IsDefault bool   `json:"is_default"`
+	Name      string             `json:"name"`
+	IsDefault bool               `json:"is_default"`
+	Provider  string             `json:"provider,omitempty"`
+	Info      *aitypes.ModelInfo `json:"info,omitempty"`
 }
 
 // ModelsConfig represents the models configuration stored in JSON.
@@ -216,4 +276,3 @@ func SaveModelsToFile(models []Model, filePath string) error {
 	}
 	return os.WriteFile(filePath, data, 0644)
 }
-
@@ -2,6 +2,10 @@ package main
 package main
 
 import (
+	"aichat/services/ai"
+	"aichat/services/ai/providers"
+	"aichat/services/ai/sse"
+	aitypes "aichat/services/ai/types"
 	"aichat/services/storage/repositories"
 	"aichat/types"
 	"bufio"
@@ -11,6 +15,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -56,7 +61,10 @@ type APIKeysConfig struct {
 	Keys []APIKey `json:"keys"`
 }
 
-var apiKeyRepo = repositories.NewAPIKeyRepository()
+// apiKeyRepo returns the key repository of the current storage backend.
+func apiKeyRepo() *repositories.APIKeyRepository {
+	return repositories.NewAPIKeyRepository()
+}
 
 func prependSystemPromptLocal(messages []types.Message, systemPrompt types.Message) []types.Message {
 	if len(messages) == 0 || messages[0].Role != "system" || messages[0].Content != systemPrompt.Content {
@@ -67,7 +75,7 @@ func prependSystemPromptLocal(messages []types.Message, systemPrompt types.Messa
 
 // loadAPIKeys loads the API keys configuration from the repository.
 func loadAPIKeys() (*types.APIKeysConfig, error) {
-	keys, err := apiKeyRepo.GetAll()
+	keys, err := apiKeyRepo().GetAll()
 	if err != nil {
 		return nil, errors.NewStorageError("utils.go", "failed to load API keys", err)
 	}
@@ -76,12 +84,12 @@ func loadAPIKeys() (*types.APIKeysConfig, error) {
 
 // saveAPIKeys saves the API keys configuration to the repository.
 func saveAPIKeys(config *types.APIKeysConfig) error {
-	return apiKeyRepo.SaveAll(config.Keys)
+	return apiKeyRepo().SaveAll(config.Keys)
 }
 
 // getActiveAPIKey returns the currently active API key from the repository, or an error if not found.
 func getActiveAPIKey() (string, error) {
-	keys, err := apiKeyRepo.GetAll()
+	keys, err := apiKeyRepo().GetAll()
 	if err != nil {
 		return "", errors.NewStorageError("utils.go", "failed to get active API key", err)
 	}
@@ -95,7 +103,7 @@ func getActiveAPIKey() (string, error) {
 
 // getActiveAPIKeyAndURL returns the currently active API key and its URL from the repository, or an error if not found.
 func getActiveAPIKeyAndURL() (string, string, error) {
-	keys, err := apiKeyRepo.GetAll()
+	keys, err := apiKeyRepo().GetAll()
 	if err != nil {
 		return "", "", errors.NewStorageError("utils.go", "failed to get active API key and URL", err)
 	}
@@ -138,12 +146,19 @@ func TestAPIKeyWithModel(model string) string {
 	if err != nil {
 		return "Error: failed to create request: " + err.Error()
 	}
+	network := networkForURL(url)
+	client, err := providers.NewHTTPClient(network)
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	if network != nil {
+		for k, v := range network.Headers {
+			req.Header.Set(k, v)
+		}
+	}
 	req.Header.Set("Authorization", "Bearer "+key)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("HTTP-Referer", "https://github.com/go-ai-cli")
-	req.Header.Set("X-Title", "Go AI CLI")
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
@@ -164,35 +179,32 @@ func TestAPIKeyWithModel(model string) string {
 		return "Error: API returned status " + fmt.Sprint(resp.StatusCode) + ": " + string(body)
 	}
 
-	reader := bufio.NewReader(resp.Body)
-	var buffer string
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "Error: reading response: " + err.Error()
-		}
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, ":") {
-			continue
+	// Any event with data means the key works; a clean end means it didn't answer.
+	if _, err := sse.NewDecoder(resp.Body, url).Next(); err != nil {
+		if err == io.EOF {
+			return "No response (empty)"
 		}
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-		data := line[len("data: "):]
-		if data == "[DONE]" {
-			break
+		if ctx.Err() == context.DeadlineExceeded {
+			return "No response (timeout)"
 		}
-		buffer += data
-		// If we get any content, consider it working
-		if len(buffer) > 0 {
-			return "Key is working"
+		return "Error: " + err.Error()
+	}
+	return "Key is working"
+}
+
+// networkForURL returns the network settings of the provider serving rawURL,
+// so a key test goes through the same proxy, CA bundle and headers as chats.
+func networkForURL(rawURL string) *aitypes.NetworkSettings {
+	target, err := neturl.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	for _, p := range ai.GetAllProviders() {
+		if endpoint, err := neturl.Parse(p.Info().Endpoint); err == nil && endpoint.Host == target.Host {
+			return p.Info().Network
 		}
 	}
-	// If we get here, no content was received
-	return "No response (empty)"
+	return nil
 }
 
 // ensureEnvironment creates required directories and config files if missing.
@@ -273,18 +285,18 @@ func promptAndSaveAPIKey(reader *bufio.Reader) error {
 
 // addAPIKey adds a new API key with the given title, key, and URL, and sets as active if first key.
 func addAPIKey(title, key, url string) error {
-	keys, err := apiKeyRepo.GetAll()
+	keys, err := apiKeyRepo().GetAll()
 	if err != nil {
 		return errors.NewStorageError("utils.go", "failed to add API key", err)
 	}
 	active := len(keys) == 0
 	newKey := types.APIKey{Title: title, Key: key, URL: url, Active: active}
-	return apiKeyRepo.Add(newKey)
+	return apiKeyRepo().Add(newKey)
 }
 
 // setKeyActiveByTitle sets the given API key title as active and all others as inactive, and saves the config
 func setKeyActiveByTitle(title string) error {
-	keys, err := apiKeyRepo.GetAll()
+	keys, err := apiKeyRepo().GetAll()
 	if err != nil {
 		return errors.NewStorageError("utils.go", "failed to set key active by title", err)
 	}
@@ -300,12 +312,12 @@ func setKeyActiveByTitle(title string) error {
 	if !found {
 		return errors.NewConfigurationError("utils.go", fmt.Sprintf("API key with title '%s' not found", title))
 	}
-	return apiKeyRepo.SaveAll(keys)
+	return apiKeyRepo().SaveAll(keys)
 }
 
 // setActiveAPIKey sets the given API key title as the active key in the repository.
 func setActiveAPIKey(title string) error {
-	return apiKeyRepo.SetActive(title)
+	return apiKeyRepo().SetActive(title)
 }
 
 // STUBS for missing model file helpers